@@ -4,130 +4,103 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
-	"crypto/rand"
-	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"reflect"
-	"runtime"
-	"strconv"
 	"sync"
 	"time"
 
 	"github.com/go-resty/resty/v2"
-	"github.com/shirou/gopsutil/v4/cpu"
-	"github.com/shirou/gopsutil/v4/mem"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	grpc_gzip "google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/protobuf/proto"
+	"google.golang.org/grpc/status"
 
+	"github.com/itallix/go-metrics/internal/collector"
+	"github.com/itallix/go-metrics/internal/grpc/api"
+	"github.com/itallix/go-metrics/internal/grpc/interceptor"
 	pb "github.com/itallix/go-metrics/internal/grpc/proto"
 	"github.com/itallix/go-metrics/internal/logger"
 	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/queue"
 	"github.com/itallix/go-metrics/internal/service"
 )
 
 const (
 	requestTimeoutSeconds = 10
-	clientCert            = "client.pem"
+	pollCount             = "PollCount"
 )
 
-var RuntimeMetrics = []string{
-	"Alloc", "BuckHashSys", "Frees", "GCCPUFraction", "GCSys", "HeapAlloc", "HeapIdle", "HeapInuse", "HeapObjects",
-	"HeapReleased", "HeapSys", "LastGC", "Lookups", "MCacheInuse", "MCacheSys", "MSpanInuse", "MSpanSys", "Mallocs",
-	"NextGC", "NumForcedGC", "NumGC", "OtherSys", "PauseTotalNs", "StackInuse", "StackSys", "Sys", "TotalAlloc",
-}
+// errRejected marks batches that the server refused to accept, sending them again would not help.
+var errRejected = errors.New("metrics rejected by the server")
 
 type agent struct {
-	runtime.MemStats
-
 	HTTPClient  *resty.Client
 	GRPCClient  *GRPCMetricsClient
 	Counter     int64
 	Gauges      map[string]model.Metrics
+	Latency     *model.HistogramValue
 	HashService service.HashService
+	Queue       *queue.Queue
 	RetryDelays []time.Duration
 	mu          sync.RWMutex
-	cpuCount    int
 	cryptoKey   string
+	// collected holds the series keys of the last successful collection of every collector.
+	collected map[string]map[string]bool
 }
 
-func newAgent(httpClient *resty.Client, grpcClient *GRPCMetricsClient, secretKey string, cryptoKey string) (*agent, error) {
+func newAgent(httpClient *resty.Client, grpcClient *GRPCMetricsClient, secretKey string, cryptoKey string) *agent {
 	var hashService service.HashService
 	if secretKey != "" {
 		hashService = service.NewHashService(secretKey)
 	}
-	cpuCount, err := cpu.Counts(true)
-	if err != nil {
-		return nil, fmt.Errorf("cannot detect number of cpus: %w", err)
-	}
 
 	return &agent{
 		HTTPClient:  httpClient,
 		GRPCClient:  grpcClient,
 		Gauges:      make(map[string]model.Metrics),
+		Latency:     model.NewHistogramValue(model.DefaultBuckets),
 		HashService: hashService,
 		RetryDelays: []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
-		cpuCount:    cpuCount,
 		cryptoKey:   cryptoKey,
-	}, nil
+		collected:   make(map[string]map[string]bool),
+	}
 }
 
-func (m *agent) collectRuntime() error {
-	runtime.ReadMemStats(&m.MemStats)
-
-	var randomValue float64
-	if err := binary.Read(rand.Reader, binary.BigEndian, &randomValue); err != nil {
-		return fmt.Errorf("error generating random number: %w", err)
-	}
+// poll increments PollCount once per poll interval, however many collectors run within it.
+func (m *agent) poll() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.Counter++
-	m.Gauges["RandomValue"] = *model.NewGauge("RandomValue", &randomValue)
-
-	for _, name := range RuntimeMetrics {
-		v := reflect.ValueOf(m.MemStats)
-		fieldVal := v.FieldByName(name)
-		var vv float64
-
-		if fieldVal.IsValid() {
-			switch fieldVal.Kind() {
-			case reflect.Uint, reflect.Uint64, reflect.Uint32:
-				vv = float64(fieldVal.Uint())
-			default:
-				vv = fieldVal.Float()
-			}
-			m.Gauges[name] = *model.NewGauge(name, &vv)
-		} else {
-			return fmt.Errorf("field %s does not exist in MemStats", name)
-		}
-	}
-
-	return nil
 }
 
-func (m *agent) collectExtra() error {
-	v, err := mem.VirtualMemory()
+// store keeps the gauges of the collection until they are reported.
+// A successful collection replaces the previous one, so series that are gone, e.g. exited processes, are not
+// reported anymore. Errors are reported per collector, the gauges collected before the error are kept.
+func (m *agent) store(c collector.Collector, metrics []model.Metrics, err error) {
 	if err != nil {
-		return fmt.Errorf("error collecting virtual memory: %w", err)
+		logger.Log().Errorf("Issue collecting metrics with %s collector: %v", c.Name(), err)
 	}
-	percentages, err := cpu.Percent(1*time.Second, true)
-	if err != nil {
-		return fmt.Errorf("error collecting cpu utilization: %w", err)
-	}
-	totalMem := float64(v.Total)
-	freeMem := float64(v.Free)
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.Gauges["TotalMemory"] = *model.NewGauge("TotalMemory", &totalMem)
-	m.Gauges["FreeMemory"] = *model.NewGauge("FreeMemory", &freeMem)
-	for i := 0; i < m.cpuCount; i++ {
-		metricName := "CPUutilization" + strconv.Itoa(i)
-		m.Gauges[metricName] = *model.NewGauge(metricName, &percentages[i])
+	keys := make(map[string]bool, len(metrics))
+	for _, metric := range metrics {
+		key := metric.SeriesKey()
+		m.Gauges[key] = metric
+		keys[key] = true
 	}
-	return err
+	if err == nil {
+		for key := range m.collected[c.Name()] {
+			if !keys[key] {
+				delete(m.Gauges, key)
+			}
+		}
+		m.collected[c.Name()] = keys
+	}
+	logger.Log().Debugf("Collected %d metrics with %s collector", len(metrics), c.Name())
 }
 
 func (m *agent) send(ctx context.Context, wg *sync.WaitGroup, jobs <-chan []model.Metrics, results chan<- error) {
@@ -138,123 +111,280 @@ func (m *agent) send(ctx context.Context, wg *sync.WaitGroup, jobs <-chan []mode
 	m.sendGRPC(ctx, wg, jobs, results)
 }
 
+// deliver sends the batch right away or, when the agent has a queue, queues it and replays the queue,
+// so batches that failed to be sent earlier are delivered first and in order.
+func (m *agent) deliver(metrics []model.Metrics, send func(metrics []model.Metrics) error) error {
+	if m.Queue == nil {
+		return m.sendBatch(metrics, send)
+	}
+	if err := m.Queue.Push(metrics); err != nil {
+		logger.Log().Errorf("Cannot queue metrics, sending them directly: %v", err)
+		return m.sendBatch(metrics, send)
+	}
+	// PollCount of the queued batch is going to be delivered with it
+	m.delivered(metrics)
+	return m.replay(send)
+}
+
+// replay sends queued batches until the queue is empty or the server is unreachable.
+// Batches rejected by the server are dropped, otherwise they would block the queue forever.
+func (m *agent) replay(send func(metrics []model.Metrics) error) error {
+	return m.Queue.Replay(func(metrics []model.Metrics) error {
+		start := time.Now()
+		err := send(metrics)
+		if errors.Is(err, errRejected) {
+			logger.Log().Errorf("Dropping queued batch: %v", err)
+			return nil
+		}
+		if err == nil {
+			m.reportLatency(time.Since(start))
+		}
+		return err
+	})
+}
+
+// drain replays the queue once there are no more jobs, so the batches are not left on disk on graceful shutdown.
+func (m *agent) drain(send func(metrics []model.Metrics) error) {
+	if m.Queue == nil || m.Queue.Len() == 0 {
+		return
+	}
+	if err := m.replay(send); err != nil {
+		logger.Log().Errorf("Cannot drain queue, %d batches are kept until the next start: %v", m.Queue.Len(), err)
+	}
+}
+
+func (m *agent) sendBatch(metrics []model.Metrics, send func(metrics []model.Metrics) error) error {
+	start := time.Now()
+	if err := send(metrics); err != nil {
+		return err
+	}
+	m.delivered(metrics)
+	m.reportLatency(time.Since(start))
+	return nil
+}
+
+// delivered subtracts PollCount of the delivered batch, so the polls counted since the batch was built are reported
+// with the next one.
+func (m *agent) delivered(metrics []model.Metrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, metric := range metrics {
+		if metric.MType == model.Counter && metric.ID == pollCount && metric.Delta != nil {
+			m.Counter -= *metric.Delta
+		}
+	}
+}
+
+// sendGRPC streams every batch over a single StreamMetrics call, which is kept open across report intervals.
+// The last metric of a batch carries the batch number and the batch is reported as sent only once the server has
+// acknowledged it, the server stores nothing of a batch it has not acknowledged. Send blocks while the server does
+// not keep up, so the stream flow control throttles the agent. A broken stream is reported as the batch error and
+// reopened with the next batch.
 func (m *agent) sendGRPC(ctx context.Context, wg *sync.WaitGroup, jobs <-chan []model.Metrics, results chan<- error) {
 	defer wg.Done()
+	stream := &batchStream{}
+	defer m.closeStream(stream)
+
+	send := func(metrics []model.Metrics) error {
+		err := m.streamBatch(ctx, stream, metrics)
+		if status.Code(err) == codes.InvalidArgument {
+			return fmt.Errorf("%w: %w", errRejected, err)
+		}
+		return err
+	}
 	for metrics := range jobs {
 		logger.Log().Info("Processing job with batch of metrics")
+		results <- m.deliver(metrics, send)
+	}
+	m.drain(send)
+}
 
-		req := pb.UpdateMetricsRequest{}
+// batchStream is the StreamMetrics call of a sending worker, nil until the first batch or after a failure.
+type batchStream struct {
+	stream pb.Metrics_StreamMetricsClient
+	cancel context.CancelFunc
+	// batch is the number of the last sent batch.
+	batch uint64
+}
 
-		for _, m := range metrics {
-			var mtype pb.Metric_MType
-			switch m.MType {
-			case model.Counter:
-				mtype = 1
-			case model.Gauge:
-				mtype = 2
-			}
-			req.Metrics = append(req.Metrics, &pb.Metric{
-				Id:    m.ID,
-				Mtype: mtype,
-				Delta: m.Delta,
-				Value: m.Value,
-			})
+func (s *batchStream) reset() {
+	s.cancel()
+	s.stream, s.cancel = nil, nil
+}
+
+// streamBatch sends the batch over the stream, opening it when needed, and waits for the server to acknowledge it.
+func (m *agent) streamBatch(ctx context.Context, s *batchStream, metrics []model.Metrics) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	if s.stream == nil {
+		streamCtx, cancel := context.WithCancel(ctx)
+		mdCtx := metadata.NewOutgoingContext(streamCtx, metadata.Pairs(model.XRealIPHeader, GetLocalIP()))
+		stream, err := m.GRPCClient.StreamMetrics(mdCtx, grpc.UseCompressor(grpc_gzip.Name))
+		if err != nil {
+			cancel()
+			return fmt.Errorf("cannot open metrics stream: %w", err)
 		}
+		s.stream, s.cancel = stream, cancel
+	}
+	if err := m.sendBatchMessages(s, metrics); err != nil {
+		s.reset()
+		return err
+	}
+	return nil
+}
 
-		var md = metadata.Pairs(model.XRealIPHeader, GetLocalIP())
+func (m *agent) sendBatchMessages(s *batchStream, metrics []model.Metrics) error {
+	s.batch++
+	for i, metric := range metrics {
+		msg := api.ToProto(&metric)
+		if i == len(metrics)-1 {
+			msg.Batch = s.batch
+		}
+		// the metric is encrypted before signing, the same as the HTTP payload
+		if m.cryptoKey != "" {
+			if err := interceptor.SealMessage(msg, m.cryptoKey); err != nil {
+				return err
+			}
+		}
 		if m.HashService != nil {
-			reqBytes, err := proto.Marshal(&req)
-			if err != nil {
-				results <- fmt.Errorf("issue marshalling grpc request object to bytes: %w", err)
-				continue
+			if err := interceptor.SignMessage(m.HashService, msg); err != nil {
+				return err
 			}
-			md.Set(model.HashSha256Header, m.HashService.Sha256sum(reqBytes))
 		}
-		mdCtx := metadata.NewOutgoingContext(ctx, md)
-
-		_, err := m.GRPCClient.UpdateMetrics(mdCtx, &req, grpc.UseCompressor(grpc_gzip.Name))
-		if err != nil {
-			results <- err
-			continue
+		// the actual reason of the failure is returned by Recv, Send returns io.EOF only
+		if err := s.stream.Send(msg); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
 		}
+	}
+
+	// the stream is aborted when the server does not acknowledge the batch in time
+	timer := time.AfterFunc(requestTimeoutSeconds*time.Second, s.cancel)
+	defer timer.Stop()
+	summary, err := s.stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return errors.New("metrics stream is closed without acknowledging the batch")
+	}
+	if err != nil {
+		return err
+	}
+	if summary.GetBatch() != s.batch {
+		return fmt.Errorf("server acknowledged batch %d instead of %d", summary.GetBatch(), s.batch)
+	}
+	return nil
+}
 
-		m.Counter = 0
-		results <- nil
+// closeStream closes the stream and waits for the server to store the remaining metrics.
+func (m *agent) closeStream(s *batchStream) {
+	if s.stream == nil {
+		return
+	}
+	defer s.reset()
+	if err := s.stream.CloseSend(); err != nil {
+		logger.Log().Errorf("Failed to close metrics stream: %v", err)
+		return
+	}
+	summary, err := s.stream.Recv()
+	if err != nil {
+		logger.Log().Errorf("Failed to close metrics stream: %v", err)
+		return
 	}
+	logger.Log().Infof("Metrics stream is closed, %d metrics sent, %d stored", summary.GetReceived(), summary.GetStored())
 }
 
 func (m *agent) sendHTTP(ctx context.Context, wg *sync.WaitGroup, jobs <-chan []model.Metrics, results chan<- error) {
 	defer wg.Done()
+	send := func(metrics []model.Metrics) error {
+		return m.postBatch(ctx, metrics)
+	}
 	for metrics := range jobs {
 		logger.Log().Info("Processing job with batch of metrics")
-		// Use encoder to pass autotests
-		var buf bytes.Buffer
-		gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
-		if err != nil {
-			results <- err
-			continue
-		}
-		encoder := json.NewEncoder(gz)
-		if err = encoder.Encode(metrics); err != nil {
-			results <- err
-			continue
-		}
-		if err = gz.Close(); err != nil {
-			results <- err
-			continue
-		}
-		if m.cryptoKey != "" {
-			encoded, err := service.EncryptData(buf.Bytes(), clientCert)
-			if err != nil {
-				results <- err
-				continue
-			}
-			buf.Reset()
-			buf.Write(encoded)
-		}
+		results <- m.deliver(metrics, send)
+	}
+	m.drain(send)
+}
 
-		var resp *resty.Response
-		request := m.HTTPClient.R().
-			SetHeader(model.XRealIPHeader, GetLocalIP()).
-			SetHeader("Content-Encoding", "gzip").
-			SetBody(buf.Bytes())
-		if m.HashService != nil {
-			request.SetHeader(model.HashSha256Header, m.HashService.Sha256sum(buf.Bytes()))
+// postBatch posts the batch to the server and retries it when the server is unreachable.
+func (m *agent) postBatch(ctx context.Context, metrics []model.Metrics) error {
+	// Use encoder to pass autotests
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(gz)
+	if err = encoder.Encode(metrics); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	if m.cryptoKey != "" {
+		encoded, err := service.EncryptData(buf.Bytes(), m.cryptoKey)
+		if err != nil {
+			return err
 		}
+		buf.Reset()
+		buf.Write(encoded)
+	}
 
-		for _, delay := range m.RetryDelays {
-			c, cancel := context.WithTimeout(ctx, requestTimeoutSeconds*time.Second)
-			resp, err = request.SetContext(c).Post("updates/")
-			cancel()
-			if err != nil {
-				logger.Log().Errorf("Failed to send request, retrying after %v...", delay)
-				time.Sleep(delay)
-				continue
-			}
-
-			break
-		}
+	var resp *resty.Response
+	request := m.HTTPClient.R().
+		SetHeader(model.XRealIPHeader, GetLocalIP()).
+		SetHeader("Content-Encoding", "gzip").
+		SetBody(buf.Bytes())
+	if m.HashService != nil {
+		request.SetHeader(model.HashSha256Header, m.HashService.Sha256sum(buf.Bytes()))
+	}
 
+	for _, delay := range m.RetryDelays {
+		c, cancel := context.WithTimeout(ctx, requestTimeoutSeconds*time.Second)
+		resp, err = request.SetContext(c).Post("updates/")
+		cancel()
 		if err != nil {
-			results <- err
+			logger.Log().Errorf("Failed to send request, retrying after %v...", delay)
+			time.Sleep(delay)
 			continue
 		}
 
-		if resp.StatusCode() == http.StatusOK {
-			m.Counter = 0
-		}
+		break
+	}
 
-		results <- nil
+	if err != nil {
+		return err
+	}
+	switch code := resp.StatusCode(); {
+	case code == http.StatusOK:
+		return nil
+	case code >= http.StatusBadRequest && code < http.StatusInternalServerError:
+		return fmt.Errorf("%w: status %d", errRejected, code)
+	default:
+		return fmt.Errorf("unexpected response status %d", code)
 	}
 }
 
+// reportLatency drops the already reported latency observations and records the latency of the last report,
+// so it is sent with the next batch.
+func (m *agent) reportLatency(latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Latency.Reset()
+	m.Latency.Observe(latency.Seconds())
+}
+
 func (m *agent) metrics() []model.Metrics {
 	var metrics []model.Metrics
 	m.mu.RLock()
 	for _, gauge := range m.Gauges {
 		metrics = append(metrics, gauge)
 	}
+	if m.Latency.Count > 0 {
+		metrics = append(metrics, *model.NewHistogram("ReportLatency", m.Latency.Clone()))
+	}
+	counter := m.Counter
 	m.mu.RUnlock()
-	metrics = append(metrics, *model.NewCounter("PollCount", &m.Counter))
+	metrics = append(metrics, *model.NewCounter(pollCount, &counter))
 	return metrics
 }
@@ -1,58 +1,91 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
-	"runtime"
-	"strconv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 
+	"github.com/itallix/go-metrics/internal/collector"
+	"github.com/itallix/go-metrics/internal/grpc/api"
+	"github.com/itallix/go-metrics/internal/grpc/interceptor"
+	pb "github.com/itallix/go-metrics/internal/grpc/proto"
 	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/queue"
+	"github.com/itallix/go-metrics/internal/service"
+	"github.com/itallix/go-metrics/internal/storage/memory"
 )
 
-func TestCollectRuntimeMetrics(t *testing.T) {
-	client := resty.New()
-	httpmock.ActivateNonDefault(client.GetClient())
-	agent, err := newAgent(client, nil, "", "")
-	require.NoError(t, err)
+type failingCollector struct{}
 
-	assert.Empty(t, agent.Gauges)
+func (failingCollector) Name() string {
+	return "failing"
+}
 
-	err = agent.collectRuntime()
-	require.NoError(t, err)
+func (failingCollector) Interval() time.Duration {
+	return time.Second
+}
 
-	for _, key := range RuntimeMetrics {
-		_, exists := agent.Gauges[key]
-		assert.Truef(t, exists, "Expected key %s is missing in the map", key)
-	}
-	assert.Equal(t, int64(1), agent.Counter)
+func (failingCollector) Collect(context.Context) ([]model.Metrics, error) {
+	return nil, errors.New("failed")
 }
 
-func TestCollectExtraMetrics(t *testing.T) {
-	client := resty.New()
-	httpmock.ActivateNonDefault(client.GetClient())
-	agent, err := newAgent(client, nil, "", "")
+func collectRuntime(t *testing.T, agent *agent) {
+	runtimeCollector, err := collector.NewRuntime(collector.Options{Interval: time.Second})
 	require.NoError(t, err)
-	assert.Empty(t, agent.Gauges)
-
-	err = agent.collectExtra()
+	metrics, err := runtimeCollector.Collect(context.Background())
 	require.NoError(t, err)
+	agent.store(runtimeCollector, metrics, nil)
+	agent.poll()
+}
+
+func TestStore(t *testing.T) {
+	agent := newAgent(nil, nil, "", "")
+	collectRuntime(t, agent)
 
-	for _, key := range []string{"TotalMemory", "FreeMemory"} {
+	for _, key := range collector.RuntimeMetrics {
 		_, exists := agent.Gauges[key]
 		assert.Truef(t, exists, "Expected key %s is missing in the map", key)
 	}
+	assert.Equal(t, int64(1), agent.Counter)
 
-	for i := 0; i < runtime.NumCPU(); i++ {
-		metricName := "CPUutilization" + strconv.Itoa(i)
-		_, exists := agent.Gauges[metricName]
-		assert.Truef(t, exists, "Expected key %s is missing in the map", metricName)
-	}
+	// PollCount counts poll intervals, not collections
+	agent.store(failingCollector{}, nil, nil)
+	assert.Equal(t, int64(1), agent.Counter)
+
+	// an error of one collector keeps the metrics of the others
+	value := 1.0
+	gauge := model.NewGauge("disk", &value)
+	gauge.Labels = model.Labels{"device": "sda"}
+	agent.store(failingCollector{}, []model.Metrics{*gauge}, errors.New("partial"))
+	agent.store(failingCollector{}, nil, errors.New("failed"))
+	assert.Equal(t, int64(1), agent.Counter)
+	assert.Contains(t, agent.Gauges, gauge.SeriesKey())
+	assert.Len(t, agent.Gauges, len(collector.RuntimeMetrics)+2)
+
+	// series missing from a successful collection are not reported anymore
+	other := model.NewGauge("disk", &value)
+	other.Labels = model.Labels{"device": "sdb"}
+	agent.store(failingCollector{}, []model.Metrics{*other}, nil)
+	agent.store(failingCollector{}, []model.Metrics{*gauge}, nil)
+	assert.NotContains(t, agent.Gauges, other.SeriesKey())
+	assert.Len(t, agent.Gauges, len(collector.RuntimeMetrics)+2)
 }
 
 func TestSendMetrics(t *testing.T) {
@@ -60,26 +93,210 @@ func TestSendMetrics(t *testing.T) {
 	httpmock.ActivateNonDefault(client.GetClient())
 	httpmock.RegisterResponder("POST", "/updates/",
 		httpmock.NewStringResponder(200, `{"status":"success"}`))
-	agent, err := newAgent(client, nil, "", "")
-	require.NoError(t, err)
+	agent := newAgent(client, nil, "", "")
 	assert.Empty(t, agent.Gauges)
-
-	err = agent.collectRuntime()
-	require.NoError(t, err)
+	collectRuntime(t, agent)
 
 	jobs := make(chan []model.Metrics, 1)
 	results := make(chan error, 1)
 
 	jobs <- agent.metrics()
+	// the poll counted after the batch is built is not part of it
+	agent.poll()
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go agent.send(context.Background(), &wg, jobs, results)
 	close(jobs)
 	wg.Wait()
 	close(results)
-	err = <-results
+	err := <-results
 	require.NoError(t, err)
 
 	info := httpmock.GetCallCountInfo()
 	assert.Equal(t, 1, info["POST /updates/"])
+	assert.Equal(t, int64(1), agent.Counter, "only the delivered polls are subtracted")
+
+	var latency *model.Metrics
+	for _, metric := range agent.metrics() {
+		if metric.ID == "ReportLatency" {
+			latency = &metric
+		}
+	}
+	require.NotNil(t, latency)
+	assert.Equal(t, model.Histogram, latency.MType)
+	assert.Equal(t, uint64(1), latency.Histogram.Count)
+}
+
+func TestSendMetricsQueue(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	agent := newAgent(client, nil, "", "")
+	q, err := queue.New(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	agent.Queue = q
+
+	var received []int
+	status := http.StatusServiceUnavailable
+	httpmock.RegisterResponder("POST", "/updates/", func(req *http.Request) (*http.Response, error) {
+		gz, err := gzip.NewReader(req.Body)
+		require.NoError(t, err)
+		var metrics []model.Metrics
+		require.NoError(t, json.NewDecoder(gz).Decode(&metrics))
+		received = append(received, len(metrics))
+		return httpmock.NewStringResponse(status, ""), nil
+	})
+
+	jobs := make(chan []model.Metrics)
+	results := make(chan error)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go agent.send(context.Background(), &wg, jobs, results)
+
+	// the batch is kept in the queue while the server is down
+	jobs <- []model.Metrics{{ID: "g0", MType: model.Gauge}}
+	require.Error(t, <-results)
+	assert.Equal(t, 1, agent.Queue.Len())
+
+	// the queued batch is delivered before the new one
+	status = http.StatusOK
+	jobs <- []model.Metrics{{ID: "g0", MType: model.Gauge}, {ID: "g1", MType: model.Gauge}}
+	require.NoError(t, <-results)
+	assert.Equal(t, 0, agent.Queue.Len())
+
+	// rejected batches are dropped
+	status = http.StatusBadRequest
+	jobs <- []model.Metrics{{ID: "g0", MType: model.Gauge}}
+	require.NoError(t, <-results)
+	assert.Equal(t, 0, agent.Queue.Len())
+
+	close(jobs)
+	wg.Wait()
+	assert.Equal(t, []int{1, 1, 2, 1}, received)
+}
+
+// dialBufconn serves the gRPC server on the in-memory listener and returns the client connected to it.
+func dialBufconn(t *testing.T, grpcServer *grpc.Server, lis *bufconn.Listener) *GRPCMetricsClient {
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	return &GRPCMetricsClient{MetricsClient: pb.NewMetricsClient(conn), conn: conn}
+}
+
+// failingStreamServer receives whole batches and then fails the stream instead of acknowledging them
+// while fail is set.
+type failingStreamServer struct {
+	pb.UnimplementedMetricsServer
+	mu       sync.Mutex
+	fail     bool
+	streams  int
+	received []int
+}
+
+func (srv *failingStreamServer) StreamMetrics(stream grpc.BidiStreamingServer[pb.Metric, pb.StreamSummary]) error {
+	srv.mu.Lock()
+	srv.streams++
+	srv.mu.Unlock()
+	var received int
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.Send(&pb.StreamSummary{})
+		}
+		if err != nil {
+			return err
+		}
+		received++
+		if msg.GetBatch() == 0 {
+			continue
+		}
+		srv.mu.Lock()
+		srv.received = append(srv.received, received)
+		fail := srv.fail
+		srv.mu.Unlock()
+		if fail {
+			return status.Error(codes.Unavailable, "storage is down")
+		}
+		received = 0
+		if err = stream.Send(&pb.StreamSummary{Batch: msg.GetBatch()}); err != nil {
+			return err
+		}
+	}
+}
+
+func TestSendMetricsGRPC(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	lis := bufconn.Listen(1 << 20)
+	// the agent signs and encrypts every streamed metric, so both interceptors must accept them
+	grpcServer := grpc.NewServer(grpc.ChainStreamInterceptor(
+		interceptor.VerifyHashStream(service.NewHashService("secret")),
+		interceptor.DecryptStream("../../test_data/server.pem"),
+	))
+	pb.RegisterMetricsServer(grpcServer, api.NewServer(s))
+	agent := newAgent(nil, dialBufconn(t, grpcServer, lis), "secret", "../../test_data/client.pem")
+	defer agent.GRPCClient.Close()
+	collectRuntime(t, agent)
+
+	// both batches go through the same stream, which is closed when there are no more jobs
+	jobs := make(chan []model.Metrics)
+	results := make(chan error)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go agent.send(ctx, &wg, jobs, results)
+	for i := 0; i < 2; i++ {
+		jobs <- agent.metrics()
+		require.NoError(t, <-results)
+	}
+	close(jobs)
+	wg.Wait()
+
+	counter := model.Metrics{ID: "PollCount", MType: model.Counter}
+	require.NoError(t, s.Read(ctx, &counter))
+	assert.Equal(t, int64(1), *counter.Delta)
+	gauge := model.Metrics{ID: "RandomValue", MType: model.Gauge}
+	require.NoError(t, s.Read(ctx, &gauge))
+}
+
+func TestSendMetricsGRPCQueue(t *testing.T) {
+	srv := &failingStreamServer{fail: true}
+	grpcServer := grpc.NewServer()
+	pb.RegisterMetricsServer(grpcServer, srv)
+	agent := newAgent(nil, dialBufconn(t, grpcServer, bufconn.Listen(1<<20)), "", "")
+	defer agent.GRPCClient.Close()
+	q, err := queue.New(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	agent.Queue = q
+
+	jobs := make(chan []model.Metrics)
+	results := make(chan error)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go agent.send(context.Background(), &wg, jobs, results)
+
+	// the whole batch is sent, but the server fails the stream instead of storing it, so the batch is kept queued
+	jobs <- []model.Metrics{{ID: "g0", MType: model.Gauge}}
+	require.Error(t, <-results)
+	assert.Equal(t, 1, agent.Queue.Len())
+
+	// the queued batch is removed only once the server acknowledges it
+	srv.mu.Lock()
+	srv.fail = false
+	srv.mu.Unlock()
+	jobs <- []model.Metrics{{ID: "g0", MType: model.Gauge}, {ID: "g1", MType: model.Gauge}}
+	require.NoError(t, <-results)
+	assert.Equal(t, 0, agent.Queue.Len())
+
+	close(jobs)
+	wg.Wait()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.Equal(t, []int{1, 1, 2}, srv.received)
+	assert.Equal(t, 2, srv.streams, "the failed stream is reopened once and kept open for the next batches")
 }
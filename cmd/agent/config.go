@@ -1,10 +1,15 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/caarlos0/env"
 
+	"github.com/itallix/go-metrics/internal/collector"
 	"github.com/itallix/go-metrics/internal/model"
 )
 
@@ -14,6 +19,10 @@ const (
 	defaultReportInterval = 10
 	defaultRateLimit      = 3
 	defaultSchema         = "http"
+	defaultGRPCTarget     = "localhost:" + model.GRPCPort
+	defaultQueueMaxSize   = 64 << 20
+	defaultQueueMaxAge    = 3600
+	defaultTopProcesses   = collector.DefaultTopProcesses
 )
 
 func parseConfig() (*model.AgentConfig, error) {
@@ -28,6 +37,21 @@ func parseConfig() (*model.AgentConfig, error) {
 	rateLimit := flag.Int("l", defaultRateLimit, "Max number of concurrent requests to the server")
 	cryptoKey := flag.String("crypto-key", "", "Path to public key that will be used for payload encryption")
 	schema := flag.String("schema", defaultSchema, "Communication protocol between agent and server")
+	grpcTarget := flag.String("grpc-target", defaultGRPCTarget, "gRPC target of the server, e.g. dns:///host:port")
+	latencyBuckets := flag.String("latency-buckets", "", "Comma-separated upper bounds of report latency buckets")
+	tlsCA := flag.String("tls-ca", "", "Path to the CA that the server certificate must be signed by")
+	tlsCert := flag.String("tls-cert", "", "Path to the TLS client certificate presented to the server")
+	tlsKey := flag.String("tls-key", "", "Path to the TLS client private key")
+	collectors := flag.String("collectors", "", "Comma-separated enabled collectors, e.g. runtime,system:5")
+	mountInclude := flag.String("mount-include", "", "Comma-separated patterns of reported mount points")
+	mountExclude := flag.String("mount-exclude", "", "Comma-separated patterns of excluded mount points")
+	interfaceInclude := flag.String("interface-include", "", "Comma-separated patterns of reported network interfaces")
+	interfaceExclude := flag.String("interface-exclude", "", "Comma-separated patterns of excluded network interfaces")
+	topProcesses := flag.Int("top-processes", defaultTopProcesses, "Number of processes reported by CPU and by memory")
+	runtimeAll := flag.Bool("runtime-metrics-all", false, "Report every supported Go runtime metric")
+	queueDir := flag.String("queue-dir", "", "Directory of the on-disk queue of undelivered batches")
+	queueMaxSize := flag.Int64("queue-max-size", defaultQueueMaxSize, "Max total size of the queue in bytes")
+	queueMaxAge := flag.Int("queue-max-age", defaultQueueMaxAge, "Max age of queued batches in seconds")
 	flag.Parse()
 
 	cfg := model.AgentConfig{
@@ -36,6 +60,10 @@ func parseConfig() (*model.AgentConfig, error) {
 		ReportInterval: defaultReportInterval,
 		RateLimit:      defaultRateLimit,
 		Schema:         defaultSchema,
+		GRPCTarget:     defaultGRPCTarget,
+		QueueMaxSize:   defaultQueueMaxSize,
+		QueueMaxAge:    defaultQueueMaxAge,
+		TopProcesses:   defaultTopProcesses,
 	}
 	if configPath != "" {
 		err := model.ParseFileConfig(configPath, &cfg)
@@ -64,8 +92,81 @@ func parseConfig() (*model.AgentConfig, error) {
 	if *schema != defaultSchema {
 		cfg.Schema = *schema
 	}
+	if *grpcTarget != defaultGRPCTarget {
+		cfg.GRPCTarget = *grpcTarget
+	}
+	if *latencyBuckets != "" {
+		buckets, err := parseBuckets(*latencyBuckets)
+		if err != nil {
+			return nil, err
+		}
+		cfg.LatencyBuckets = buckets
+	}
+	if *tlsCA != "" {
+		cfg.TLSCA = *tlsCA
+	}
+	if *tlsCert != "" {
+		cfg.TLSCert = *tlsCert
+	}
+	if *tlsKey != "" {
+		cfg.TLSKey = *tlsKey
+	}
+	if *collectors != "" {
+		cfg.Collectors = splitList(*collectors)
+	}
+	if *mountInclude != "" {
+		cfg.MountInclude = splitList(*mountInclude)
+	}
+	if *mountExclude != "" {
+		cfg.MountExclude = splitList(*mountExclude)
+	}
+	if *interfaceInclude != "" {
+		cfg.InterfaceInclude = splitList(*interfaceInclude)
+	}
+	if *interfaceExclude != "" {
+		cfg.InterfaceExclude = splitList(*interfaceExclude)
+	}
+	if *topProcesses != defaultTopProcesses {
+		cfg.TopProcesses = *topProcesses
+	}
+	if *runtimeAll {
+		cfg.RuntimeMetricsAll = true
+	}
+	if *queueDir != "" {
+		cfg.QueueDir = *queueDir
+	}
+	if *queueMaxSize != defaultQueueMaxSize {
+		cfg.QueueMaxSize = *queueMaxSize
+	}
+	if *queueMaxAge != defaultQueueMaxAge {
+		cfg.QueueMaxAge = *queueMaxAge
+	}
 	if err := env.Parse(&cfg); err != nil {
 		return nil, err
 	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, errors.New("both TLS client certificate and key must be set")
+	}
 	return &cfg, nil
 }
+
+func splitList(value string) []string {
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return parts
+}
+
+func parseBuckets(value string) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	buckets := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		bucket, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q: %w", part, err)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
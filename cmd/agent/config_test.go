@@ -19,6 +19,19 @@ func TestAgentConfig_Parse(t *testing.T) {
 		wantKey       string
 		wantRateLimit int
 		wantCryptoKey string
+		wantBuckets   []float64
+		wantTLSCA     string
+		wantTLSCert   string
+		wantTLSKey    string
+		wantTarget    string
+		wantQueueDir  string
+		wantQueueSize int64
+		wantQueueAge  int
+		wantCollector []string
+		wantMounts    []string
+		wantIfaces    []string
+		wantTop       int
+		wantAll       bool
 	}{
 		{
 			name:          "Default",
@@ -28,16 +41,38 @@ func TestAgentConfig_Parse(t *testing.T) {
 			wantKey:       "",
 			wantRateLimit: 3,
 			wantCryptoKey: "",
+			wantTarget:    "localhost:8081",
+			wantQueueSize: 64 << 20,
+			wantQueueAge:  3600,
+			wantTop:       5,
 		},
 		{
-			name:          "WithArgs",
-			giveArgs:      []string{"-a", "localhost:8081", "-p", "4", "-r", "20", "-k", "key", "-l", "5", "-crypto-key", "cryptoKey"},
+			name: "WithArgs",
+			giveArgs: []string{"-a", "localhost:8081", "-p", "4", "-r", "20", "-k", "key", "-l", "5", "-crypto-key", "cryptoKey",
+				"-latency-buckets", "0.1, 1,10", "-tls-ca", "ca.pem", "-tls-cert", "cert.pem",
+				"-tls-key", "key.pem", "-grpc-target", "dns:///metrics:8081", "-queue-dir", "/tmp/queue",
+				"-queue-max-size", "1024", "-queue-max-age", "60", "-collectors", "runtime,system:5",
+				"-mount-include", "/, /data/*", "-interface-exclude", "lo,docker*", "-top-processes", "3",
+				"-runtime-metrics-all"},
 			wantAddr:      "localhost:8081",
 			wantPoll:      4,
 			wantReport:    20,
 			wantKey:       "key",
 			wantRateLimit: 5,
 			wantCryptoKey: "cryptoKey",
+			wantBuckets:   []float64{0.1, 1, 10},
+			wantTLSCA:     "ca.pem",
+			wantTLSCert:   "cert.pem",
+			wantTLSKey:    "key.pem",
+			wantTarget:    "dns:///metrics:8081",
+			wantQueueDir:  "/tmp/queue",
+			wantQueueSize: 1024,
+			wantQueueAge:  60,
+			wantCollector: []string{"runtime", "system:5"},
+			wantMounts:    []string{"/", "/data/*"},
+			wantIfaces:    []string{"lo", "docker*"},
+			wantTop:       3,
+			wantAll:       true,
 		},
 	}
 
@@ -56,6 +91,19 @@ func TestAgentConfig_Parse(t *testing.T) {
 			assert.Equal(t, tt.wantKey, cfg.Key)
 			assert.Equal(t, tt.wantRateLimit, cfg.RateLimit)
 			assert.Equal(t, tt.wantCryptoKey, cfg.CryptoKey)
+			assert.Equal(t, tt.wantBuckets, cfg.LatencyBuckets)
+			assert.Equal(t, tt.wantTLSCA, cfg.TLSCA)
+			assert.Equal(t, tt.wantTLSCert, cfg.TLSCert)
+			assert.Equal(t, tt.wantTLSKey, cfg.TLSKey)
+			assert.Equal(t, tt.wantTarget, cfg.GRPCTarget)
+			assert.Equal(t, tt.wantQueueDir, cfg.QueueDir)
+			assert.Equal(t, tt.wantQueueSize, cfg.QueueMaxSize)
+			assert.Equal(t, tt.wantQueueAge, cfg.QueueMaxAge)
+			assert.Equal(t, tt.wantCollector, cfg.Collectors)
+			assert.Equal(t, tt.wantMounts, cfg.MountInclude)
+			assert.Equal(t, tt.wantIfaces, cfg.InterfaceExclude)
+			assert.Equal(t, tt.wantTop, cfg.TopProcesses)
+			assert.Equal(t, tt.wantAll, cfg.RuntimeMetricsAll)
 		})
 	}
 }
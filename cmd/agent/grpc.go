@@ -1,16 +1,17 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/retry"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 
 	pb "github.com/itallix/go-metrics/internal/grpc/proto"
-	"github.com/itallix/go-metrics/internal/model"
 )
 
 type GRPCMetricsClient struct {
@@ -18,9 +19,18 @@ type GRPCMetricsClient struct {
 	conn *grpc.ClientConn
 }
 
-func NewGrpcClient() (*GRPCMetricsClient, error) {
+// roundRobinConfig spreads calls over every address the target resolves to, e.g. dns:///metrics:8081.
+const roundRobinConfig = `{"loadBalancingConfig": [{"round_robin": {}}]}`
+
+// NewGrpcClient connects to the target over TLS when tlsConfig is set and over plain text otherwise.
+func NewGrpcClient(target string, tlsConfig *tls.Config) (*GRPCMetricsClient, error) {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
 	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultServiceConfig(roundRobinConfig),
 		grpc.WithUnaryInterceptor(
 			retry.UnaryClientInterceptor(
 				retry.WithMax(3),
@@ -29,7 +39,7 @@ func NewGrpcClient() (*GRPCMetricsClient, error) {
 			),
 		),
 	}
-	conn, err := grpc.NewClient(":"+model.GRPCPort, opts...)
+	conn, err := grpc.NewClient(target, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create a new grpc client: %w", err)
 	}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/itallix/go-metrics/internal/grpc/api"
+	pb "github.com/itallix/go-metrics/internal/grpc/proto"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+func TestNewGrpcClient_Target(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	pb.RegisterMetricsServer(grpcServer, api.NewServer(s))
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	defer grpcServer.Stop()
+
+	for _, target := range []string{lis.Addr().String(), "dns:///" + lis.Addr().String()} {
+		t.Run(target, func(t *testing.T) {
+			client, err := NewGrpcClient(target, nil)
+			require.NoError(t, err)
+			defer client.Close()
+
+			g := 1.5
+			_, err = client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{
+				Metrics: []*pb.Metric{api.ToProto(model.NewGauge("g0", &g))},
+			})
+			require.NoError(t, err)
+			metric, err := client.GetMetric(ctx, &pb.MetricKey{Id: "g0", Mtype: pb.Metric_M_TYPE_GAUGE})
+			require.NoError(t, err)
+			assert.InDelta(t, 1.5, metric.GetValue(), 0.0001)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"log"
 	"os"
 	"os/signal"
@@ -10,10 +11,11 @@ import (
 	"time"
 
 	"github.com/go-resty/resty/v2"
-	"golang.org/x/sync/errgroup"
 
+	"github.com/itallix/go-metrics/internal/collector"
 	"github.com/itallix/go-metrics/internal/logger"
 	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/queue"
 	"github.com/itallix/go-metrics/internal/service"
 )
 
@@ -46,8 +48,8 @@ func main() {
 	jobs := make(chan []model.Metrics, config.RateLimit)
 	results := make(chan error, config.RateLimit)
 
-	tickerPoll := time.NewTicker(time.Duration(config.PollInterval) * time.Second)
-	defer tickerPoll.Stop()
+	pollTicker := time.NewTicker(time.Duration(config.PollInterval) * time.Second)
+	defer pollTicker.Stop()
 	reportPoll := time.NewTicker(time.Duration(config.ReportInterval) * time.Second)
 	defer reportPoll.Stop()
 
@@ -55,19 +57,38 @@ func main() {
 		httpClient *resty.Client
 		grpcClient *GRPCMetricsClient
 	)
+	var tlsConfig *tls.Config
+	if config.TLSEnabled() {
+		tlsConfig, err = service.ClientTLSConfig(config.TLSCA, config.TLSCert, config.TLSKey)
+		if err != nil {
+			logger.Log().Fatalf("Cannot configure TLS: %v", err)
+		}
+	}
 	if config.Schema == "http" {
-		httpClient = resty.New().SetBaseURL("http://"+config.ServerURL).
-			SetHeader("Content-Type", "application/json")
+		scheme := "http://"
+		httpClient = resty.New().SetHeader("Content-Type", "application/json")
+		if tlsConfig != nil {
+			scheme = "https://"
+			httpClient.SetTLSClientConfig(tlsConfig)
+		}
+		httpClient.SetBaseURL(scheme + config.ServerURL)
 	} else if config.Schema == "grpc" {
-		client, err := NewGrpcClient()
+		client, err := NewGrpcClient(config.GRPCTarget, tlsConfig)
 		if err != nil {
 			logger.Log().Fatalf("Failed to create a new grpc client: %v", err)
 		}
 		grpcClient = client
 	}
-	metricsAgent, err := newAgent(httpClient, grpcClient, config.Key, config.CryptoKey)
-	if err != nil {
-		logger.Log().Fatalf("Failed to instantiate agent: %v", err)
+	metricsAgent := newAgent(httpClient, grpcClient, config.Key, config.CryptoKey)
+	if config.QueueDir != "" {
+		metricsAgent.Queue, err = queue.New(config.QueueDir, config.QueueMaxSize,
+			time.Duration(config.QueueMaxAge)*time.Second)
+		if err != nil {
+			logger.Log().Fatalf("Failed to open queue: %v", err)
+		}
+	}
+	if len(config.LatencyBuckets) > 0 {
+		metricsAgent.Latency = model.NewHistogramValue(config.LatencyBuckets)
 	}
 
 	var wg sync.WaitGroup
@@ -88,18 +109,24 @@ func main() {
 		}
 	}()
 
+	collectors, err := collector.Default().Build(config.Collectors, collector.Options{
+		Interval:     time.Duration(config.PollInterval) * time.Second,
+		Mounts:       collector.Filter{Include: config.MountInclude, Exclude: config.MountExclude},
+		Interfaces:   collector.Filter{Include: config.InterfaceInclude, Exclude: config.InterfaceExclude},
+		TopProcesses: config.TopProcesses,
+		RuntimeAll:   config.RuntimeMetricsAll,
+	})
+	if err != nil {
+		logger.Log().Fatalf("Failed to configure collectors: %v", err)
+	}
+	// every collector runs on its own schedule
+	go collector.Run(ctx, collectors, metricsAgent.store)
+
 	go func() {
 		for {
 			select {
-			case <-tickerPoll.C:
-				g := new(errgroup.Group)
-				g.Go(metricsAgent.collectRuntime)
-				g.Go(metricsAgent.collectExtra)
-
-				if err = g.Wait(); err != nil {
-					logger.Log().Errorf("Issue collecting metrics: %v", err)
-				}
-				logger.Log().Infof("Collected metrics: %v", metricsAgent.metrics())
+			case <-pollTicker.C:
+				metricsAgent.poll()
 			case <-reportPoll.C:
 				logger.Log().Info("Scheduling new job to send metrics...")
 				jobs <- metricsAgent.metrics()
@@ -121,7 +148,7 @@ func main() {
 		jobs <- metricsAgent.metrics()
 	}
 	close(jobs)
-	// waiting for all workers that send metrics to the server to finish
+	// waiting for all workers that send metrics to the server and drain the queue to finish
 	wg.Wait()
 	close(results)
 	if grpcClient != nil {
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"strings"
 
 	"github.com/caarlos0/env"
 
@@ -13,6 +16,13 @@ const (
 	defaultStoreInterval = 300
 	defaultFilePath      = "/tmp/metrics-db.json"
 	defaultRestore       = true
+	defaultRetention     = 3600
+	defaultHistorySize   = 1024
+	defaultGenerations   = 3
+	defaultBoltPath      = "/tmp/metrics.db"
+	defaultGRPCAddress   = "localhost:" + model.GRPCPort
+	defaultStatsDFlush   = 10
+	defaultMaxBodySize   = 32 << 20
 )
 
 func parseConfig() (*model.ServerConfig, error) {
@@ -28,6 +38,31 @@ func parseConfig() (*model.ServerConfig, error) {
 	key := flag.String("k", "", "Key that will be used to calculate hash")
 	cryptoKey := flag.String("crypto-key", "", "Private key that will be used to decrypt the request payload")
 	trustedSubnet := flag.String("t", "", "Whitelisted subnet in CIDR format")
+	grpcAddr := flag.String("grpc-address", defaultGRPCAddress, "Net address host:port of the gRPC server")
+	retention := flag.Int("retention", defaultRetention,
+		"How long to keep the history of metrics in seconds, 0 keeps it forever in every storage engine, "+
+			"the memory engine still keeps at most history-size samples per series")
+	historySize := flag.Int("history-size", defaultHistorySize, "Max number of samples kept per series in memory")
+	generations := flag.Int("g", defaultGenerations, "Number of snapshot generations kept in the file storage")
+	engine := flag.String("storage-engine", "", "Storage engine: memory, postgres or bolt")
+	boltPath := flag.String("bolt-path", defaultBoltPath, "Filepath of the bolt storage engine database")
+	tlsCert := flag.String("tls-cert", "", "Path to the TLS certificate of the server")
+	tlsKey := flag.String("tls-key", "", "Path to the TLS private key of the server")
+	tlsClientCA := flag.String("tls-client-ca", "", "Path to the CA that client certificates must be signed by")
+	statsDAddr := flag.String("statsd-address", "", "Net address host:port of the StatsD listener")
+	statsDFlush := flag.Int("statsd-flush-interval", defaultStatsDFlush, "StatsD flush interval in seconds")
+	influxCounters := flag.String("influx-counter-fields", "",
+		"Comma separated patterns of InfluxDB integer fields stored as counters")
+	graphiteAddr := flag.String("graphite-address", "", "Net address host:port of the Graphite plaintext listener")
+	graphitePickleAddr := flag.String("graphite-pickle-address", "",
+		"Net address host:port of the Graphite pickle listener")
+	graphiteTemplates := flag.String("graphite-templates", "",
+		"Semicolon separated templates mapping Graphite paths to metric names and labels")
+	graphiteRateLimit := flag.Int("graphite-rate-limit", 0,
+		"Max number of Graphite datapoints per second accepted from every connection, 0 means no limit")
+	maxBodySize := flag.Int64("max-body-size", defaultMaxBodySize,
+		"Max size in bytes of the request bodies accepted by the ingestion endpoints")
+	wal := flag.Bool("wal", false, "Whether server logs every update to the write-ahead log next to the file or not")
 	flag.Parse()
 
 	cfg := model.ServerConfig{
@@ -35,6 +70,15 @@ func parseConfig() (*model.ServerConfig, error) {
 		StoreInterval: defaultStoreInterval,
 		FilePath:      defaultFilePath,
 		Restore:       defaultRestore,
+
+		RetentionInterval: defaultRetention,
+		HistorySize:       defaultHistorySize,
+		StoreGenerations:  defaultGenerations,
+		BoltPath:          defaultBoltPath,
+		GRPCAddress:       defaultGRPCAddress,
+
+		StatsDFlushInterval: defaultStatsDFlush,
+		MaxBodySize:         defaultMaxBodySize,
 	}
 	if configPath != "" {
 		err := model.ParseFileConfig(configPath, &cfg)
@@ -66,8 +110,93 @@ func parseConfig() (*model.ServerConfig, error) {
 	if *trustedSubnet != "" {
 		cfg.TrustedSubnet = *trustedSubnet
 	}
+	if *grpcAddr != defaultGRPCAddress {
+		cfg.GRPCAddress = *grpcAddr
+	}
+	if *retention != defaultRetention {
+		cfg.RetentionInterval = *retention
+	}
+	if *historySize != defaultHistorySize {
+		cfg.HistorySize = *historySize
+	}
+	if *generations != defaultGenerations {
+		cfg.StoreGenerations = *generations
+	}
+	if *engine != "" {
+		cfg.StorageEngine = *engine
+	}
+	if *boltPath != defaultBoltPath {
+		cfg.BoltPath = *boltPath
+	}
+	if *wal {
+		cfg.WAL = *wal
+	}
+	if *tlsCert != "" {
+		cfg.TLSCert = *tlsCert
+	}
+	if *tlsKey != "" {
+		cfg.TLSKey = *tlsKey
+	}
+	if *tlsClientCA != "" {
+		cfg.TLSClientCA = *tlsClientCA
+	}
+	if *statsDAddr != "" {
+		cfg.StatsDAddress = *statsDAddr
+	}
+	if *statsDFlush != defaultStatsDFlush {
+		cfg.StatsDFlushInterval = *statsDFlush
+	}
+	if *influxCounters != "" {
+		cfg.InfluxCounterFields = splitList(*influxCounters, ",")
+	}
+	if *graphiteAddr != "" {
+		cfg.GraphiteAddress = *graphiteAddr
+	}
+	if *graphitePickleAddr != "" {
+		cfg.GraphitePickleAddress = *graphitePickleAddr
+	}
+	if *graphiteTemplates != "" {
+		cfg.GraphiteTemplates = splitList(*graphiteTemplates, ";")
+	}
+	if *graphiteRateLimit != 0 {
+		cfg.GraphiteRateLimit = *graphiteRateLimit
+	}
+	if *maxBodySize != defaultMaxBodySize {
+		cfg.MaxBodySize = *maxBodySize
+	}
 	if err := env.Parse(&cfg); err != nil {
 		return nil, err
 	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, errors.New("both TLS certificate and key must be set")
+	}
+	if cfg.TLSClientCA != "" && !cfg.TLSEnabled() {
+		return nil, errors.New("client CA requires TLS certificate and key")
+	}
+	if cfg.StatsDAddress != "" && cfg.StatsDFlushInterval <= 0 {
+		return nil, errors.New("StatsD flush interval must be positive")
+	}
+	if cfg.RetentionInterval < 0 {
+		return nil, errors.New("retention must not be negative")
+	}
+	if cfg.MaxBodySize <= 0 {
+		return nil, errors.New("max body size must be positive")
+	}
+	if cfg.GraphiteRateLimit < 0 {
+		return nil, errors.New("Graphite rate limit must not be negative")
+	}
+	switch cfg.Engine() {
+	case model.EngineMemory, model.EnginePostgres, model.EngineBolt:
+	default:
+		return nil, fmt.Errorf("unknown storage engine %q", cfg.StorageEngine)
+	}
 	return &cfg, nil
 }
+
+func splitList(value, sep string) []string {
+	parts := strings.Split(value, sep)
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return parts
+}
@@ -21,6 +21,24 @@ func TestServerConfig_Parse(t *testing.T) {
 		wantDSN           string
 		wantCryptoKey     string
 		wantTrustedSubnet string
+		wantRetention     int
+		wantHistorySize   int
+		wantWAL           bool
+		wantGenerations   int
+		wantEngine        string
+		wantBoltPath      string
+		wantTLSCert       string
+		wantTLSKey        string
+		wantTLSClientCA   string
+		wantGRPCAddress   string
+		wantStatsDAddress string
+		wantStatsDFlush   int
+		wantInflux        []string
+		wantGraphite      string
+		wantPickle        string
+		wantTemplates     []string
+		wantRateLimit     int
+		wantMaxBodySize   int64
 	}{
 		{
 			name:              "Default",
@@ -32,11 +50,27 @@ func TestServerConfig_Parse(t *testing.T) {
 			wantDSN:           "",
 			wantCryptoKey:     "",
 			wantTrustedSubnet: "",
+			wantRetention:     3600,
+			wantHistorySize:   1024,
+			wantGenerations:   3,
+			wantEngine:        "memory",
+			wantBoltPath:      "/tmp/metrics.db",
+			wantGRPCAddress:   "localhost:8081",
+			wantStatsDFlush:   10,
+			wantMaxBodySize:   32 << 20,
 		},
 		{
 			name: "WithArgs",
 			giveArgs: []string{"-a", "localhost:8081", "-i", "400", "-f", "filepath", "-d", "dsn",
-				"-k", "key", "-crypto-key", "cryptoKey", "-t", "192.168.2.0/24"},
+				"-k", "key", "-crypto-key", "cryptoKey", "-t", "192.168.2.0/24", "-retention", "60",
+				"-history-size", "10", "-wal", "-g", "5",
+				"-storage-engine", "bolt", "-bolt-path", "metrics.db",
+				"-tls-cert", "cert.pem", "-tls-key", "key.pem", "-tls-client-ca", "ca.pem",
+				"-grpc-address", ":9091", "-statsd-address", ":8125", "-statsd-flush-interval", "1",
+				"-influx-counter-fields", "net_bytes_*, diskio_*",
+				"-graphite-address", ":2003", "-graphite-pickle-address", ":2004",
+				"-graphite-templates", "servers.* .host.measurement* dc=eu,env=prod; measurement*",
+				"-graphite-rate-limit", "100", "-max-body-size", "1024"},
 			wantAddr:          "localhost:8081",
 			wantFilepath:      "filepath",
 			wantStoreInterval: 400,
@@ -45,6 +79,24 @@ func TestServerConfig_Parse(t *testing.T) {
 			wantDSN:           "dsn",
 			wantCryptoKey:     "cryptoKey",
 			wantTrustedSubnet: "192.168.2.0/24",
+			wantRetention:     60,
+			wantHistorySize:   10,
+			wantWAL:           true,
+			wantGenerations:   5,
+			wantEngine:        "bolt",
+			wantBoltPath:      "metrics.db",
+			wantTLSCert:       "cert.pem",
+			wantTLSKey:        "key.pem",
+			wantTLSClientCA:   "ca.pem",
+			wantGRPCAddress:   ":9091",
+			wantStatsDAddress: ":8125",
+			wantStatsDFlush:   1,
+			wantInflux:        []string{"net_bytes_*", "diskio_*"},
+			wantGraphite:      ":2003",
+			wantPickle:        ":2004",
+			wantTemplates:     []string{"servers.* .host.measurement* dc=eu,env=prod", "measurement*"},
+			wantRateLimit:     100,
+			wantMaxBodySize:   1024,
 		},
 	}
 
@@ -65,6 +117,24 @@ func TestServerConfig_Parse(t *testing.T) {
 			assert.Equal(t, tt.wantDSN, cfg.DatabaseDSN)
 			assert.Equal(t, tt.wantCryptoKey, cfg.CryptoKey)
 			assert.Equal(t, tt.wantTrustedSubnet, cfg.TrustedSubnet)
+			assert.Equal(t, tt.wantRetention, cfg.RetentionInterval)
+			assert.Equal(t, tt.wantHistorySize, cfg.HistorySize)
+			assert.Equal(t, tt.wantWAL, cfg.WAL)
+			assert.Equal(t, tt.wantGenerations, cfg.StoreGenerations)
+			assert.Equal(t, tt.wantEngine, cfg.Engine())
+			assert.Equal(t, tt.wantBoltPath, cfg.BoltPath)
+			assert.Equal(t, tt.wantTLSCert, cfg.TLSCert)
+			assert.Equal(t, tt.wantTLSKey, cfg.TLSKey)
+			assert.Equal(t, tt.wantTLSClientCA, cfg.TLSClientCA)
+			assert.Equal(t, tt.wantGRPCAddress, cfg.GRPCAddress)
+			assert.Equal(t, tt.wantStatsDAddress, cfg.StatsDAddress)
+			assert.Equal(t, tt.wantStatsDFlush, cfg.StatsDFlushInterval)
+			assert.Equal(t, tt.wantInflux, cfg.InfluxCounterFields)
+			assert.Equal(t, tt.wantGraphite, cfg.GraphiteAddress)
+			assert.Equal(t, tt.wantPickle, cfg.GraphitePickleAddress)
+			assert.Equal(t, tt.wantTemplates, cfg.GraphiteTemplates)
+			assert.Equal(t, tt.wantRateLimit, cfg.GraphiteRateLimit)
+			assert.Equal(t, tt.wantMaxBodySize, cfg.MaxBodySize)
 		})
 	}
 }
@@ -6,7 +6,6 @@ import (
 	"log"
 	"net"
 	"net/http"
-	"net/netip"
 	"os"
 	"os/signal"
 	"sync"
@@ -16,18 +15,26 @@ import (
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
-	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/realip"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 
 	"github.com/itallix/go-metrics/internal/controller"
 	"github.com/itallix/go-metrics/internal/grpc/api"
+	"github.com/itallix/go-metrics/internal/grpc/interceptor"
 	pb "github.com/itallix/go-metrics/internal/grpc/proto"
+	"github.com/itallix/go-metrics/internal/ingest/graphite"
+	"github.com/itallix/go-metrics/internal/ingest/influx"
+	"github.com/itallix/go-metrics/internal/ingest/otlp"
+	"github.com/itallix/go-metrics/internal/ingest/remotewrite"
+	"github.com/itallix/go-metrics/internal/ingest/statsd"
 	"github.com/itallix/go-metrics/internal/logger"
 	"github.com/itallix/go-metrics/internal/middleware"
 	"github.com/itallix/go-metrics/internal/model"
 	"github.com/itallix/go-metrics/internal/service"
 	"github.com/itallix/go-metrics/internal/storage"
+	"github.com/itallix/go-metrics/internal/storage/bolt"
 	"github.com/itallix/go-metrics/internal/storage/db"
 	"github.com/itallix/go-metrics/internal/storage/memory"
 
@@ -48,13 +55,14 @@ var (
 	buildCommit  string
 )
 
-func startGrpcServer(grpcServer *grpc.Server, storage storage.Storage, hasher service.HashService) {
-	grpcServerAddr := "localhost:" + model.GRPCPort
+func startGrpcServer(grpcServer *grpc.Server, grpcServerAddr string, storage storage.Storage, hub *storage.Hub,
+	otlpWriter *otlp.Writer) {
 	lis, err := net.Listen("tcp", grpcServerAddr)
 	if err != nil {
 		logger.Log().Fatalf("failed to run gRPC server: %v", err)
 	}
-	pb.RegisterMetricsServer(grpcServer, api.NewServer(storage, hasher))
+	pb.RegisterMetricsServer(grpcServer, api.NewServer(storage).WithHub(hub))
+	colmetricspb.RegisterMetricsServiceServer(grpcServer, otlpWriter)
 	reflection.Register(grpcServer)
 	logger.Log().Infof("GRPC server is starting on %s...", grpcServerAddr)
 	if err := grpcServer.Serve(lis); err != nil {
@@ -74,14 +82,28 @@ func main() {
 
 	service.PrintBuildInfo(buildVersion, buildDate, buildCommit, os.Stdout)
 
+	if len(os.Args) > 1 && os.Args[1] == migrateCommand {
+		args, flags := splitMigrateArgs(os.Args[2:])
+		os.Args = append([]string{os.Args[0]}, flags...)
+		migrateConfig, err := parseConfig()
+		if err != nil {
+			logger.Log().Fatalf("Can't parse flags: %v", err)
+		}
+		if err = runMigrate(context.Background(), migrateConfig, args, os.Stdout); err != nil {
+			logger.Log().Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	serverConfig, err := parseConfig()
 	if err != nil {
-		logger.Log().Errorf("Can't parse flags: %v", err.Error())
+		logger.Log().Fatalf("Can't parse flags: %v", err)
 	}
 
 	router := gin.New()
 	router.Use(gin.Recovery())
 	router.Use(middleware.LoggerWithZap(logger.Log()))
+	router.Use(middleware.ClientIdentity())
 	if serverConfig.TrustedSubnet != "" {
 		router.Use(middleware.CheckIPAddr(serverConfig.TrustedSubnet))
 	}
@@ -90,39 +112,67 @@ func main() {
 		hashService = service.NewHashService(serverConfig.Key)
 		router.Use(middleware.VerifyHash(hashService))
 	}
+	// the payload is decrypted before it is decompressed, the ingest endpoints serve third party clients
+	// which cannot encrypt it
+	payload := []gin.HandlerFunc{gzip.Gzip(gzip.BestCompression), middleware.GzipDecompress()}
+	api := router.Group("/")
 	if serverConfig.CryptoKey != "" {
-		router.Use(middleware.DecryptMiddleware(serverConfig.CryptoKey))
+		api.Use(middleware.DecryptMiddleware(serverConfig.CryptoKey))
 	}
-	router.Use(gzip.Gzip(gzip.BestCompression))
-	router.Use(middleware.GzipDecompress())
+	api.Use(payload...)
+	ingestAPI := router.Group("/", payload...)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	var (
 		mStorage storage.Storage
 		wg       sync.WaitGroup
 	)
-	if serverConfig.DatabaseDSN != "" {
-		mStorage, err = db.NewPgStorage(ctx, serverConfig.DatabaseDSN)
-		if err != nil {
-			logger.Log().Errorf("Cannot instantiate DB: %v", err)
-			mStorage = memory.NewMemStorage(ctx, &wg, memory.NewConfig(serverConfig.FilePath, serverConfig.StoreInterval,
-				serverConfig.Restore))
+	retention := time.Duration(serverConfig.RetentionInterval) * time.Second
+	hub := storage.NewHub()
+	switch serverConfig.Engine() {
+	case model.EnginePostgres:
+		pgStorage, pgErr := db.NewPgStorage(ctx, serverConfig.DatabaseDSN, retention)
+		if pgErr != nil {
+			logger.Log().Errorf("Cannot instantiate DB: %v", pgErr)
+		} else {
+			mStorage = pgStorage.WithHub(hub)
+		}
+	case model.EngineBolt:
+		boltStorage, boltErr := bolt.NewBoltStorage(serverConfig.BoltPath, retention)
+		if boltErr != nil {
+			logger.Log().Errorf("Cannot open bolt storage: %v", boltErr)
+		} else {
+			mStorage = boltStorage.WithHub(hub)
 		}
 	}
 	if mStorage == nil {
-		mStorage = memory.NewMemStorage(ctx, &wg, memory.NewConfig(serverConfig.FilePath, serverConfig.StoreInterval,
-			serverConfig.Restore))
+		memConfig := memory.NewConfig(serverConfig.FilePath, serverConfig.StoreInterval, serverConfig.Restore).
+			WithHistory(serverConfig.HistorySize, retention).
+			WithWAL(serverConfig.WAL).
+			WithGenerations(serverConfig.StoreGenerations)
+		mStorage = memory.NewMemStorage(ctx, &wg, memConfig).WithHub(hub)
 	}
 	defer mStorage.Close()
-	metricController := controller.NewMetricController(mStorage)
-
-	router.GET("/", metricController.ListMetrics)
-	router.POST("/update/", metricController.UpdateOne)
-	router.POST("/updates/", metricController.UpdateBatch)
-	router.POST("/value/", metricController.GetMetric)
-	router.POST("/update/:metricType/:metricName/:metricValue", metricController.UpdateMetricQuery)
-	router.GET("/value/:metricType/:metricName", metricController.GetMetricQuery)
-	router.GET("/ping", func(c *gin.Context) {
+	influxWriter, err := influx.NewWriter(mStorage, serverConfig.InfluxCounterFields)
+	if err != nil {
+		logger.Log().Fatalf("Cannot configure influx writer: %v", err)
+	}
+	otlpWriter := otlp.NewWriter(mStorage)
+	metricController := controller.NewMetricController(mStorage).
+		WithMaxBodySize(serverConfig.MaxBodySize).
+		WithInfluxWriter(influxWriter).
+		WithRemoteWriter(remotewrite.NewWriter(mStorage)).
+		WithOTLPWriter(otlpWriter)
+
+	api.GET("/", metricController.ListMetrics)
+	api.GET("/metrics", metricController.PrometheusMetrics)
+	api.GET("/api/v1/range", metricController.RangeQuery)
+	api.POST("/update/", metricController.UpdateOne)
+	api.POST("/updates/", metricController.UpdateBatch)
+	api.POST("/value/", metricController.GetMetric)
+	api.POST("/update/:metricType/:metricName/:metricValue", metricController.UpdateMetricQuery)
+	api.GET("/value/:metricType/:metricName", metricController.GetMetricQuery)
+	api.GET("/ping", func(c *gin.Context) {
 		if mStorage.Ping(c.Request.Context()) {
 			c.Status(http.StatusOK)
 			return
@@ -130,6 +180,10 @@ func main() {
 		_ = c.AbortWithError(http.StatusInternalServerError, errors.New("internal server error"))
 	})
 	pprof.Register(router)
+	ingestAPI.POST("/write", metricController.InfluxWrite)
+	ingestAPI.POST("/api/v2/write", metricController.InfluxWrite)
+	ingestAPI.POST("/api/v1/write", metricController.RemoteWrite)
+	ingestAPI.POST("/v1/metrics", metricController.OTLPMetrics)
 
 	server := &http.Server{
 		Addr:         serverConfig.Address,
@@ -139,34 +193,83 @@ func main() {
 		IdleTimeout:  IdleTimeoutSeconds * time.Second,
 	}
 
-	var grpcServer *grpc.Server
-	if serverConfig.TrustedSubnet != "" {
-		trustedPeers := []netip.Prefix{
-			netip.MustParsePrefix(serverConfig.TrustedSubnet),
+	var grpcOpts []grpc.ServerOption
+	if serverConfig.TLSEnabled() {
+		tlsConfig, tlsErr := service.ServerTLSConfig(serverConfig.TLSCert, serverConfig.TLSKey,
+			serverConfig.TLSClientCA)
+		if tlsErr != nil {
+			logger.Log().Fatalf("Cannot configure TLS: %v", tlsErr)
 		}
-		opts := []realip.Option{
-			realip.WithTrustedPeers(trustedPeers),
-			realip.WithHeaders([]string{model.XRealIPHeader}),
+		server.TLSConfig = tlsConfig
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	// the interceptors run in the same order as the gin middlewares
+	var (
+		unaryInterceptors  []grpc.UnaryServerInterceptor
+		streamInterceptors []grpc.StreamServerInterceptor
+	)
+	if serverConfig.TrustedSubnet != "" {
+		unaryInterceptors = append(unaryInterceptors, interceptor.TrustedSubnetUnary(serverConfig.TrustedSubnet))
+		streamInterceptors = append(streamInterceptors, interceptor.TrustedSubnetStream(serverConfig.TrustedSubnet))
+	}
+	if hashService != nil {
+		unaryInterceptors = append(unaryInterceptors, interceptor.VerifyHashUnary(hashService))
+		streamInterceptors = append(streamInterceptors, interceptor.VerifyHashStream(hashService))
+	}
+	if serverConfig.CryptoKey != "" {
+		unaryInterceptors = append(unaryInterceptors, interceptor.DecryptUnary(serverConfig.CryptoKey))
+		streamInterceptors = append(streamInterceptors, interceptor.DecryptStream(serverConfig.CryptoKey))
+	}
+	grpcOpts = append(grpcOpts,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
+	grpcServer := grpc.NewServer(grpcOpts...)
+	go startGrpcServer(grpcServer, serverConfig.GRPCAddress, mStorage, hub, otlpWriter)
+
+	// listeners are stopped before the storage, so their last flush is persisted
+	ingestCtx, stopIngest := context.WithCancel(ctx)
+	var ingestWg sync.WaitGroup
+	if serverConfig.StatsDAddress != "" {
+		statsDServer := statsd.NewServer(mStorage, time.Duration(serverConfig.StatsDFlushInterval)*time.Second)
+		ingestWg.Add(1)
+		go func() {
+			defer ingestWg.Done()
+			if err := statsDServer.ListenAndServe(ingestCtx, serverConfig.StatsDAddress); err != nil {
+				logger.Log().Errorf("StatsD listener failed: %v", err)
+			}
+		}()
+	}
+	if serverConfig.GraphiteAddress != "" || serverConfig.GraphitePickleAddress != "" {
+		templates, err := graphite.ParseTemplates(serverConfig.GraphiteTemplates)
+		if err != nil {
+			logger.Log().Fatalf("Cannot parse graphite templates: %v", err)
 		}
-		grpcServer = grpc.NewServer(
-			grpc.ChainUnaryInterceptor(
-				realip.UnaryServerInterceptorOpts(opts...),
-			),
-		)
-	} else {
-		grpcServer = grpc.NewServer()
+		graphiteServer := graphite.NewServer(mStorage, templates).WithRateLimit(serverConfig.GraphiteRateLimit)
+		ingestWg.Add(1)
+		go func() {
+			defer ingestWg.Done()
+			err := graphiteServer.ListenAndServe(ingestCtx, serverConfig.GraphiteAddress,
+				serverConfig.GraphitePickleAddress)
+			if err != nil {
+				logger.Log().Errorf("Graphite listener failed: %v", err)
+			}
+		}()
 	}
-	go startGrpcServer(grpcServer, mStorage, hashService)
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
 	go func() {
 		<-quit
 		logger.Log().Info("Shutting down servers gracefully...")
+		stopIngest()
+		ingestWg.Wait()
 		cancel()
 		wg.Wait()
 
 		logger.Log().Info("Stopping gRPC server...")
+		// watch streams never end on their own, closing the hub lets the graceful stop finish
+		hub.Close()
 		grpcServer.GracefulStop()
 
 		logger.Log().Info("Stopping HTTP server...")
@@ -178,7 +281,13 @@ func main() {
 	}()
 
 	logger.Log().Infof("Server is starting on %s...", serverConfig.Address)
-	if err = server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if server.TLSConfig != nil {
+		// the certificate is already loaded into the TLS config
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		logger.Log().Fatalf("Error starting server: %v", err)
 	}
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/db"
+)
+
+// migrateCommand is the subcommand managing the database schema:
+//
+//	server migrate up|down [steps]|status [flags]
+//
+// The database is taken from the -d flag or DATABASE_DSN like in the server mode.
+const migrateCommand = "migrate"
+
+const migrateUsage = "usage: migrate up|down [steps]|status"
+
+// splitMigrateArgs separates the positional arguments of the subcommand from the flags following them.
+func splitMigrateArgs(args []string) ([]string, []string) {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+func runMigrate(ctx context.Context, cfg *model.ServerConfig, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if cfg.DatabaseDSN == "" {
+		return errors.New("database connection string is required to run migrations")
+	}
+	steps := 1
+	if args[0] == "down" && len(args) > 1 {
+		var err error
+		if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+			return fmt.Errorf("invalid number of steps %q", args[1])
+		}
+	}
+
+	c, cancel := context.WithTimeout(ctx, db.MigrationTimeout)
+	defer cancel()
+	pool, err := pgxpool.New(c, cfg.DatabaseDSN)
+	if err != nil {
+		return fmt.Errorf("failed to initialize a connection pool: %w", err)
+	}
+	defer pool.Close()
+	migrator, err := db.NewMigrator(pool)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(c)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%d migrations applied\n", applied)
+		return err
+	case "down":
+		reverted, err := migrator.Down(c, steps)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%d migrations reverted\n", reverted)
+		return err
+	case "status":
+		statuses, err := migrator.Status(c)
+		if err != nil {
+			return err
+		}
+		return printMigrationStatus(out, statuses)
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func printMigrationStatus(out io.Writer, statuses []db.MigrationStatus) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, status := range statuses {
+		state := "pending"
+		switch {
+		case status.Unknown:
+			state = "applied by a newer version at " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		case status.AppliedAt != nil:
+			state = "applied at " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, state)
+	}
+	return w.Flush()
+}
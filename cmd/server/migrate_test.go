@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/db"
+)
+
+func TestSplitMigrateArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		giveArgs       []string
+		wantPositional []string
+		wantFlags      []string
+	}{
+		{
+			name:           "WithFlags",
+			giveArgs:       []string{"down", "2", "-d", "dsn"},
+			wantPositional: []string{"down", "2"},
+			wantFlags:      []string{"-d", "dsn"},
+		},
+		{
+			name:           "NoFlags",
+			giveArgs:       []string{"status"},
+			wantPositional: []string{"status"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			positional, flags := splitMigrateArgs(tt.giveArgs)
+			assert.Equal(t, tt.wantPositional, positional)
+			assert.Equal(t, tt.wantFlags, flags)
+		})
+	}
+}
+
+func TestRunMigrate_InvalidArgs(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+	require.Error(t, runMigrate(ctx, &model.ServerConfig{DatabaseDSN: "dsn"}, nil, &out))
+	require.Error(t, runMigrate(ctx, &model.ServerConfig{}, []string{"up"}, &out))
+	require.Error(t, runMigrate(ctx, &model.ServerConfig{DatabaseDSN: "dsn"}, []string{"down", "-1"}, &out))
+}
+
+func TestPrintMigrationStatus(t *testing.T) {
+	appliedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var out bytes.Buffer
+	require.NoError(t, printMigrationStatus(&out, []db.MigrationStatus{
+		{Version: 1, Name: "init", AppliedAt: &appliedAt},
+		{Version: 2, Name: "labels"},
+	}))
+	assert.Equal(t, `VERSION  NAME    STATUS
+1        init    applied at 2024-01-02 03:04:05
+2        labels  pending
+`, out.String())
+}
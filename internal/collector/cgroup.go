@@ -0,0 +1,257 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// CgroupName is the name of the container resources collector.
+const CgroupName = "cgroup"
+
+const (
+	cgroupRoot      = "/sys/fs/cgroup"
+	procSelfCgroup  = "/proc/self/cgroup"
+	cgroupUnlimited = "max"
+)
+
+// Cgroup reports the resources of the cgroup v2 the agent runs in: memory usage and limit, CPU usage,
+// limit and throttling, number of pids and IO pressure. Outside a container, or when the unified hierarchy is
+// not mounted, there is no cgroup with resource limits, so nothing is reported and the system collector
+// stays the source of memory and CPU metrics.
+type Cgroup struct {
+	interval time.Duration
+	// dir is the cgroup directory of the agent, empty when cgroup v2 is not available.
+	dir string
+}
+
+func NewCgroup(opts Options) (Collector, error) {
+	return newCgroup(opts.Interval, cgroupRoot, procSelfCgroup), nil
+}
+
+func newCgroup(interval time.Duration, root, procCgroup string) *Cgroup {
+	dir, err := detectCgroup(root, procCgroup)
+	if err != nil {
+		logger.Log().Infof("Container metrics are not collected: %v", err)
+	}
+	return &Cgroup{interval: interval, dir: dir}
+}
+
+// detectCgroup returns the cgroup v2 directory of the current process. In a container with its own cgroup
+// namespace the path in procCgroup is "/", so the directory is the mounted root itself.
+func detectCgroup(root, procCgroup string) (string, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not mounted at %s", root)
+	}
+	content, err := os.ReadFile(procCgroup)
+	if err != nil {
+		return "", fmt.Errorf("cannot read cgroup of the process: %w", err)
+	}
+	var path string
+	for _, line := range strings.Split(string(content), "\n") {
+		// the unified hierarchy entry has the "0::<path>" format
+		if p, ok := strings.CutPrefix(line, "0::"); ok {
+			path = p
+			break
+		}
+	}
+	dir := filepath.Join(root, path)
+	// the root cgroup has no resource limits and its usage is the usage of the whole host
+	if _, err = os.Stat(filepath.Join(dir, "memory.current")); err != nil {
+		return "", fmt.Errorf("process is not in a cgroup with resource accounting: %s", dir)
+	}
+	return dir, nil
+}
+
+func (c *Cgroup) Name() string {
+	return CgroupName
+}
+
+func (c *Cgroup) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect skips the files of controllers that are not enabled for the cgroup.
+func (c *Cgroup) Collect(context.Context) ([]model.Metrics, error) {
+	if c.dir == "" {
+		return nil, nil
+	}
+	var (
+		metrics []model.Metrics
+		errs    []error
+	)
+	add := func(collect func() ([]model.Metrics, error)) {
+		collected, err := collect()
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		metrics = append(metrics, collected...)
+	}
+	add(c.memory)
+	add(c.cpu)
+	add(c.cpuLimit)
+	add(c.pids)
+	add(c.ioPressure)
+	return metrics, errors.Join(errs...)
+}
+
+func (c *Cgroup) memory() ([]model.Metrics, error) {
+	usage, err := c.readValue("memory.current")
+	if err != nil {
+		return nil, err
+	}
+	metrics := []model.Metrics{gauge("ContainerMemoryUsage", usage, nil)}
+	limit, err := c.readValue("memory.max")
+	if err != nil {
+		return metrics, err
+	}
+	if limit >= 0 {
+		metrics = append(metrics, gauge("ContainerMemoryLimit", limit, nil))
+	}
+	return metrics, nil
+}
+
+func (c *Cgroup) cpu() ([]model.Metrics, error) {
+	stat, err := c.readKeyed("cpu.stat")
+	if err != nil {
+		return nil, err
+	}
+	metrics := []model.Metrics{gauge("ContainerCPUUsageSeconds", stat["usage_usec"]/1e6, nil)}
+	// throttling stats are present only when the cpu controller is enabled
+	if periods, ok := stat["nr_periods"]; ok {
+		metrics = append(metrics,
+			gauge("ContainerCPUPeriods", periods, nil),
+			gauge("ContainerCPUThrottledPeriods", stat["nr_throttled"], nil),
+			gauge("ContainerCPUThrottledSeconds", stat["throttled_usec"]/1e6, nil),
+		)
+	}
+	return metrics, nil
+}
+
+// cpuLimit reports the CPU quota in cores, e.g. 0.5 for "50000 100000".
+func (c *Cgroup) cpuLimit() ([]model.Metrics, error) {
+	content, err := c.read("cpu.max")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(content)
+	if len(fields) != 2 || fields[0] == cgroupUnlimited {
+		return nil, nil
+	}
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpu.max: %w", err)
+	}
+	period, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || period == 0 {
+		return nil, fmt.Errorf("invalid cpu.max period: %q", fields[1])
+	}
+	return []model.Metrics{gauge("ContainerCPULimit", quota/period, nil)}, nil
+}
+
+func (c *Cgroup) pids() ([]model.Metrics, error) {
+	current, err := c.readValue("pids.current")
+	if err != nil {
+		return nil, err
+	}
+	metrics := []model.Metrics{gauge("ContainerPids", current, nil)}
+	limit, err := c.readValue("pids.max")
+	if err != nil {
+		return metrics, err
+	}
+	if limit >= 0 {
+		metrics = append(metrics, gauge("ContainerPidsLimit", limit, nil))
+	}
+	return metrics, nil
+}
+
+// ioPressure reports the share of time tasks were stalled on IO over the last 10, 60 and 300 seconds
+// and the total stall time, for some and for all ("full") non-idle tasks.
+func (c *Cgroup) ioPressure() ([]model.Metrics, error) {
+	content, err := c.read("io.pressure")
+	if err != nil {
+		return nil, err
+	}
+	var metrics []model.Metrics
+	for _, line := range strings.Split(content, "\n") {
+		// some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		kind := fields[0]
+		for _, field := range fields[1:] {
+			key, raw, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return metrics, fmt.Errorf("invalid io.pressure value %q: %w", field, err)
+			}
+			if key == "total" {
+				metrics = append(metrics, gauge("ContainerIOPressureStalledSeconds", value/1e6,
+					model.Labels{"kind": kind}))
+				continue
+			}
+			window := strings.TrimPrefix(key, "avg") + "s"
+			metrics = append(metrics, gauge("ContainerIOPressure", value,
+				model.Labels{"kind": kind, "window": window}))
+		}
+	}
+	return metrics, nil
+}
+
+func (c *Cgroup) read(name string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// readValue reads a single value file, "max" is returned as -1.
+func (c *Cgroup) readValue(name string) (float64, error) {
+	content, err := c.read(name)
+	if err != nil {
+		return 0, err
+	}
+	if content == cgroupUnlimited {
+		return -1, nil
+	}
+	value, err := strconv.ParseFloat(content, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// readKeyed reads a flat keyed file with "key value" lines.
+func (c *Cgroup) readKeyed(name string) (map[string]float64, error) {
+	content, err := c.read(name)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]float64)
+	for _, line := range strings.Split(content, "\n") {
+		key, raw, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q: %w", name, line, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
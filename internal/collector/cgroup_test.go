@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// fakeCgroupfs creates the unified hierarchy root with the files of the agent cgroup at path.
+func fakeCgroupfs(t *testing.T, path string, files map[string]string) (string, string) {
+	root := t.TempDir()
+	dir := filepath.Join(root, path)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu io memory pids"), 0644))
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	procCgroup := filepath.Join(t.TempDir(), "cgroup")
+	require.NoError(t, os.WriteFile(procCgroup, []byte("0::"+path+"\n"), 0644))
+	return root, procCgroup
+}
+
+func values(metrics []model.Metrics) map[string]float64 {
+	result := make(map[string]float64, len(metrics))
+	for _, metric := range metrics {
+		result[metric.SeriesKey()] = *metric.Value
+	}
+	return result
+}
+
+func TestCgroup_Collect(t *testing.T) {
+	root, procCgroup := fakeCgroupfs(t, "/system.slice/agent.scope", map[string]string{
+		"memory.current": "1048576\n",
+		"memory.max":     "4194304\n",
+		"cpu.stat": "usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\nnr_periods 10\n" +
+			"nr_throttled 2\nthrottled_usec 300000\n",
+		"cpu.max":      "50000 100000\n",
+		"pids.current": "7\n",
+		"pids.max":     "max\n",
+		"io.pressure": "some avg10=1.50 avg60=0.50 avg300=0.10 total=2000000\n" +
+			"full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+	})
+	c := newCgroup(time.Second, root, procCgroup)
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]float64{
+		"ContainerMemoryUsage":         1048576,
+		"ContainerMemoryLimit":         4194304,
+		"ContainerCPUUsageSeconds":     2.5,
+		"ContainerCPUPeriods":          10,
+		"ContainerCPUThrottledPeriods": 2,
+		"ContainerCPUThrottledSeconds": 0.3,
+		"ContainerCPULimit":            0.5,
+		"ContainerPids":                7,
+		model.SeriesKey("ContainerIOPressure", model.Labels{"kind": "some", "window": "10s"}):  1.5,
+		model.SeriesKey("ContainerIOPressure", model.Labels{"kind": "some", "window": "60s"}):  0.5,
+		model.SeriesKey("ContainerIOPressure", model.Labels{"kind": "some", "window": "300s"}): 0.1,
+		model.SeriesKey("ContainerIOPressure", model.Labels{"kind": "full", "window": "10s"}):  0,
+		model.SeriesKey("ContainerIOPressure", model.Labels{"kind": "full", "window": "60s"}):  0,
+		model.SeriesKey("ContainerIOPressure", model.Labels{"kind": "full", "window": "300s"}): 0,
+		model.SeriesKey("ContainerIOPressureStalledSeconds", model.Labels{"kind": "some"}):     2,
+		model.SeriesKey("ContainerIOPressureStalledSeconds", model.Labels{"kind": "full"}):     0,
+	}, values(metrics))
+}
+
+func TestCgroup_MissingControllers(t *testing.T) {
+	// a namespaced container sees its own cgroup as the root, the cpu and pids controllers are not enabled
+	root, procCgroup := fakeCgroupfs(t, "/", map[string]string{
+		"memory.current": "1048576\n",
+		"memory.max":     "max\n",
+		"cpu.stat":       "usage_usec 1000000\nuser_usec 1000000\nsystem_usec 0\n",
+	})
+	c := newCgroup(time.Second, root, procCgroup)
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{
+		"ContainerMemoryUsage":     1048576,
+		"ContainerCPUUsageSeconds": 1,
+	}, values(metrics))
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "pids.current"), []byte("many"), 0644))
+	_, err = c.Collect(context.Background())
+	require.Error(t, err)
+}
+
+func TestCgroup_Fallback(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T) (string, string)
+	}{
+		{
+			name: "cgroup v1",
+			setup: func(t *testing.T) (string, string) {
+				root := t.TempDir()
+				require.NoError(t, os.Mkdir(filepath.Join(root, "memory"), 0755))
+				return root, filepath.Join(root, "cgroup")
+			},
+		},
+		{
+			name: "root cgroup",
+			setup: func(t *testing.T) (string, string) {
+				return fakeCgroupfs(t, "/", nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, procCgroup := tt.setup(t)
+			c := newCgroup(time.Second, root, procCgroup)
+			metrics, err := c.Collect(context.Background())
+			require.NoError(t, err)
+			assert.Empty(t, metrics)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Package collector defines the sources of metrics collected by the agent and the registry they are enabled from.
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// Collector is a source of metrics collected on its own schedule. Collected values are the current state,
+// so they are reported as gauges, or summaries for distributions, and replace the previous collection.
+type Collector interface {
+	// Name identifies the collector in configuration and logs.
+	Name() string
+	// Interval is the period between collections.
+	Interval() time.Duration
+	// Collect returns the current values. Metrics collected before an error are returned along with it.
+	Collect(ctx context.Context) ([]model.Metrics, error)
+}
+
+// Options configure the collectors created by the registry.
+type Options struct {
+	// Interval between collections, Build sets it to the interval of the collector spec.
+	Interval time.Duration
+	// Mounts selects the mount points reported by the disk collector.
+	Mounts Filter
+	// Interfaces selects the network interfaces reported by the network collector.
+	Interfaces Filter
+	// TopProcesses is the number of processes reported by CPU and by RSS.
+	TopProcesses int
+	// RuntimeAll enables every supported runtime/metrics metric in the runtime collector.
+	RuntimeAll bool
+}
+
+// Factory creates a collector with the given options.
+type Factory func(opts Options) (Collector, error)
+
+// Registry holds the factories of known collectors.
+type Registry struct {
+	factories map[string]Factory
+	defaults  []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// Register adds the collector factory, collectors enabled by default are used when no collectors are configured.
+func (r *Registry) Register(name string, factory Factory, enabledByDefault bool) *Registry {
+	r.factories[name] = factory
+	if enabledByDefault {
+		r.defaults = append(r.defaults, name)
+	}
+	return r
+}
+
+// Names returns the names of the registered collectors in alphabetical order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build creates the collectors enabled by specs. A spec is the collector name optionally followed
+// by its interval in seconds, e.g. "system:5"; collectors without an interval run every opts.Interval.
+// When specs are empty, the collectors enabled by default are built.
+func (r *Registry) Build(specs []string, opts Options) ([]Collector, error) {
+	if len(specs) == 0 {
+		specs = r.defaults
+	}
+	collectors := make([]Collector, 0, len(specs))
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		name, interval, err := parseSpec(spec, opts.Interval)
+		if err != nil {
+			return nil, err
+		}
+		factory, ok := r.factories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q, available collectors: %s", name,
+				strings.Join(r.Names(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("collector %q is enabled more than once", name)
+		}
+		seen[name] = true
+		collectorOpts := opts
+		collectorOpts.Interval = interval
+		collector, err := factory(collectorOpts)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create collector %q: %w", name, err)
+		}
+		collectors = append(collectors, collector)
+	}
+	return collectors, nil
+}
+
+func parseSpec(spec string, defaultInterval time.Duration) (string, time.Duration, error) {
+	name, seconds, found := strings.Cut(strings.TrimSpace(spec), ":")
+	if !found {
+		return name, defaultInterval, nil
+	}
+	interval, err := strconv.Atoi(seconds)
+	if err != nil || interval <= 0 {
+		return "", 0, fmt.Errorf("invalid interval of collector %q: %q", name, seconds)
+	}
+	return name, time.Duration(interval) * time.Second, nil
+}
+
+// Run collects metrics with every collector on its own schedule until the context is done.
+// Every collection is passed to sink along with its error, so a failing collector does not affect the others.
+func Run(ctx context.Context, collectors []Collector, sink func(c Collector, metrics []model.Metrics, err error)) {
+	var wg sync.WaitGroup
+	wg.Add(len(collectors))
+	for _, c := range collectors {
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(c.Interval())
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					metrics, err := c.Collect(ctx)
+					sink(c, metrics, err)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Default returns the registry with the built-in collectors, every one but the process collector is enabled by default.
+func Default() *Registry {
+	return NewRegistry().
+		Register(RuntimeName, NewRuntime, true).
+		Register(SystemName, NewSystem, true).
+		Register(DiskName, NewDisk, true).
+		Register(NetworkName, NewNetwork, true).
+		Register(LoadName, NewLoad, true).
+		Register(UptimeName, NewUptime, true).
+		Register(CgroupName, NewCgroup, true).
+		Register(ProcessName, NewProcess, false)
+}
+
+func gauge(id string, value float64, labels model.Labels) model.Metrics {
+	metric := model.NewGauge(id, &value)
+	metric.Labels = labels
+	return *metric
+}
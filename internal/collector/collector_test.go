@@ -0,0 +1,195 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+type stubCollector struct {
+	name     string
+	interval time.Duration
+	err      error
+}
+
+func (c *stubCollector) Name() string {
+	return c.name
+}
+
+func (c *stubCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *stubCollector) Collect(context.Context) ([]model.Metrics, error) {
+	value := 1.0
+	return []model.Metrics{*model.NewGauge(c.name, &value)}, c.err
+}
+
+func stubFactory(name string) Factory {
+	return func(opts Options) (Collector, error) {
+		return &stubCollector{name: name, interval: opts.Interval}, nil
+	}
+}
+
+func TestRegistry_Build(t *testing.T) {
+	registry := NewRegistry().
+		Register("a", stubFactory("a"), true).
+		Register("b", stubFactory("b"), false)
+
+	tests := []struct {
+		name          string
+		specs         []string
+		wantNames     []string
+		wantIntervals []time.Duration
+		wantErr       bool
+	}{
+		{name: "defaults", wantNames: []string{"a"}, wantIntervals: []time.Duration{2 * time.Second}},
+		{
+			name:          "with intervals",
+			specs:         []string{"b:5", " a"},
+			wantNames:     []string{"b", "a"},
+			wantIntervals: []time.Duration{5 * time.Second, 2 * time.Second},
+		},
+		{name: "unknown", specs: []string{"c"}, wantErr: true},
+		{name: "invalid interval", specs: []string{"a:0"}, wantErr: true},
+		{name: "duplicate", specs: []string{"a", "a:5"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collectors, err := registry.Build(tt.specs, Options{Interval: 2 * time.Second})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var names []string
+			var intervals []time.Duration
+			for _, c := range collectors {
+				names = append(names, c.Name())
+				intervals = append(intervals, c.Interval())
+			}
+			assert.Equal(t, tt.wantNames, names)
+			assert.Equal(t, tt.wantIntervals, intervals)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	collectors := []Collector{
+		&stubCollector{name: "ok", interval: time.Millisecond},
+		&stubCollector{name: "failing", interval: time.Millisecond, err: errors.New("failed")},
+	}
+
+	var mu sync.Mutex
+	errs := make(map[string]error)
+	done := make(chan struct{})
+	go func() {
+		Run(ctx, collectors, func(c Collector, metrics []model.Metrics, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Len(t, metrics, 1)
+			errs[c.Name()] = err
+		})
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) == 2
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	assert.NoError(t, errs["ok"])
+	assert.Error(t, errs["failing"])
+}
+
+func TestSystem_Collect(t *testing.T) {
+	c, err := NewSystem(Options{Interval: time.Second})
+	require.NoError(t, err)
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	ids := make(map[string]bool)
+	for _, metric := range metrics {
+		ids[metric.ID] = true
+	}
+	keys := []string{"TotalMemory", "FreeMemory"}
+	for i := 0; i < runtime.NumCPU(); i++ {
+		keys = append(keys, "CPUutilization"+strconv.Itoa(i))
+	}
+	for _, key := range keys {
+		assert.Truef(t, ids[key], "Expected key %s is missing", key)
+	}
+}
+
+func TestNetwork_Collect(t *testing.T) {
+	c, err := NewNetwork(Options{Interfaces: Filter{Include: []string{"lo"}}})
+	require.NoError(t, err)
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	for _, metric := range metrics {
+		assert.Equal(t, model.Labels{"interface": "lo"}, metric.Labels)
+	}
+}
+
+func TestDisk_Collect(t *testing.T) {
+	c, err := NewDisk(Options{Mounts: Filter{Include: []string{"/nonexistent"}}})
+	require.NoError(t, err)
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, metrics)
+
+	c, err = NewDisk(Options{})
+	require.NoError(t, err)
+	metrics, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	for _, metric := range metrics {
+		assert.Equal(t, model.Gauge, metric.MType)
+		assert.True(t, metric.Labels["mount"] != "" || metric.Labels["device"] != "")
+	}
+}
+
+func TestHost_Collect(t *testing.T) {
+	for _, factory := range []Factory{NewLoad, NewUptime} {
+		c, err := factory(Options{})
+		require.NoError(t, err)
+		metrics, err := c.Collect(context.Background())
+		require.NoError(t, err)
+		assert.NotEmpty(t, metrics)
+	}
+}
+
+func TestProcess_Collect(t *testing.T) {
+	c, err := NewProcess(Options{TopProcesses: 2})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		metrics, err := c.Collect(context.Background())
+		require.NoError(t, err)
+
+		// processes are labeled by name only, so every series is reported once
+		counts := make(map[string]int)
+		series := make(map[string]bool)
+		for _, metric := range metrics {
+			counts[metric.ID]++
+			assert.Equal(t, model.Labels{"name": metric.Labels["name"]}, metric.Labels)
+			assert.False(t, series[metric.SeriesKey()], metric.SeriesKey())
+			series[metric.SeriesKey()] = true
+			assert.GreaterOrEqual(t, *metric.Value, 0.0)
+		}
+		assert.Equal(t, 2, counts["ProcessCPUPercent"])
+		assert.Equal(t, 2, counts["ProcessRSS"])
+	}
+}
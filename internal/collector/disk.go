@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/disk"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// DiskName is the name of the disk usage and IO collector.
+const DiskName = "disk"
+
+// Disk reports the usage of every selected mount point and the IO counters of the devices behind them.
+// IO counters are cumulative since boot.
+type Disk struct {
+	interval time.Duration
+	mounts   Filter
+}
+
+func NewDisk(opts Options) (Collector, error) {
+	return &Disk{interval: opts.Interval, mounts: opts.Mounts}, nil
+}
+
+func (c *Disk) Name() string {
+	return DiskName
+}
+
+func (c *Disk) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *Disk) Collect(ctx context.Context) ([]model.Metrics, error) {
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("error listing partitions: %w", err)
+	}
+
+	var (
+		metrics []model.Metrics
+		errs    []error
+		devices = make(map[string]bool)
+	)
+	for _, partition := range partitions {
+		if !c.mounts.Match(partition.Mountpoint) {
+			continue
+		}
+		usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error collecting usage of %s: %w", partition.Mountpoint, err))
+			continue
+		}
+		labels := model.Labels{"mount": partition.Mountpoint, "fstype": partition.Fstype}
+		metrics = append(metrics,
+			gauge("DiskTotal", float64(usage.Total), labels),
+			gauge("DiskUsed", float64(usage.Used), labels),
+			gauge("DiskFree", float64(usage.Free), labels),
+			gauge("DiskUsedPercent", usage.UsedPercent, labels),
+		)
+		devices[filepath.Base(partition.Device)] = true
+	}
+	if len(devices) == 0 {
+		return metrics, errors.Join(errs...)
+	}
+
+	counters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error collecting disk IO: %w", err))
+		return metrics, errors.Join(errs...)
+	}
+	for device, counter := range counters {
+		if !devices[device] {
+			continue
+		}
+		labels := model.Labels{"device": device}
+		metrics = append(metrics,
+			gauge("DiskReadBytes", float64(counter.ReadBytes), labels),
+			gauge("DiskWriteBytes", float64(counter.WriteBytes), labels),
+			gauge("DiskReadCount", float64(counter.ReadCount), labels),
+			gauge("DiskWriteCount", float64(counter.WriteCount), labels),
+		)
+	}
+	return metrics, errors.Join(errs...)
+}
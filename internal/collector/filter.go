@@ -0,0 +1,27 @@
+package collector
+
+import "path"
+
+// Filter selects names by shell patterns (see path.Match), e.g. "/mnt/*" or "eth*".
+// A name matches when it matches any include pattern, or there are none, and no exclude pattern.
+type Filter struct {
+	Include []string
+	Exclude []string
+}
+
+func (f Filter) Match(name string) bool {
+	for _, pattern := range f.Exclude {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter_Match(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		give   string
+		want   bool
+	}{
+		{name: "empty", give: "/", want: true},
+		{name: "included", filter: Filter{Include: []string{"eth*", "lo"}}, give: "eth0", want: true},
+		{name: "not included", filter: Filter{Include: []string{"eth*"}}, give: "lo"},
+		{name: "excluded", filter: Filter{Exclude: []string{"/snap/*"}}, give: "/snap/core", want: false},
+		{name: "exclude wins", filter: Filter{Include: []string{"/*"}, Exclude: []string{"/boot"}}, give: "/boot"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Match(tt.give))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/host"
+	"github.com/shirou/gopsutil/v4/load"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+const (
+	// LoadName is the name of the load average collector.
+	LoadName = "load"
+	// UptimeName is the name of the host uptime collector.
+	UptimeName = "uptime"
+)
+
+// Load reports the 1, 5 and 15 minutes load average.
+type Load struct {
+	interval time.Duration
+}
+
+func NewLoad(opts Options) (Collector, error) {
+	return &Load{interval: opts.Interval}, nil
+}
+
+func (c *Load) Name() string {
+	return LoadName
+}
+
+func (c *Load) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *Load) Collect(ctx context.Context) ([]model.Metrics, error) {
+	avg, err := load.AvgWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error collecting load average: %w", err)
+	}
+	return []model.Metrics{
+		gauge("Load1", avg.Load1, nil),
+		gauge("Load5", avg.Load5, nil),
+		gauge("Load15", avg.Load15, nil),
+	}, nil
+}
+
+// Uptime reports the host uptime in seconds.
+type Uptime struct {
+	interval time.Duration
+}
+
+func NewUptime(opts Options) (Collector, error) {
+	return &Uptime{interval: opts.Interval}, nil
+}
+
+func (c *Uptime) Name() string {
+	return UptimeName
+}
+
+func (c *Uptime) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *Uptime) Collect(ctx context.Context) ([]model.Metrics, error) {
+	uptime, err := host.UptimeWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error collecting uptime: %w", err)
+	}
+	return []model.Metrics{gauge("Uptime", float64(uptime), nil)}, nil
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// NetworkName is the name of the network interfaces collector.
+const NetworkName = "network"
+
+// Network reports bytes, packets and errors of every selected network interface, cumulative since boot.
+type Network struct {
+	interval   time.Duration
+	interfaces Filter
+}
+
+func NewNetwork(opts Options) (Collector, error) {
+	return &Network{interval: opts.Interval, interfaces: opts.Interfaces}, nil
+}
+
+func (c *Network) Name() string {
+	return NetworkName
+}
+
+func (c *Network) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *Network) Collect(ctx context.Context) ([]model.Metrics, error) {
+	counters, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("error collecting network IO: %w", err)
+	}
+	var metrics []model.Metrics
+	for _, counter := range counters {
+		if !c.interfaces.Match(counter.Name) {
+			continue
+		}
+		labels := model.Labels{"interface": counter.Name}
+		metrics = append(metrics,
+			gauge("NetBytesSent", float64(counter.BytesSent), labels),
+			gauge("NetBytesRecv", float64(counter.BytesRecv), labels),
+			gauge("NetPacketsSent", float64(counter.PacketsSent), labels),
+			gauge("NetPacketsRecv", float64(counter.PacketsRecv), labels),
+			gauge("NetErrorsIn", float64(counter.Errin), labels),
+			gauge("NetErrorsOut", float64(counter.Errout), labels),
+		)
+	}
+	return metrics, nil
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// ProcessName is the name of the top processes collector.
+const ProcessName = "process"
+
+// DefaultTopProcesses is the number of reported processes when it is not configured.
+const DefaultTopProcesses = 5
+
+// Process reports the top processes by CPU utilization and by resident memory. Processes are labeled by name only,
+// so restarts do not create new series, and processes sharing the name, e.g. workers, are reported as their sum.
+// CPU utilization is measured between collections, on the first collection of a process it is its lifetime average.
+type Process struct {
+	interval time.Duration
+	top      int
+	// cpuTimes holds the total CPU time of every process seen on the previous collection.
+	cpuTimes map[int32]cpuSample
+	now      func() time.Time
+}
+
+type cpuSample struct {
+	total float64
+	at    time.Time
+}
+
+type processStat struct {
+	name       string
+	cpuPercent float64
+	rss        uint64
+}
+
+func NewProcess(opts Options) (Collector, error) {
+	top := opts.TopProcesses
+	if top <= 0 {
+		top = DefaultTopProcesses
+	}
+	return &Process{interval: opts.Interval, top: top, cpuTimes: make(map[int32]cpuSample), now: time.Now}, nil
+}
+
+func (c *Process) Name() string {
+	return ProcessName
+}
+
+func (c *Process) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *Process) Collect(ctx context.Context) ([]model.Metrics, error) {
+	processes, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing processes: %w", err)
+	}
+
+	now := c.now()
+	cpuTimes := make(map[int32]cpuSample, len(processes))
+	byName := make(map[string]*processStat, len(processes))
+	for _, p := range processes {
+		// processes may exit while they are being read, such processes are skipped
+		times, err := p.TimesWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		memory, err := p.MemoryInfoWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		sample := cpuSample{total: times.User + times.System, at: now}
+		cpuTimes[p.Pid] = sample
+
+		var cpuPercent float64
+		if prev, ok := c.cpuTimes[p.Pid]; ok && now.After(prev.at) {
+			cpuPercent = 100 * (sample.total - prev.total) / now.Sub(prev.at).Seconds()
+		} else if cpuPercent, err = p.CPUPercentWithContext(ctx); err != nil {
+			continue
+		}
+		stat, ok := byName[name]
+		if !ok {
+			stat = &processStat{name: name}
+			byName[name] = stat
+		}
+		stat.cpuPercent += cpuPercent
+		stat.rss += memory.RSS
+	}
+	// samples of exited processes are dropped
+	c.cpuTimes = cpuTimes
+
+	stats := make([]processStat, 0, len(byName))
+	for _, stat := range byName {
+		stats = append(stats, *stat)
+	}
+
+	var metrics []model.Metrics
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].cpuPercent > stats[j].cpuPercent
+	})
+	for _, stat := range stats[:min(c.top, len(stats))] {
+		metrics = append(metrics, gauge("ProcessCPUPercent", stat.cpuPercent, stat.labels()))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].rss > stats[j].rss
+	})
+	for _, stat := range stats[:min(c.top, len(stats))] {
+		metrics = append(metrics, gauge("ProcessRSS", float64(stat.rss), stat.labels()))
+	}
+	return metrics, nil
+}
+
+func (s processStat) labels() model.Labels {
+	return model.Labels{"name": s.name}
+}
@@ -0,0 +1,233 @@
+package collector
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"strings"
+	"time"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// RuntimeName is the name of the Go runtime collector.
+const RuntimeName = "runtime"
+
+// RuntimeMetrics are the gauges named after the runtime.MemStats fields, they are kept for existing dashboards.
+var RuntimeMetrics = []string{
+	"Alloc", "BuckHashSys", "Frees", "GCCPUFraction", "GCSys", "HeapAlloc", "HeapIdle", "HeapInuse", "HeapObjects",
+	"HeapReleased", "HeapSys", "LastGC", "Lookups", "MCacheInuse", "MCacheSys", "MSpanInuse", "MSpanSys", "Mallocs",
+	"NextGC", "NumForcedGC", "NumGC", "OtherSys", "PauseTotalNs", "StackInuse", "StackSys", "Sys", "TotalAlloc",
+}
+
+// memStats maps the MemStats field names to the sums of runtime/metrics they are derived from,
+// see the runtime/metrics documentation. GCCPUFraction, LastGC, Lookups and PauseTotalNs have no such
+// equivalent and are calculated separately.
+var memStats = map[string][]string{
+	"Alloc":        {"/memory/classes/heap/objects:bytes"},
+	"BuckHashSys":  {"/memory/classes/profiling/buckets:bytes"},
+	"Frees":        {"/gc/heap/frees:objects", "/gc/heap/tiny/allocs:objects"},
+	"GCSys":        {"/memory/classes/metadata/other:bytes"},
+	"HeapAlloc":    {"/memory/classes/heap/objects:bytes"},
+	"HeapIdle":     {"/memory/classes/heap/released:bytes", "/memory/classes/heap/free:bytes"},
+	"HeapInuse":    {"/memory/classes/heap/objects:bytes", "/memory/classes/heap/unused:bytes"},
+	"HeapObjects":  {"/gc/heap/objects:objects"},
+	"HeapReleased": {"/memory/classes/heap/released:bytes"},
+	"HeapSys": {"/memory/classes/heap/objects:bytes", "/memory/classes/heap/unused:bytes",
+		"/memory/classes/heap/free:bytes", "/memory/classes/heap/released:bytes"},
+	"MCacheInuse": {"/memory/classes/metadata/mcache/inuse:bytes"},
+	"MCacheSys":   {"/memory/classes/metadata/mcache/inuse:bytes", "/memory/classes/metadata/mcache/free:bytes"},
+	"MSpanInuse":  {"/memory/classes/metadata/mspan/inuse:bytes"},
+	"MSpanSys":    {"/memory/classes/metadata/mspan/inuse:bytes", "/memory/classes/metadata/mspan/free:bytes"},
+	"Mallocs":     {"/gc/heap/allocs:objects", "/gc/heap/tiny/allocs:objects"},
+	"NextGC":      {"/gc/heap/goal:bytes"},
+	"NumForcedGC": {"/gc/cycles/forced:gc-cycles"},
+	"NumGC":       {"/gc/cycles/total:gc-cycles"},
+	"OtherSys":    {"/memory/classes/other:bytes"},
+	"StackInuse":  {"/memory/classes/heap/stacks:bytes"},
+	"StackSys":    {"/memory/classes/heap/stacks:bytes", "/memory/classes/os-stacks:bytes"},
+	"Sys":         {"/memory/classes/total:bytes"},
+	"TotalAlloc":  {"/gc/heap/allocs:bytes"},
+}
+
+const (
+	gcCPUMetric    = "/cpu/classes/gc/total:cpu-seconds"
+	totalCPUMetric = "/cpu/classes/total:cpu-seconds"
+)
+
+// runtimeQuantiles are reported for the runtime histograms, e.g. the scheduler latencies and GC pauses.
+var runtimeQuantiles = []float64{0.5, 0.9, 0.99, 1}
+
+// Runtime reports the Go runtime statistics read with runtime/metrics, which does not stop the world,
+// as the MemStats compatible gauges and a RandomValue gauge. With all set, every supported runtime metric
+// is reported as well: scalars as gauges named after the metric, e.g. go_sched_goroutines_goroutines,
+// and histograms as summaries of their quantiles.
+type Runtime struct {
+	interval time.Duration
+	all      bool
+	samples  []metrics.Sample
+	// index holds the position of every metric in samples.
+	index map[string]int
+}
+
+func NewRuntime(opts Options) (Collector, error) {
+	c := &Runtime{interval: opts.Interval, all: opts.RuntimeAll, index: make(map[string]int)}
+	supported := make(map[string]bool)
+	for _, description := range metrics.All() {
+		supported[description.Name] = true
+		if c.all {
+			c.add(description.Name)
+		}
+	}
+	for _, names := range memStats {
+		for _, name := range names {
+			if supported[name] {
+				c.add(name)
+			}
+		}
+	}
+	c.add(gcCPUMetric)
+	c.add(totalCPUMetric)
+	return c, nil
+}
+
+func (c *Runtime) add(name string) {
+	if _, ok := c.index[name]; ok {
+		return
+	}
+	c.index[name] = len(c.samples)
+	c.samples = append(c.samples, metrics.Sample{Name: name})
+}
+
+func (c *Runtime) Name() string {
+	return RuntimeName
+}
+
+func (c *Runtime) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect is not safe for concurrent use, the collector reads samples into the same buffer every time.
+func (c *Runtime) Collect(context.Context) ([]model.Metrics, error) {
+	var randomValue float64
+	if err := binary.Read(rand.Reader, binary.BigEndian, &randomValue); err != nil {
+		return nil, fmt.Errorf("error generating random number: %w", err)
+	}
+	metrics.Read(c.samples)
+
+	result := make([]model.Metrics, 0, len(RuntimeMetrics)+1)
+	result = append(result, gauge("RandomValue", randomValue, nil))
+	result = append(result, c.memStats()...)
+	if !c.all {
+		return result, nil
+	}
+	for _, sample := range c.samples {
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			result = append(result, gauge(runtimeMetricID(sample.Name), float64(sample.Value.Uint64()), nil))
+		case metrics.KindFloat64:
+			result = append(result, gauge(runtimeMetricID(sample.Name), sample.Value.Float64(), nil))
+		case metrics.KindFloat64Histogram:
+			summary := histogramSummary(sample.Value.Float64Histogram())
+			result = append(result, *model.NewSummary(runtimeMetricID(sample.Name), summary))
+		case metrics.KindBad:
+			// the metric is not supported by this Go version
+		}
+	}
+	return result, nil
+}
+
+func (c *Runtime) memStats() []model.Metrics {
+	var gcStats debug.GCStats
+	debug.ReadGCStats(&gcStats)
+
+	result := make([]model.Metrics, 0, len(RuntimeMetrics))
+	for _, name := range RuntimeMetrics {
+		var value float64
+		switch name {
+		case "GCCPUFraction":
+			if total := c.value(totalCPUMetric); total > 0 {
+				value = c.value(gcCPUMetric) / total
+			}
+		case "LastGC":
+			if !gcStats.LastGC.IsZero() {
+				value = float64(gcStats.LastGC.UnixNano())
+			}
+		case "PauseTotalNs":
+			value = float64(gcStats.PauseTotal.Nanoseconds())
+		case "Lookups":
+			// pointer lookups are not performed by the runtime anymore
+		default:
+			for _, metric := range memStats[name] {
+				value += c.value(metric)
+			}
+		}
+		result = append(result, gauge(name, value, nil))
+	}
+	return result
+}
+
+// value returns the scalar value of the read metric, zero for metrics unsupported by this Go version.
+func (c *Runtime) value(name string) float64 {
+	i, ok := c.index[name]
+	if !ok {
+		return 0
+	}
+	switch value := c.samples[i].Value; value.Kind() {
+	case metrics.KindUint64:
+		return float64(value.Uint64())
+	case metrics.KindFloat64:
+		return value.Float64()
+	default:
+		return 0
+	}
+}
+
+// runtimeMetricID converts the runtime metric name to an identifier, e.g.
+// /sched/latencies:seconds → go_sched_latencies_seconds.
+func runtimeMetricID(name string) string {
+	return "go" + strings.NewReplacer("/", "_", ":", "_", "-", "_", "*", "_").Replace(name)
+}
+
+// histogramSummary estimates the quantiles, sum and count of the runtime histogram. A quantile is the upper bound
+// of the bucket it falls into and the sum assumes the observations are in the middle of their buckets.
+// Infinite bounds are replaced by the finite bound of the same bucket, so the summary can be encoded as JSON.
+func histogramSummary(h *metrics.Float64Histogram) *model.SummaryValue {
+	summary := &model.SummaryValue{Quantiles: make([]model.Quantile, 0, len(runtimeQuantiles))}
+	for i, count := range h.Counts {
+		summary.Count += count
+		if count > 0 {
+			lower, upper := finiteBounds(h.Buckets[i], h.Buckets[i+1])
+			summary.Sum += float64(count) * (lower + upper) / 2
+		}
+	}
+	var cumulative uint64
+	next := 0
+	for i, count := range h.Counts {
+		cumulative += count
+		for next < len(runtimeQuantiles) && summary.Count > 0 &&
+			float64(cumulative) >= runtimeQuantiles[next]*float64(summary.Count) {
+			_, upper := finiteBounds(h.Buckets[i], h.Buckets[i+1])
+			summary.Quantiles = append(summary.Quantiles, model.Quantile{Quantile: runtimeQuantiles[next], Value: upper})
+			next++
+		}
+	}
+	for ; next < len(runtimeQuantiles); next++ {
+		summary.Quantiles = append(summary.Quantiles, model.Quantile{Quantile: runtimeQuantiles[next]})
+	}
+	return summary
+}
+
+func finiteBounds(lower, upper float64) (float64, float64) {
+	if math.IsInf(lower, -1) {
+		lower = upper
+	}
+	if math.IsInf(upper, 1) {
+		upper = lower
+	}
+	return lower, upper
+}
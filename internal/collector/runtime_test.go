@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+func TestRuntime_Collect(t *testing.T) {
+	runtime.GC()
+	c, err := NewRuntime(Options{Interval: time.Second})
+	require.NoError(t, err)
+	collected, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	gauges := make(map[string]float64)
+	for _, metric := range collected {
+		require.Equal(t, model.Gauge, metric.MType)
+		gauges[metric.ID] = *metric.Value
+	}
+	assert.Len(t, gauges, len(RuntimeMetrics)+1)
+	for _, key := range append(RuntimeMetrics, "RandomValue") {
+		assert.Containsf(t, gauges, key, "Expected key %s is missing", key)
+	}
+
+	// the values are consistent with MemStats
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	assert.Positive(t, gauges["HeapAlloc"])
+	assert.GreaterOrEqual(t, gauges["HeapSys"], gauges["HeapInuse"])
+	assert.GreaterOrEqual(t, gauges["Sys"], gauges["HeapSys"])
+	assert.LessOrEqual(t, gauges["NumGC"], float64(memStats.NumGC))
+	assert.Positive(t, gauges["NumGC"])
+	assert.Positive(t, gauges["LastGC"])
+	assert.LessOrEqual(t, gauges["TotalAlloc"], float64(memStats.TotalAlloc))
+}
+
+func TestRuntime_CollectAll(t *testing.T) {
+	c, err := NewRuntime(Options{Interval: time.Second, RuntimeAll: true})
+	require.NoError(t, err)
+	collected, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	byID := make(map[string]model.Metrics)
+	for _, metric := range collected {
+		byID[metric.ID] = metric
+	}
+	assert.Contains(t, byID, "HeapAlloc")
+	goroutines := byID["go_sched_goroutines_goroutines"]
+	assert.Equal(t, model.Gauge, goroutines.MType)
+	assert.Positive(t, *goroutines.Value)
+	latencies := byID["go_sched_latencies_seconds"]
+	assert.Equal(t, model.Summary, latencies.MType)
+	require.Len(t, latencies.Summary.Quantiles, 4)
+
+	// histograms with infinite buckets must be encodable
+	_, err = json.Marshal(collected)
+	require.NoError(t, err)
+}
+
+func TestHistogramSummary(t *testing.T) {
+	tests := []struct {
+		name      string
+		histogram *metrics.Float64Histogram
+		want      *model.SummaryValue
+	}{
+		{
+			name: "finite buckets",
+			histogram: &metrics.Float64Histogram{
+				Counts:  []uint64{5, 4, 1},
+				Buckets: []float64{0, 1, 2, 4},
+			},
+			want: &model.SummaryValue{
+				Quantiles: []model.Quantile{{Quantile: 0.5, Value: 1}, {Quantile: 0.9, Value: 2},
+					{Quantile: 0.99, Value: 4}, {Quantile: 1, Value: 4}},
+				Sum:   5*0.5 + 4*1.5 + 3,
+				Count: 10,
+			},
+		},
+		{
+			name: "infinite buckets",
+			histogram: &metrics.Float64Histogram{
+				Counts:  []uint64{1, 0, 1},
+				Buckets: []float64{math.Inf(-1), 1, 2, math.Inf(1)},
+			},
+			want: &model.SummaryValue{
+				Quantiles: []model.Quantile{{Quantile: 0.5, Value: 1}, {Quantile: 0.9, Value: 2},
+					{Quantile: 0.99, Value: 2}, {Quantile: 1, Value: 2}},
+				Sum:   3,
+				Count: 2,
+			},
+		},
+		{
+			name: "empty",
+			histogram: &metrics.Float64Histogram{
+				Counts:  []uint64{0},
+				Buckets: []float64{0, 1},
+			},
+			want: &model.SummaryValue{
+				Quantiles: []model.Quantile{{Quantile: 0.5}, {Quantile: 0.9}, {Quantile: 0.99}, {Quantile: 1}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, histogramSummary(tt.histogram))
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/mem"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// SystemName is the name of the memory and CPU utilization collector.
+const SystemName = "system"
+
+// cpuSampleDuration is the period the CPU utilization is measured over.
+const cpuSampleDuration = time.Second
+
+// System reports the total and free memory and the utilization of every CPU.
+type System struct {
+	interval time.Duration
+	cpuCount int
+}
+
+func NewSystem(opts Options) (Collector, error) {
+	cpuCount, err := cpu.Counts(true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot detect number of cpus: %w", err)
+	}
+	return &System{interval: opts.Interval, cpuCount: cpuCount}, nil
+}
+
+func (c *System) Name() string {
+	return SystemName
+}
+
+func (c *System) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *System) Collect(ctx context.Context) ([]model.Metrics, error) {
+	v, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error collecting virtual memory: %w", err)
+	}
+	totalMem := float64(v.Total)
+	freeMem := float64(v.Free)
+	metrics := []model.Metrics{
+		*model.NewGauge("TotalMemory", &totalMem),
+		*model.NewGauge("FreeMemory", &freeMem),
+	}
+
+	percentages, err := cpu.PercentWithContext(ctx, cpuSampleDuration, true)
+	if err != nil {
+		return metrics, fmt.Errorf("error collecting cpu utilization: %w", err)
+	}
+	for i := 0; i < min(c.cpuCount, len(percentages)); i++ {
+		metricName := "CPUutilization" + strconv.Itoa(i)
+		metrics = append(metrics, *model.NewGauge(metricName, &percentages[i]))
+	}
+	return metrics, nil
+}
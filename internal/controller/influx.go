@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/itallix/go-metrics/internal/ingest/influx"
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// WithInfluxWriter enables the InfluxDB line protocol write endpoints.
+func (mc *MetricController) WithInfluxWriter(writer *influx.Writer) *MetricController {
+	mc.influxWriter = writer
+	return mc
+}
+
+// InfluxWrite stores points in InfluxDB line protocol, it serves both POST /write (v1) and POST /api/v2/write (v2).
+// The timestamp unit is set by the "precision" query parameter, nanoseconds by default; database, bucket and
+// org parameters are ignored. Errors are reported in the InfluxDB format: 400 in case of invalid lines or series
+// names, nothing is stored then, 413 in case of too large bodies and 500 in case of storage errors. Returns 204 when points are stored.
+func (mc *MetricController) InfluxWrite(c *gin.Context) {
+	if mc.influxWriter == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	precision, err := influx.ParsePrecision(c.Query("precision"))
+	if err != nil {
+		abortInflux(c, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+	body, err := mc.readBody(c)
+	if err != nil {
+		abortInflux(c, readBodyStatus(err), "invalid", "error reading request body")
+		return
+	}
+	points, err := influx.Parse(body, precision)
+	if err != nil {
+		abortInflux(c, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+	err = mc.influxWriter.Write(c.Request.Context(), points)
+	if errors.Is(err, model.ErrInvalidSeries) {
+		abortInflux(c, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+	if err != nil {
+		logger.Log().Errorf("Cannot write influx points: %v", err)
+		abortInflux(c, http.StatusInternalServerError, "internal error", "error writing points to storage")
+		return
+	}
+
+	logger.Log().Infof("Writing %d influx points completed.", len(points))
+	c.Status(http.StatusNoContent)
+}
+
+func abortInflux(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"code":    code,
+		"message": message,
+	})
+}
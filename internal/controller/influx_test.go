@@ -0,0 +1,135 @@
+package controller_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/controller"
+	"github.com/itallix/go-metrics/internal/ingest/influx"
+	"github.com/itallix/go-metrics/internal/middleware"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+func gzipBody(t *testing.T, data string) *bytes.Buffer {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return &buf
+}
+
+func TestMetricHandler_InfluxWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.GzipDecompress())
+	ctx := context.Background()
+	metricStorage := memory.NewMemStorage(ctx, nil, nil)
+	writer, err := influx.NewWriter(metricStorage, []string{"net_bytes_*"})
+	require.NoError(t, err)
+	metricController := controller.NewMetricController(metricStorage).
+		WithMaxBodySize(128).
+		WithInfluxWriter(writer)
+
+	router.POST("/write", metricController.InfluxWrite)
+	router.POST("/api/v2/write", metricController.InfluxWrite)
+
+	tests := []struct {
+		name       string
+		givePath   string
+		giveBody   string
+		gzip       bool
+		wantStatus int
+		wantJSON   string
+	}{
+		{
+			name:       "V2",
+			givePath:   "/api/v2/write?org=o&bucket=b&precision=s",
+			giveBody:   "cpu,host=web-1 usage_idle=97.5 1700000000\nnet,host=web-1 bytes_sent=1024i 1700000000",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "V1Gzip",
+			givePath:   "/write?db=telegraf",
+			giveBody:   "net,host=web-1 bytes_sent=2048i",
+			gzip:       true,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "InvalidLine",
+			givePath:   "/api/v2/write",
+			giveBody:   "cpu,host=web-1 usage_idle=97.5\ncpu",
+			wantStatus: http.StatusBadRequest,
+			wantJSON:   `{"code":"invalid","message":"line 2: invalid line protocol \"cpu\": missing fields"}`,
+		},
+		{
+			name:       "InvalidPrecision",
+			givePath:   "/api/v2/write?precision=d",
+			giveBody:   "cpu usage_idle=97.5",
+			wantStatus: http.StatusBadRequest,
+			wantJSON:   `{"code":"invalid","message":"unknown precision \"d\""}`,
+		},
+		{
+			name:       "InvalidSeries",
+			givePath:   "/api/v2/write",
+			giveBody:   "weird{name usage_idle=97.5",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "TooLarge",
+			givePath:   "/api/v2/write",
+			giveBody:   strings.Repeat("cpu usage_idle=97.5\n", 10),
+			gzip:       true,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := bytes.NewBufferString(tt.giveBody)
+			if tt.gzip {
+				body = gzipBody(t, tt.giveBody)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.givePath, body)
+			if tt.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantJSON != "" {
+				assert.JSONEq(t, tt.wantJSON, w.Body.String())
+			}
+		})
+	}
+
+	host := model.Labels{"host": "web-1"}
+	gauge := model.Metrics{ID: "cpu_usage_idle", MType: model.Gauge, Labels: host}
+	require.NoError(t, metricStorage.Read(ctx, &gauge))
+	assert.InDelta(t, 97.5, *gauge.Value, 1e-9)
+	counter := model.Metrics{ID: "net_bytes_sent", MType: model.Counter, Labels: host}
+	require.NoError(t, metricStorage.Read(ctx, &counter))
+	assert.Equal(t, int64(2048), *counter.Delta)
+}
+
+func TestMetricHandler_InfluxWriteDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	metricController := controller.NewMetricController(memory.NewMemStorage(context.Background(), nil, nil))
+	router.POST("/api/v2/write", metricController.InfluxWrite)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/write", bytes.NewBufferString("cpu usage_idle=97.5"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -1,13 +1,18 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/itallix/go-metrics/internal/ingest/influx"
+	"github.com/itallix/go-metrics/internal/ingest/otlp"
+	"github.com/itallix/go-metrics/internal/ingest/remotewrite"
 	"github.com/itallix/go-metrics/internal/logger"
 	"github.com/itallix/go-metrics/internal/model"
 	"github.com/itallix/go-metrics/internal/storage"
@@ -21,13 +26,45 @@ type Result struct {
 // MetricController implements API handlers for metric requests.
 type MetricController struct {
 	metricsStorage storage.Storage
+	influxWriter   *influx.Writer
+	remoteWriter   *remotewrite.Writer
+	otlpWriter     *otlp.Writer
+	maxBodySize    int64
 }
 
+// DefaultMaxBodySize is the max size in bytes of ingested request bodies when it is not configured.
+const DefaultMaxBodySize = 32 << 20
+
 // NewMetricController constructs new controller instance with a storage for metrics.
 func NewMetricController(metricsStorage storage.Storage) *MetricController {
 	return &MetricController{
 		metricsStorage: metricsStorage,
+		maxBodySize:    DefaultMaxBodySize,
+	}
+}
+
+// WithMaxBodySize sets the max size in bytes of the request bodies read by the ingestion endpoints.
+// Zero value falls back to DefaultMaxBodySize.
+func (mc *MetricController) WithMaxBodySize(size int64) *MetricController {
+	mc.maxBodySize = size
+	if size <= 0 {
+		mc.maxBodySize = DefaultMaxBodySize
+	}
+	return mc
+}
+
+// readBody reads the whole request body, at most maxBodySize bytes of it.
+func (mc *MetricController) readBody(c *gin.Context) ([]byte, error) {
+	return io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, mc.maxBodySize))
+}
+
+// readBodyStatus gives the status of the body read error: 413 when the body is too large and 400 otherwise.
+func readBodyStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
 	}
+	return http.StatusBadRequest
 }
 
 // UpdateBatch updates a collection of metrics, expecting the payload in JSON format.
@@ -107,13 +144,21 @@ func (mc *MetricController) ListMetrics(c *gin.Context) {
 <body>
 {{- $hasCounters := len .Counters }}
 {{- $hasGauges := len .Gauges }}
-{{- if or (gt $hasCounters 0) (gt $hasGauges 0) }}
+{{- $hasHistograms := len .Histograms }}
+{{- $hasSummaries := len .Summaries }}
+{{- if or (gt $hasCounters 0) (gt $hasGauges 0) (gt $hasHistograms 0) (gt $hasSummaries 0) }}
 	<ul>
-		{{- range $key, $value := .Counters }}
-		<li>{{ $key }}: {{ $value }}</li>
+		{{- range .Counters }}
+		<li>{{ .SeriesKey }}: {{ .Delta }}</li>
 		{{- end }}
-		{{- range $key, $value := .Gauges }}
-		<li>{{ $key }}: {{ $value }}</li>
+		{{- range .Gauges }}
+		<li>{{ .SeriesKey }}: {{ .Value }}</li>
+		{{- end }}
+		{{- range .Histograms }}
+		<li>{{ .SeriesKey }}: count {{ .Histogram.Count }}, sum {{ .Histogram.Sum }}</li>
+		{{- end }}
+		{{- range .Summaries }}
+		<li>{{ .SeriesKey }}: count {{ .Summary.Count }}, sum {{ .Summary.Sum }}</li>
 		{{- end }}
 	</ul>
 </body>
@@ -139,13 +184,27 @@ func (mc *MetricController) ListMetrics(c *gin.Context) {
 		c.String(http.StatusInternalServerError, "Error reading gauges from storage")
 		return
 	}
+	histograms, err := mc.metricsStorage.GetHistograms(ctx)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error reading histograms from storage")
+		return
+	}
+	summaries, err := mc.metricsStorage.GetSummaries(ctx)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error reading summaries from storage")
+		return
+	}
 
 	data := struct {
-		Counters map[string]int64
-		Gauges   map[string]float64
+		Counters   []model.Metrics
+		Gauges     []model.Metrics
+		Histograms []model.Metrics
+		Summaries  []model.Metrics
 	}{
-		Counters: counters,
-		Gauges:   gauges,
+		Counters:   counters,
+		Gauges:     gauges,
+		Histograms: histograms,
+		Summaries:  summaries,
 	}
 
 	c.Header("Content-Type", "text/html")
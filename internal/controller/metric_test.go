@@ -72,6 +72,17 @@ func TestMetricHandler_UpdateOne(t *testing.T) {
 			wantStatus:  http.StatusOK,
 			wantJSON:    `{"id": "someGauge", "type": "gauge", "value": 123.0}`,
 		},
+		{
+			name: "CanUpdateGaugeWithLabels",
+			givePayload: &model.Metrics{
+				ID:     "someGauge",
+				MType:  model.Gauge,
+				Value:  &floatValue,
+				Labels: model.Labels{"host": "web-1"},
+			},
+			wantStatus: http.StatusOK,
+			wantJSON:   `{"id": "someGauge", "type": "gauge", "value": 123.0, "labels": {"host": "web-1"}}`,
+		},
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/itallix/go-metrics/internal/ingest/otlp"
+	"github.com/itallix/go-metrics/internal/logger"
+)
+
+// OTLP/HTTP content types.
+const (
+	otlpProtobuf = "application/x-protobuf"
+	otlpJSON     = "application/json"
+)
+
+// WithOTLPWriter enables the OTLP/HTTP metrics endpoint.
+func (mc *MetricController) WithOTLPWriter(writer *otlp.Writer) *MetricController {
+	mc.otlpWriter = writer
+	return mc
+}
+
+// OTLPMetrics stores the metrics of the OTLP ExportMetricsServiceRequest encoded as protobuf or JSON,
+// e.g. POST /v1/metrics. The response is encoded the same as the request: 200 with the partial success
+// of rejected data points, 400 in case of invalid requests or series names, 413 in case of too large bodies and 503
+// in case of storage errors, which are retried by exporters. Returns 415 for other content types.
+func (mc *MetricController) OTLPMetrics(c *gin.Context) {
+	if mc.otlpWriter == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(c.ContentType())
+	if contentType != otlpProtobuf && contentType != otlpJSON {
+		c.AbortWithStatus(http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := mc.readBody(c)
+	if err != nil {
+		abortOTLP(c, contentType, readBodyStatus(err), status.New(codes.InvalidArgument, "error reading request body"))
+		return
+	}
+	var req colmetricspb.ExportMetricsServiceRequest
+	if contentType == otlpJSON {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, &req)
+	} else {
+		err = proto.Unmarshal(body, &req)
+	}
+	if err != nil {
+		abortOTLP(c, contentType, http.StatusBadRequest, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
+
+	resp, err := mc.otlpWriter.Export(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log().Errorf("Cannot export otlp metrics: %v", err)
+		st := status.Convert(err)
+		code := http.StatusServiceUnavailable
+		if st.Code() == codes.InvalidArgument {
+			code = http.StatusBadRequest
+		}
+		abortOTLP(c, contentType, code, st)
+		return
+	}
+	logger.Log().Infof("Exporting %d otlp resource metrics completed.", len(req.GetResourceMetrics()))
+	writeOTLP(c, contentType, http.StatusOK, resp)
+}
+
+func abortOTLP(c *gin.Context, contentType string, code int, st *status.Status) {
+	writeOTLP(c, contentType, code, st.Proto())
+	c.Abort()
+}
+
+func writeOTLP(c *gin.Context, contentType string, code int, msg proto.Message) {
+	var (
+		data []byte
+		err  error
+	)
+	if contentType == otlpJSON {
+		data, err = protojson.Marshal(msg)
+	} else {
+		data, err = proto.Marshal(msg)
+	}
+	if err != nil {
+		logger.Log().Errorf("Cannot encode otlp response: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(code, contentType, data)
+}
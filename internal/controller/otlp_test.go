@@ -0,0 +1,110 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/itallix/go-metrics/internal/controller"
+	"github.com/itallix/go-metrics/internal/ingest/otlp"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+func TestMetricHandler_OTLPMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ctx := context.Background()
+	metricStorage := memory.NewMemStorage(ctx, nil, nil)
+	metricController := controller.NewMetricController(metricStorage).
+		WithMaxBodySize(1024).
+		WithOTLPWriter(otlp.NewWriter(metricStorage))
+	router.POST("/v1/metrics", metricController.OTLPMetrics)
+
+	protobuf, err := proto.Marshal(&colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{ScopeMetrics: []*metricspb.ScopeMetrics{{
+			Metrics: []*metricspb.Metric{{
+				Name: "queue.size",
+				Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+					{Value: &metricspb.NumberDataPoint_AsInt{AsInt: 4}},
+				}}},
+			}},
+		}}}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		giveContentType string
+		giveBody        []byte
+		wantStatus      int
+		wantJSON        string
+	}{
+		{
+			name:            "Protobuf",
+			giveContentType: "application/x-protobuf",
+			giveBody:        protobuf,
+			wantStatus:      http.StatusOK,
+		},
+		{
+			name:            "JSON",
+			giveContentType: "application/json; charset=utf-8",
+			giveBody: []byte(`{"resourceMetrics":[{
+				"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"checkout"}}]},
+				"scopeMetrics":[{"metrics":[{"name":"http.requests","sum":{"aggregationTemporality":1,"isMonotonic":true,
+					"dataPoints":[{"asInt":"5","timeUnixNano":"1700000000000000000",
+						"exemplars":[{"asInt":"1","traceId":"5b8efff798038103d269b633813fc60c"}]}]}},
+				{"name":"latency","exponentialHistogram":{"dataPoints":[{"count":"1"}]}}]}]}]}`),
+			wantStatus: http.StatusOK,
+			wantJSON: `{"partialSuccess":{"rejectedDataPoints":"1",
+				"errorMessage":"data point is rejected: metric latency: exponential histograms are not supported"}}`,
+		},
+		{
+			name:            "InvalidJSON",
+			giveContentType: "application/json",
+			giveBody:        []byte(`{"resourceMetrics":`),
+			wantStatus:      http.StatusBadRequest,
+		},
+		{
+			name:            "TooLarge",
+			giveContentType: "application/json",
+			giveBody:        bytes.Repeat([]byte(" "), 2048),
+			wantStatus:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:            "UnsupportedContentType",
+			giveContentType: "text/plain",
+			giveBody:        []byte("queue.size 4"),
+			wantStatus:      http.StatusUnsupportedMediaType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewReader(tt.giveBody))
+			req.Header.Set("Content-Type", tt.giveContentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantJSON != "" {
+				assert.JSONEq(t, tt.wantJSON, w.Body.String())
+			}
+		})
+	}
+
+	gauge := model.Metrics{ID: "queue.size", MType: model.Gauge}
+	require.NoError(t, metricStorage.Read(ctx, &gauge))
+	assert.InDelta(t, 4.0, *gauge.Value, 1e-9)
+	counter := model.Metrics{ID: "http.requests", MType: model.Counter, Labels: model.Labels{"service.name": "checkout"}}
+	require.NoError(t, metricStorage.Read(ctx, &counter))
+	assert.Equal(t, int64(5), *counter.Delta)
+}
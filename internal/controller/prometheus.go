@@ -0,0 +1,312 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+const (
+	ContentTypeTextFormat  = "text/plain; version=0.0.4; charset=utf-8"
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+	openMetricsMediaType = "application/openmetrics-text"
+	counterSuffix        = "_total"
+)
+
+// exposition describes the Prometheus format chosen for the response.
+type exposition struct {
+	contentType string
+	openMetrics bool
+}
+
+// family groups series sharing the same metric name in the exposition output.
+type family struct {
+	name    string
+	id      string
+	mtype   model.MetricType
+	samples []model.Metrics
+	// series holds the sanitized label sets of the samples, which must be unique within the family.
+	series map[string]struct{}
+}
+
+// PrometheusMetrics renders every metric from the storage in the Prometheus text format 0.0.4
+// or in the OpenMetrics 1.0.0 format, depending on the Accept header of the request.
+// Returns 500 in case of errors when reading from storage.
+func (mc *MetricController) PrometheusMetrics(c *gin.Context) {
+	ctx := c.Request.Context()
+	var series []model.Metrics
+	for _, read := range []struct {
+		name string
+		get  func(context.Context) ([]model.Metrics, error)
+	}{
+		{name: "counters", get: mc.metricsStorage.GetCounters},
+		{name: "gauges", get: mc.metricsStorage.GetGauges},
+		{name: "histograms", get: mc.metricsStorage.GetHistograms},
+		{name: "summaries", get: mc.metricsStorage.GetSummaries},
+	} {
+		metrics, err := read.get(ctx)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Error reading "+read.name+" from storage")
+			return
+		}
+		series = append(series, metrics...)
+	}
+
+	format := negotiateExposition(c.GetHeader("Accept"))
+	var buf bytes.Buffer
+	for _, f := range toFamilies(series, format.openMetrics) {
+		writeFamily(&buf, f, format.openMetrics)
+	}
+	if format.openMetrics {
+		buf.WriteString("# EOF\n")
+	}
+
+	c.Data(http.StatusOK, format.contentType, buf.Bytes())
+}
+
+// negotiateExposition picks OpenMetrics when the client prefers it over the plain text format.
+func negotiateExposition(accept string) exposition {
+	var openMetricsQ, textQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case openMetricsMediaType:
+			openMetricsQ = math.Max(openMetricsQ, q)
+		case "text/plain", "text/*", "*/*":
+			textQ = math.Max(textQ, q)
+		}
+	}
+	if openMetricsQ > 0 && openMetricsQ >= textQ {
+		return exposition{contentType: ContentTypeOpenMetrics, openMetrics: true}
+	}
+	return exposition{contentType: ContentTypeTextFormat}
+}
+
+// toFamilies groups series by sanitized metric name. When names of different types collide,
+// the later family gets the type as a suffix (e.g. "_gauge"), so every family has exactly one type.
+// Series colliding after sanitization, e.g. IDs "a.b" and "a_b" with the same labels, would be rejected
+// by Prometheus, so only the first of them is exposed and the others are skipped with a warning.
+// User labels named as the label added to histogram or summary samples are exposed with the "exported_" prefix.
+func toFamilies(series []model.Metrics, openMetrics bool) []*family {
+	families := make(map[string]*family)
+	for _, metric := range series {
+		name := SanitizeMetricName(metric.ID)
+		if metric.MType == model.Counter && openMetrics {
+			name = strings.TrimSuffix(name, counterSuffix)
+		}
+		if f, ok := families[name]; ok && f.mtype != metric.MType {
+			name += "_" + string(metric.MType)
+		}
+		f, ok := families[name]
+		if !ok {
+			f = &family{name: name, id: metric.ID, mtype: metric.MType, series: make(map[string]struct{})}
+			families[name] = f
+		}
+		if f.mtype != metric.MType {
+			logger.Log().Warnf("Skipping %s %s: family %s is of type %s", metric.MType, metric.SeriesKey(), name, f.mtype)
+			continue
+		}
+		labels, ok := sanitizeLabels(metric.Labels, reservedLabel(metric.MType))
+		if !ok {
+			logger.Log().Warnf("Skipping %s %s: label names collide after sanitization", metric.MType, metric.SeriesKey())
+			continue
+		}
+		key := labels.String()
+		if _, ok = f.series[key]; ok {
+			logger.Log().Warnf("Skipping %s %s: series %s%s is already exposed", metric.MType, metric.SeriesKey(),
+				name, key)
+			continue
+		}
+		f.series[key] = struct{}{}
+		metric.Labels = labels
+		f.samples = append(f.samples, metric)
+	}
+
+	result := make([]*family, 0, len(families))
+	for _, f := range families {
+		result = append(result, f)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].name < result[j].name
+	})
+	return result
+}
+
+func writeFamily(buf *bytes.Buffer, f *family, openMetrics bool) {
+	buf.WriteString("# HELP " + f.name + " " + string(f.mtype) + " " + escapeHelp(f.id) + " reported to go-metrics.\n")
+	buf.WriteString("# TYPE " + f.name + " " + string(f.mtype) + "\n")
+
+	sampleName := f.name
+	if f.mtype == model.Counter && openMetrics {
+		sampleName += counterSuffix
+	}
+	for _, sample := range f.samples {
+		switch f.mtype {
+		case model.Counter:
+			writeSample(buf, sampleName, sample.Labels, "", "", strconv.FormatInt(*sample.Delta, 10))
+		case model.Gauge:
+			writeSample(buf, sampleName, sample.Labels, "", "", formatFloat(*sample.Value))
+		case model.Histogram:
+			h := sample.Histogram
+			for _, b := range h.Buckets {
+				writeSample(buf, sampleName+"_bucket", sample.Labels, "le", formatFloat(b.UpperBound),
+					strconv.FormatUint(b.Count, 10))
+			}
+			writeSample(buf, sampleName+"_bucket", sample.Labels, "le", "+Inf", strconv.FormatUint(h.Count, 10))
+			writeSample(buf, sampleName+"_sum", sample.Labels, "", "", formatFloat(h.Sum))
+			writeSample(buf, sampleName+"_count", sample.Labels, "", "", strconv.FormatUint(h.Count, 10))
+		case model.Summary:
+			s := sample.Summary
+			for _, q := range s.Quantiles {
+				writeSample(buf, sampleName, sample.Labels, "quantile", formatFloat(q.Quantile), formatFloat(q.Value))
+			}
+			writeSample(buf, sampleName+"_sum", sample.Labels, "", "", formatFloat(s.Sum))
+			writeSample(buf, sampleName+"_count", sample.Labels, "", "", strconv.FormatUint(s.Count, 10))
+		}
+	}
+}
+
+// writeSample writes a single sample line. The optional extra label (e.g. "le" or "quantile")
+// is appended after the series labels.
+func writeSample(buf *bytes.Buffer, name string, labels model.Labels, extraName, extraValue, value string) {
+	buf.WriteString(name)
+	writeLabels(buf, labels, extraName, extraValue)
+	buf.WriteByte(' ')
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func writeLabels(buf *bytes.Buffer, labels model.Labels, extraName, extraValue string) {
+	if len(labels) == 0 && extraName == "" {
+		return
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(SanitizeLabelName(name))
+		buf.WriteString(`="`)
+		buf.WriteString(labelValueEscaper.Replace(labels[name]))
+		buf.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(extraName + `="` + extraValue + `"`)
+	}
+	buf.WriteByte('}')
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// reservedLabel gives the name of the label added to the samples of the metric type, empty when there is none.
+func reservedLabel(mtype model.MetricType) string {
+	switch mtype {
+	case model.Histogram:
+		return "le"
+	case model.Summary:
+		return "quantile"
+	default:
+		return ""
+	}
+}
+
+// sanitizeLabels returns the labels with sanitized names, the reserved name gets the "exported_" prefix.
+// Returns false when sanitized names collide.
+func sanitizeLabels(labels model.Labels, reserved string) (model.Labels, bool) {
+	if len(labels) == 0 {
+		return labels, true
+	}
+	sanitized := make(model.Labels, len(labels))
+	for name, value := range labels {
+		name = SanitizeLabelName(name)
+		if name == reserved {
+			name = "exported_" + name
+		}
+		if _, ok := sanitized[name]; ok {
+			return nil, false
+		}
+		sanitized[name] = value
+	}
+	return sanitized, true
+}
+
+// SanitizeMetricName turns an arbitrary metric ID into a valid Prometheus metric name
+// by replacing unsupported characters with underscores.
+func SanitizeMetricName(name string) string {
+	return sanitize(name, true)
+}
+
+// SanitizeLabelName turns an arbitrary label name into a valid Prometheus label name.
+func SanitizeLabelName(name string) string {
+	return sanitize(name, false)
+}
+
+func sanitize(name string, allowColon bool) string {
+	if name == "" {
+		return "_"
+	}
+	var sb strings.Builder
+	sb.Grow(len(name) + 1)
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':' && allowColon:
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,179 @@
+package controller_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/controller"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+func TestMetricHandler_PrometheusMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ctx := context.Background()
+	metricStorage := memory.NewMemStorage(ctx, nil, nil)
+	var (
+		counter int64 = 10
+		gauge         = 25.5
+	)
+	_ = metricStorage.Update(ctx, model.NewCounter("PollCount", &counter))
+	cpu := model.NewGauge("CPU.utilization", &gauge)
+	cpu.Labels = model.Labels{"core": "1", "host-name": `web "1"`}
+	_ = metricStorage.Update(ctx, cpu)
+	metricController := controller.NewMetricController(metricStorage)
+
+	router.GET("/metrics", metricController.PrometheusMetrics)
+
+	tests := []struct {
+		name            string
+		giveAccept      string
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "TextFormat",
+			wantContentType: controller.ContentTypeTextFormat,
+			wantBody: `# HELP CPU_utilization gauge CPU.utilization reported to go-metrics.
+# TYPE CPU_utilization gauge
+CPU_utilization{core="1",host_name="web \"1\""} 25.5
+# HELP PollCount counter PollCount reported to go-metrics.
+# TYPE PollCount counter
+PollCount 10
+`,
+		},
+		{
+			name: "OpenMetrics",
+			giveAccept: "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75," +
+				"text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+			wantContentType: controller.ContentTypeOpenMetrics,
+			wantBody: `# HELP CPU_utilization gauge CPU.utilization reported to go-metrics.
+# TYPE CPU_utilization gauge
+CPU_utilization{core="1",host_name="web \"1\""} 25.5
+# HELP PollCount counter PollCount reported to go-metrics.
+# TYPE PollCount counter
+PollCount_total 10
+# EOF
+`,
+		},
+		{
+			name:            "PrefersText",
+			giveAccept:      "text/plain;version=0.0.4,application/openmetrics-text;q=0.5",
+			wantContentType: controller.ContentTypeTextFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.giveAccept != "" {
+				req.Header.Set("Accept", tt.giveAccept)
+			}
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusOK, resp.Code, "handler returned wrong status code")
+			assert.Equal(t, tt.wantContentType, resp.Header().Get("Content-Type"))
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, resp.Body.String(), "handler returned wrong message")
+			}
+		})
+	}
+}
+
+func TestMetricHandler_PrometheusDistributions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ctx := context.Background()
+	metricStorage := memory.NewMemStorage(ctx, nil, nil)
+	histogram := model.NewHistogramValue([]float64{0.1, 1})
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	latency := model.NewHistogram("latency", histogram)
+	latency.Labels = model.Labels{"host": "a"}
+	_ = metricStorage.Update(ctx, latency)
+	rpc := model.NewSummary("rpc", &model.SummaryValue{
+		Quantiles: []model.Quantile{{Quantile: 0.99, Value: 2}}, Sum: 3, Count: 2,
+	})
+	_ = metricStorage.Update(ctx, rpc)
+	// user labels named as the bucket or quantile label do not duplicate it
+	size := model.NewHistogram("size", model.NewHistogramValue(nil))
+	size.Labels = model.Labels{"le": "x"}
+	_ = metricStorage.Update(ctx, size)
+	rpc.Labels = model.Labels{"quantile": "y"}
+	_ = metricStorage.Update(ctx, rpc)
+	metricController := controller.NewMetricController(metricStorage)
+
+	router.GET("/metrics", metricController.PrometheusMetrics)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code, "handler returned wrong status code")
+	assert.Equal(t, `# HELP latency histogram latency reported to go-metrics.
+# TYPE latency histogram
+latency_bucket{host="a",le="0.1"} 1
+latency_bucket{host="a",le="1"} 2
+latency_bucket{host="a",le="+Inf"} 2
+latency_sum{host="a"} 0.55
+latency_count{host="a"} 2
+# HELP rpc summary rpc reported to go-metrics.
+# TYPE rpc summary
+rpc{quantile="0.99"} 2
+rpc_sum 3
+rpc_count 2
+rpc{exported_quantile="y",quantile="0.99"} 2
+rpc_sum{exported_quantile="y"} 3
+rpc_count{exported_quantile="y"} 2
+# HELP size histogram size reported to go-metrics.
+# TYPE size histogram
+size_bucket{exported_le="x",le="+Inf"} 0
+size_sum{exported_le="x"} 0
+size_count{exported_le="x"} 0
+`, resp.Body.String())
+}
+
+func TestSanitizeMetricName(t *testing.T) {
+	assert.Equal(t, "CPUutilization3", controller.SanitizeMetricName("CPUutilization3"))
+	assert.Equal(t, "_3xx_responses", controller.SanitizeMetricName("3xx-responses"))
+	assert.Equal(t, "http:requests_total", controller.SanitizeMetricName("http:requests.total"))
+	assert.Equal(t, "host_name", controller.SanitizeLabelName("host:name"))
+}
+
+func TestMetricHandler_PrometheusCollisions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ctx := context.Background()
+	metricStorage := memory.NewMemStorage(ctx, nil, nil)
+	v1, v2, v3, v4 := 1.0, 2.0, 3.0, 4.0
+	gauges := []model.Metrics{
+		*model.NewGauge("a.b", &v1),
+		*model.NewGauge("a_b", &v2),
+		*model.NewGauge("a_b", &v3),
+		*model.NewGauge("a_b", &v4),
+	}
+	gauges[2].Labels = model.Labels{"host": "web-1"}
+	gauges[3].Labels = model.Labels{"k.v": "x", "k_v": "y"}
+	require.NoError(t, metricStorage.UpdateBatch(ctx, gauges))
+	metricController := controller.NewMetricController(metricStorage)
+	router.GET("/metrics", metricController.PrometheusMetrics)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `# HELP a_b gauge a.b reported to go-metrics.
+# TYPE a_b gauge
+a_b 1
+a_b{host="web-1"} 3
+`, resp.Body.String(), "colliding series are exposed once")
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+// DefaultRangeWindow is used as a query range when "from" is omitted.
+const DefaultRangeWindow = time.Hour
+
+// RangeResult is a JSON response with the history of a single series.
+type RangeResult struct {
+	ID      string           `json:"id"`
+	MType   model.MetricType `json:"type"`
+	Labels  model.Labels     `json:"labels,omitempty"`
+	Samples []model.Sample   `json:"samples"`
+}
+
+// RangeQuery returns the history of a counter or gauge series, e.g.
+// GET /api/v1/range?id=Alloc&type=gauge&label=host=web-1&from=2024-01-01T00:00:00Z&to=1704070800&step=1m.
+// Time bounds accept RFC 3339 or unix seconds and default to the last hour, step accepts a duration
+// or a number of seconds and must result in at most model.MaxRangePoints points, the same limit applies to raw
+// samples returned without step. Returns 400 in case of invalid parameters and 500 in case of storage errors.
+func (mc *MetricController) RangeQuery(c *gin.Context) {
+	query, err := parseRangeQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	samples, err := mc.metricsStorage.ReadRange(c.Request.Context(), query)
+	if errors.Is(err, storage.ErrTooManySamples) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("exceeded maximum of %d samples, set step or shorten the range", model.MaxRangePoints),
+		})
+		return
+	}
+	if errors.Is(err, storage.ErrMetricNotSupported) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "history is available for counters and gauges only",
+		})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "error reading history from storage",
+		})
+		return
+	}
+	if samples == nil {
+		samples = []model.Sample{}
+	}
+
+	c.JSON(http.StatusOK, RangeResult{
+		ID:      query.ID,
+		MType:   query.MType,
+		Labels:  query.Labels,
+		Samples: samples,
+	})
+}
+
+func parseRangeQuery(c *gin.Context) (*model.RangeQuery, error) {
+	query := &model.RangeQuery{
+		ID:    c.Query("id"),
+		MType: model.MetricType(c.Query("type")),
+		To:    time.Now(),
+	}
+	if query.ID == "" || query.MType == "" {
+		return nil, errors.New("id and type are required")
+	}
+
+	for _, label := range c.QueryArray("label") {
+		name, value, ok := strings.Cut(label, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid label %q, expected name=value", label)
+		}
+		if query.Labels == nil {
+			query.Labels = make(model.Labels)
+		}
+		query.Labels[name] = value
+	}
+
+	var err error
+	if to := c.Query("to"); to != "" {
+		if query.To, err = parseTime(to); err != nil {
+			return nil, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	query.From = query.To.Add(-DefaultRangeWindow)
+	if from := c.Query("from"); from != "" {
+		if query.From, err = parseTime(from); err != nil {
+			return nil, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if query.From.After(query.To) {
+		return nil, errors.New("from must not be after to")
+	}
+	if step := c.Query("step"); step != "" {
+		if query.Step, err = parseStep(step); err != nil {
+			return nil, fmt.Errorf("invalid step: %w", err)
+		}
+		if query.Step > 0 && query.To.Sub(query.From)/query.Step >= model.MaxRangePoints {
+			return nil, fmt.Errorf("exceeded maximum of %d points, increase step or shorten the range",
+				model.MaxRangePoints)
+		}
+	}
+	return query, nil
+}
+
+// parseTime accepts RFC 3339 timestamps and (fractional) unix seconds.
+func parseTime(s string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, frac := int64(seconds), seconds-float64(int64(seconds))
+		return time.Unix(sec, int64(frac*float64(time.Second))), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// parseStep accepts Go durations (e.g. "30s", "5m") and plain number of seconds.
+func parseStep(s string) (time.Duration, error) {
+	step, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, parseErr := strconv.ParseFloat(s, 64)
+		if parseErr != nil {
+			return 0, err
+		}
+		step = time.Duration(seconds * float64(time.Second))
+	}
+	if step < 0 {
+		return 0, errors.New("step must not be negative")
+	}
+	return step, nil
+}
@@ -0,0 +1,107 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/controller"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+func TestMetricHandler_RangeQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ctx := context.Background()
+	metricStorage := memory.NewMemStorage(ctx, nil, nil)
+	for _, v := range []float64{1, 2, 3} {
+		gauge := model.NewGauge("Alloc", &v)
+		gauge.Labels = model.Labels{"host": "web-1"}
+		require.NoError(t, metricStorage.Update(ctx, gauge))
+	}
+	metricController := controller.NewMetricController(metricStorage)
+
+	router.GET("/api/v1/range", metricController.RangeQuery)
+
+	from := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	tests := []struct {
+		name        string
+		giveQuery   string
+		wantCode    int
+		wantSamples []float64
+	}{
+		{
+			name:        "Raw",
+			giveQuery:   "?id=Alloc&type=gauge&label=host=web-1&from=" + from,
+			wantCode:    http.StatusOK,
+			wantSamples: []float64{1, 2, 3},
+		},
+		{
+			name:        "Downsampled",
+			giveQuery:   "?id=Alloc&type=gauge&label=host=web-1&step=1h",
+			wantCode:    http.StatusOK,
+			wantSamples: []float64{3},
+		},
+		{
+			name:        "OtherSeries",
+			giveQuery:   "?id=Alloc&type=gauge",
+			wantCode:    http.StatusOK,
+			wantSamples: []float64{},
+		},
+		{
+			name:      "MissingID",
+			giveQuery: "?type=gauge",
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "InvalidStep",
+			giveQuery: "?id=Alloc&type=gauge&step=abc",
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "TooManyPoints",
+			giveQuery: "?id=Alloc&type=gauge&from=0&step=1ns",
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "InvalidRange",
+			giveQuery: "?id=Alloc&type=gauge&from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z",
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "NotSupported",
+			giveQuery: "?id=Alloc&type=histogram",
+			wantCode:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/range"+tt.giveQuery, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var result controller.RangeResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			assert.Equal(t, "Alloc", result.ID)
+			values := make([]float64, 0, len(result.Samples))
+			for _, sample := range result.Samples {
+				values = append(values, sample.Value)
+			}
+			assert.Equal(t, tt.wantSamples, values)
+		})
+	}
+}
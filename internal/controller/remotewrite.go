@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/itallix/go-metrics/internal/ingest/remotewrite"
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// WithRemoteWriter enables the Prometheus remote write endpoint.
+func (mc *MetricController) WithRemoteWriter(writer *remotewrite.Writer) *MetricController {
+	mc.remoteWriter = writer
+	return mc
+}
+
+// RemoteWrite stores the latest samples of the series of a snappy compressed Prometheus WriteRequest,
+// e.g. POST /api/v1/write. Returns 400 in case of invalid requests and 413 in case of too large bodies, which are
+// not retried by Prometheus, 500 in case of storage errors and 204 when the samples are stored.
+func (mc *MetricController) RemoteWrite(c *gin.Context) {
+	if mc.remoteWriter == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	body, err := mc.readBody(c)
+	if err != nil {
+		c.AbortWithStatusJSON(readBodyStatus(err), gin.H{
+			"error": "error reading request body",
+		})
+		return
+	}
+	req, err := remotewrite.Decode(body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	err = mc.remoteWriter.Write(c.Request.Context(), req)
+	if errors.Is(err, remotewrite.ErrInvalidRequest) || errors.Is(err, model.ErrInvalidSeries) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		logger.Log().Errorf("Cannot write remote write samples: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "error writing samples to storage",
+		})
+		return
+	}
+
+	logger.Log().Infof("Writing %d remote write series completed.", len(req.GetTimeseries()))
+	c.Status(http.StatusNoContent)
+}
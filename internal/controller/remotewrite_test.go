@@ -0,0 +1,83 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/itallix/go-metrics/internal/controller"
+	"github.com/itallix/go-metrics/internal/ingest/remotewrite"
+	"github.com/itallix/go-metrics/internal/ingest/remotewrite/prompb"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+func TestMetricHandler_RemoteWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ctx := context.Background()
+	metricStorage := memory.NewMemStorage(ctx, nil, nil)
+	metricController := controller.NewMetricController(metricStorage).
+		WithMaxBodySize(1024).
+		WithRemoteWriter(remotewrite.NewWriter(metricStorage))
+	router.POST("/api/v1/write", metricController.RemoteWrite)
+
+	encode := func(req *prompb.WriteRequest) []byte {
+		data, err := proto.Marshal(req)
+		require.NoError(t, err)
+		return snappy.Encode(nil, data)
+	}
+	tests := []struct {
+		name       string
+		giveBody   []byte
+		wantStatus int
+	}{
+		{
+			name: "Valid",
+			giveBody: encode(&prompb.WriteRequest{Timeseries: []*prompb.TimeSeries{{
+				Labels:  []*prompb.Label{{Name: "__name__", Value: "node_load1"}, {Name: "instance", Value: "web-1"}},
+				Samples: []*prompb.Sample{{Value: 0.25, Timestamp: 1700000000000}},
+			}}}),
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "NotCompressed",
+			giveBody:   []byte("node_load1 0.25"),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "TooLarge",
+			giveBody:   bytes.Repeat([]byte{0}, 2048),
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "MissingName",
+			giveBody: encode(&prompb.WriteRequest{Timeseries: []*prompb.TimeSeries{{
+				Samples: []*prompb.Sample{{Value: 1}},
+			}}}),
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(tt.giveBody))
+			req.Header.Set("Content-Encoding", "snappy")
+			req.Header.Set("Content-Type", "application/x-protobuf")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	gauge := model.Metrics{ID: "node_load1", MType: model.Gauge, Labels: model.Labels{"instance": "web-1"}}
+	require.NoError(t, metricStorage.Read(ctx, &gauge))
+	assert.InDelta(t, 0.25, *gauge.Value, 1e-9)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	pb "github.com/itallix/go-metrics/internal/grpc/proto"
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// ToProto converts the metric into its protobuf representation.
+func ToProto(m *model.Metrics) *pb.Metric {
+	metric := &pb.Metric{
+		Id:     m.ID,
+		Delta:  m.Delta,
+		Value:  m.Value,
+		Labels: m.Labels,
+	}
+	switch m.MType {
+	case model.Counter:
+		metric.Mtype = pb.Metric_M_TYPE_COUNTER
+	case model.Gauge:
+		metric.Mtype = pb.Metric_M_TYPE_GAUGE
+	case model.Histogram:
+		metric.Mtype = pb.Metric_M_TYPE_HISTOGRAM
+		if m.Histogram != nil {
+			metric.Histogram = &pb.Histogram{Sum: m.Histogram.Sum, Count: m.Histogram.Count}
+			for _, b := range m.Histogram.Buckets {
+				metric.Histogram.Buckets = append(metric.Histogram.Buckets,
+					&pb.Histogram_Bucket{UpperBound: b.UpperBound, Count: b.Count})
+			}
+		}
+	case model.Summary:
+		metric.Mtype = pb.Metric_M_TYPE_SUMMARY
+		if m.Summary != nil {
+			metric.Summary = &pb.Summary{Sum: m.Summary.Sum, Count: m.Summary.Count}
+			for _, q := range m.Summary.Quantiles {
+				metric.Summary.Quantiles = append(metric.Summary.Quantiles,
+					&pb.Summary_Quantile{Quantile: q.Quantile, Value: q.Value})
+			}
+		}
+	}
+	return metric
+}
+
+// FromProto converts the protobuf metric into the model. It returns false for unspecified metric types.
+func FromProto(metric *pb.Metric) (*model.Metrics, bool) {
+	var m *model.Metrics
+	switch metric.GetMtype() {
+	case pb.Metric_M_TYPE_COUNTER:
+		m = model.NewCounter(metric.GetId(), metric.Delta)
+	case pb.Metric_M_TYPE_GAUGE:
+		m = model.NewGauge(metric.GetId(), metric.Value)
+	case pb.Metric_M_TYPE_HISTOGRAM:
+		m = model.NewHistogram(metric.GetId(), nil)
+		if h := metric.GetHistogram(); h != nil {
+			m.Histogram = &model.HistogramValue{Sum: h.GetSum(), Count: h.GetCount()}
+			for _, b := range h.GetBuckets() {
+				m.Histogram.Buckets = append(m.Histogram.Buckets,
+					model.Bucket{UpperBound: b.GetUpperBound(), Count: b.GetCount()})
+			}
+		}
+	case pb.Metric_M_TYPE_SUMMARY:
+		m = model.NewSummary(metric.GetId(), nil)
+		if s := metric.GetSummary(); s != nil {
+			m.Summary = &model.SummaryValue{Sum: s.GetSum(), Count: s.GetCount()}
+			for _, q := range s.GetQuantiles() {
+				m.Summary.Quantiles = append(m.Summary.Quantiles,
+					model.Quantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+			}
+		}
+	default:
+		return nil, false
+	}
+	if len(metric.GetLabels()) > 0 {
+		m.Labels = metric.GetLabels()
+	}
+	return m, true
+}
+
+// MTypeFromProto converts the protobuf metric type into the model. It returns false for unspecified types.
+func MTypeFromProto(mtype pb.Metric_MType) (model.MetricType, bool) {
+	switch mtype {
+	case pb.Metric_M_TYPE_COUNTER:
+		return model.Counter, true
+	case pb.Metric_M_TYPE_GAUGE:
+		return model.Gauge, true
+	case pb.Metric_M_TYPE_HISTOGRAM:
+		return model.Histogram, true
+	case pb.Metric_M_TYPE_SUMMARY:
+		return model.Summary, true
+	default:
+		return "", false
+	}
+}
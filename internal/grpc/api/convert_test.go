@@ -0,0 +1,33 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/itallix/go-metrics/internal/grpc/proto"
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+func TestConvert_RoundTrip(t *testing.T) {
+	c, g := int64(5), 1.5
+	histogram := model.NewHistogramValue([]float64{1, 2})
+	histogram.Observe(1.5)
+	counter := model.NewCounter("c0", &c)
+	counter.Labels = model.Labels{"host": "a"}
+
+	for _, metric := range []*model.Metrics{
+		counter,
+		model.NewGauge("g0", &g),
+		model.NewHistogram("h0", histogram),
+		model.NewSummary("s0", &model.SummaryValue{Quantiles: []model.Quantile{{Quantile: 0.5, Value: 1}}, Sum: 2, Count: 1}),
+	} {
+		converted, ok := FromProto(ToProto(metric))
+		require.True(t, ok)
+		assert.Equal(t, metric, converted)
+	}
+
+	_, ok := FromProto(&pb.Metric{Id: "unknown"})
+	assert.False(t, ok)
+}
@@ -2,11 +2,9 @@ package api
 
 import (
 	"context"
-	"errors"
-	"fmt"
 
-	"google.golang.org/grpc/metadata"
-	"google.golang.org/protobuf/proto"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 
 	pb "github.com/itallix/go-metrics/internal/grpc/proto"
 	"github.com/itallix/go-metrics/internal/logger"
@@ -15,46 +13,49 @@ import (
 	"github.com/itallix/go-metrics/internal/storage"
 )
 
+// Server implements the Metrics service on top of the storage. Hash verification, decryption
+// and trusted subnet checks are done by the interceptors of the interceptor package.
 type Server struct {
 	pb.UnimplementedMetricsServer
 
 	metricsStorage storage.Storage
-	hashService    service.HashService
+	hub            *storage.Hub
 }
 
-func NewServer(metricsStorage storage.Storage, hashService service.HashService) *Server {
+func NewServer(metricsStorage storage.Storage) *Server {
 	return &Server{
 		metricsStorage: metricsStorage,
-		hashService:    hashService,
 	}
 }
 
+// WithHub enables Watch, which streams the metrics published to the hub.
+func (srv *Server) WithHub(hub *storage.Hub) *Server {
+	srv.hub = hub
+	return srv
+}
+
+// AgentIdentity returns the common name of the verified client certificate of the call, empty without mutual TLS.
+func AgentIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ""
+	}
+	return service.PeerIdentity(&tlsInfo.State)
+}
+
 func (srv *Server) UpdateMetrics(ctx context.Context, in *pb.UpdateMetricsRequest) (*pb.UpdateMetricsResponse, error) {
 	var (
 		batch    []model.Metrics
 		response pb.UpdateMetricsResponse
 	)
 
-	md, ok := metadata.FromIncomingContext(ctx)
-	if ok {
-		hash := md.Get(model.HashSha256Header)
-		if len(hash) > 0 && srv.hashService != nil {
-			reqBytes, err := proto.Marshal(in)
-			if err != nil {
-				return nil, fmt.Errorf("cannot marshall req to bytes: %w", err)
-			}
-			if !srv.hashService.Matches(reqBytes, hash[0]) {
-				return nil, errors.New("hash doesn't match")
-			}
-		}
-	}
-
 	for _, metric := range in.Metrics {
-		switch metric.GetMtype() {
-		case pb.Metric_M_TYPE_COUNTER:
-			batch = append(batch, *model.NewCounter(metric.Id, metric.Delta))
-		case pb.Metric_M_TYPE_GAUGE:
-			batch = append(batch, *model.NewGauge(metric.Id, metric.Value))
+		if m, ok := FromProto(metric); ok {
+			batch = append(batch, *m)
 		}
 	}
 
@@ -62,7 +63,7 @@ func (srv *Server) UpdateMetrics(ctx context.Context, in *pb.UpdateMetricsReques
 		return nil, err
 	}
 
-	logger.Log().Info("Successfully saved metrics.")
+	logger.Log().Infow("Successfully saved metrics.", "agent", AgentIdentity(ctx))
 
 	response.Metrics = in.GetMetrics()
 
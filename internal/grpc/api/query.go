@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/itallix/go-metrics/internal/grpc/proto"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+const (
+	// DefaultPageSize is the number of metrics returned by ListMetrics when the page size is not set.
+	DefaultPageSize = 100
+	// MaxPageSize caps the page size requested by the client.
+	MaxPageSize = 1000
+	// MaxBatchKeys limits the number of keys of a single GetMetrics request.
+	MaxBatchKeys = 1000
+)
+
+// listOrder is the order of metric types in ListMetrics pages, series of every type are ordered by series key.
+var listOrder = []model.MetricType{model.Counter, model.Gauge, model.Histogram, model.Summary}
+
+// GetMetric returns the current value of the series, NotFound when the series does not exist.
+func (srv *Server) GetMetric(ctx context.Context, in *pb.MetricKey) (*pb.Metric, error) {
+	metric, err := keyToMetric(in)
+	if err != nil {
+		return nil, err
+	}
+	if err = srv.metricsStorage.Read(ctx, metric); err != nil {
+		if errors.Is(err, storage.ErrMetricNotFound) {
+			return nil, status.Errorf(codes.NotFound, "metric %s of type %s is not found", metric.SeriesKey(),
+				metric.MType)
+		}
+		return nil, status.Errorf(codes.Internal, "cannot read metric: %v", err)
+	}
+	return ToProto(metric), nil
+}
+
+// GetMetrics reads the requested series, the series that do not exist are returned as missing keys.
+func (srv *Server) GetMetrics(ctx context.Context, in *pb.GetMetricsRequest) (*pb.GetMetricsResponse, error) {
+	if len(in.GetKeys()) > MaxBatchKeys {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d keys can be requested", MaxBatchKeys)
+	}
+	var response pb.GetMetricsResponse
+	for _, key := range in.GetKeys() {
+		metric, err := keyToMetric(key)
+		if err != nil {
+			return nil, err
+		}
+		if err = srv.metricsStorage.Read(ctx, metric); err != nil {
+			if errors.Is(err, storage.ErrMetricNotFound) {
+				response.Missing = append(response.Missing, key)
+				continue
+			}
+			return nil, status.Errorf(codes.Internal, "cannot read metric: %v", err)
+		}
+		response.Metrics = append(response.Metrics, ToProto(metric))
+	}
+	return &response, nil
+}
+
+// ListMetrics returns a page of metrics whose id starts with the requested prefix. Metrics are ordered by type
+// and series key, the page token is the position of the last returned metric in this order, so pages stay
+// consistent while new series are added.
+func (srv *Server) ListMetrics(ctx context.Context, in *pb.ListMetricsRequest) (*pb.ListMetricsResponse, error) {
+	pageSize := int(in.GetPageSize())
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "page size must not be negative")
+	case pageSize == 0:
+		pageSize = DefaultPageSize
+	case pageSize > MaxPageSize:
+		pageSize = MaxPageSize
+	}
+	types := listOrder
+	if in.GetMtype() != pb.Metric_M_TYPE_UNSPECIFIED {
+		mtype, ok := MTypeFromProto(in.GetMtype())
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown metric type %v", in.GetMtype())
+		}
+		types = []model.MetricType{mtype}
+	}
+	after, err := parsePageToken(in.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		response pb.ListMetricsResponse
+		last     pageToken
+	)
+	for _, mtype := range types {
+		if typeIndex(mtype) < typeIndex(after.mtype) {
+			continue
+		}
+		series, err := srv.series(ctx, mtype)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "cannot read %s metrics: %v", mtype, err)
+		}
+		// storages order labels differently, so the order is fixed here to keep the page token meaningful
+		sort.Slice(series, func(i, j int) bool {
+			return series[i].SeriesKey() < series[j].SeriesKey()
+		})
+		for i := range series {
+			metric := &series[i]
+			key := metric.SeriesKey()
+			if !strings.HasPrefix(metric.ID, in.GetPrefix()) || (mtype == after.mtype && key <= after.seriesKey) {
+				continue
+			}
+			if len(response.Metrics) == pageSize {
+				response.NextPageToken = last.String()
+				return &response, nil
+			}
+			response.Metrics = append(response.Metrics, ToProto(metric))
+			last = pageToken{mtype: mtype, seriesKey: key}
+		}
+	}
+	return &response, nil
+}
+
+func (srv *Server) series(ctx context.Context, mtype model.MetricType) ([]model.Metrics, error) {
+	switch mtype {
+	case model.Counter:
+		return srv.metricsStorage.GetCounters(ctx)
+	case model.Gauge:
+		return srv.metricsStorage.GetGauges(ctx)
+	case model.Histogram:
+		return srv.metricsStorage.GetHistograms(ctx)
+	default:
+		return srv.metricsStorage.GetSummaries(ctx)
+	}
+}
+
+func keyToMetric(key *pb.MetricKey) (*model.Metrics, error) {
+	mtype, ok := MTypeFromProto(key.GetMtype())
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown metric type %v", key.GetMtype())
+	}
+	if key.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "metric id is required")
+	}
+	metric := &model.Metrics{ID: key.GetId(), MType: mtype}
+	if len(key.GetLabels()) > 0 {
+		metric.Labels = key.GetLabels()
+	}
+	return metric, nil
+}
+
+// pageToken points to the last metric of the previous page.
+type pageToken struct {
+	mtype     model.MetricType
+	seriesKey string
+}
+
+func (t pageToken) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(string(t.mtype) + "\n" + t.seriesKey))
+}
+
+func parsePageToken(token string) (pageToken, error) {
+	if token == "" {
+		return pageToken{}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return pageToken{}, status.Error(codes.InvalidArgument, "invalid page token")
+	}
+	mtype, seriesKey, ok := strings.Cut(string(data), "\n")
+	if !ok || typeIndex(model.MetricType(mtype)) < 0 {
+		return pageToken{}, status.Error(codes.InvalidArgument, "invalid page token")
+	}
+	return pageToken{mtype: model.MetricType(mtype), seriesKey: seriesKey}, nil
+}
+
+// typeIndex returns the position of the type in listOrder, -1 for unknown types.
+func typeIndex(mtype model.MetricType) int {
+	for i, t := range listOrder {
+		if t == mtype {
+			return i
+		}
+	}
+	return -1
+}
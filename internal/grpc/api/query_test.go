@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/itallix/go-metrics/internal/grpc/proto"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+func TestServer_GetMetric(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	c := int64(5)
+	counter := model.NewCounter("c0", &c)
+	counter.Labels = model.Labels{"host": "a"}
+	require.NoError(t, s.Update(ctx, counter))
+	client := startServer(t, NewServer(s))
+
+	metric, err := client.GetMetric(ctx, &pb.MetricKey{Id: "c0", Mtype: pb.Metric_M_TYPE_COUNTER,
+		Labels: map[string]string{"host": "a"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), metric.GetDelta())
+
+	tests := []struct {
+		name     string
+		key      *pb.MetricKey
+		wantCode codes.Code
+	}{
+		{name: "other labels", key: &pb.MetricKey{Id: "c0", Mtype: pb.Metric_M_TYPE_COUNTER},
+			wantCode: codes.NotFound},
+		{name: "other type", key: &pb.MetricKey{Id: "c0", Mtype: pb.Metric_M_TYPE_GAUGE,
+			Labels: map[string]string{"host": "a"}}, wantCode: codes.NotFound},
+		{name: "no type", key: &pb.MetricKey{Id: "c0"}, wantCode: codes.InvalidArgument},
+		{name: "no id", key: &pb.MetricKey{Mtype: pb.Metric_M_TYPE_COUNTER}, wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err = client.GetMetric(ctx, tt.key)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestServer_GetMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	c, g := int64(5), 1.5
+	require.NoError(t, s.UpdateBatch(ctx, []model.Metrics{*model.NewCounter("c0", &c), *model.NewGauge("g0", &g)}))
+	client := startServer(t, NewServer(s))
+
+	missing := &pb.MetricKey{Id: "g1", Mtype: pb.Metric_M_TYPE_GAUGE}
+	response, err := client.GetMetrics(ctx, &pb.GetMetricsRequest{Keys: []*pb.MetricKey{
+		{Id: "c0", Mtype: pb.Metric_M_TYPE_COUNTER},
+		missing,
+		{Id: "g0", Mtype: pb.Metric_M_TYPE_GAUGE},
+	}})
+	require.NoError(t, err)
+	require.Len(t, response.GetMetrics(), 2)
+	assert.Equal(t, int64(5), response.GetMetrics()[0].GetDelta())
+	assert.InDelta(t, 1.5, response.GetMetrics()[1].GetValue(), 0.0001)
+	require.Len(t, response.GetMissing(), 1)
+	assert.Equal(t, "g1", response.GetMissing()[0].GetId())
+}
+
+func TestServer_ListMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	var batch []model.Metrics
+	for i := 0; i < 5; i++ {
+		c, g := int64(i), float64(i)
+		batch = append(batch, *model.NewCounter("app_c"+strconv.Itoa(i), &c), *model.NewGauge("app_g"+strconv.Itoa(i), &g))
+	}
+	g := 1.0
+	batch = append(batch, *model.NewGauge("other", &g))
+	require.NoError(t, s.UpdateBatch(ctx, batch))
+	client := startServer(t, NewServer(s))
+
+	tests := []struct {
+		name     string
+		request  *pb.ListMetricsRequest
+		wantIDs  []string
+		wantCode codes.Code
+	}{
+		{
+			name:    "prefix over all types",
+			request: &pb.ListMetricsRequest{Prefix: "app_", PageSize: 4},
+			wantIDs: []string{"app_c0", "app_c1", "app_c2", "app_c3", "app_c4", "app_g0", "app_g1", "app_g2", "app_g3",
+				"app_g4"},
+		},
+		{
+			name:    "single type",
+			request: &pb.ListMetricsRequest{Mtype: pb.Metric_M_TYPE_GAUGE, PageSize: 3},
+			wantIDs: []string{"app_g0", "app_g1", "app_g2", "app_g3", "app_g4", "other"},
+		},
+		{
+			name:     "invalid token",
+			request:  &pb.ListMetricsRequest{PageToken: "!"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "negative page size",
+			request:  &pb.ListMetricsRequest{PageSize: -1},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			pages := 0
+			for {
+				response, err := client.ListMetrics(ctx, tt.request)
+				if tt.wantCode != codes.OK {
+					assert.Equal(t, tt.wantCode, status.Code(err))
+					return
+				}
+				require.NoError(t, err)
+				require.LessOrEqual(t, len(response.GetMetrics()), int(tt.request.GetPageSize()))
+				for _, metric := range response.GetMetrics() {
+					ids = append(ids, metric.GetId())
+				}
+				pages++
+				if response.GetNextPageToken() == "" {
+					break
+				}
+				tt.request.PageToken = response.GetNextPageToken()
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+			assert.Equal(t, (len(tt.wantIDs)+int(tt.request.GetPageSize())-1)/int(tt.request.GetPageSize()), pages)
+		})
+	}
+}
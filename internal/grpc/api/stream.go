@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/itallix/go-metrics/internal/grpc/proto"
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// MaxStreamBatch limits the number of metrics of a single streamed batch, which is kept in memory until it is stored.
+const MaxStreamBatch = 10000
+
+// StreamMetrics receives metrics until the client closes the stream. The metrics are stored by batches marked
+// by the client: the metric with the batch set ends the batch, which is stored with a single UpdateBatch and
+// acknowledged with the StreamSummary of the batch. Metrics received after the last batch are stored when
+// the client closes the stream, so a stream that fails in the middle of a batch stores nothing of it and the batch
+// can be sent again. Receiving is paused while a batch is being stored, so a slow storage throttles the client
+// through the stream flow control.
+func (srv *Server) StreamMetrics(stream grpc.BidiStreamingServer[pb.Metric, pb.StreamSummary]) error {
+	ctx := stream.Context()
+	var (
+		summary pb.StreamSummary
+		batch   []model.Metrics
+	)
+	store := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := srv.metricsStorage.UpdateBatch(ctx, batch); err != nil {
+			return status.Errorf(codes.Internal, "cannot store metrics: %v", err)
+		}
+		summary.Stored += uint64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		metric, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			if err = store(); err != nil {
+				return err
+			}
+			logger.Log().Infow("Metrics stream is closed.", "received", summary.Received,
+				"stored", summary.Stored, "agent", AgentIdentity(ctx))
+			summary.Batch = 0
+			return stream.Send(&summary)
+		}
+		if err != nil {
+			return err
+		}
+		summary.Received++
+		if m, ok := FromProto(metric); ok {
+			batch = append(batch, *m)
+		}
+		if len(batch) > MaxStreamBatch {
+			return status.Errorf(codes.InvalidArgument, "batch exceeds %d metrics", MaxStreamBatch)
+		}
+		if metric.GetBatch() == 0 {
+			continue
+		}
+		if err = store(); err != nil {
+			return err
+		}
+		summary.Batch = metric.GetBatch()
+		if err = stream.Send(&summary); err != nil {
+			return err
+		}
+	}
+}
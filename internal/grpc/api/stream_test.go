@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/itallix/go-metrics/internal/grpc/proto"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+func startServer(t *testing.T, srv *Server) pb.MetricsClient {
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	pb.RegisterMetricsServer(grpcServer, srv)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewMetricsClient(conn)
+}
+
+func TestServer_StreamMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	client := startServer(t, NewServer(s))
+	labels := model.Labels{"host": "a", "zone": "b"}
+	read := func() int64 {
+		counter := model.Metrics{ID: "c0", MType: model.Counter, Labels: labels}
+		require.NoError(t, s.Read(ctx, &counter))
+		return *counter.Delta
+	}
+	send := func(stream pb.Metrics_StreamMetricsClient, n int, batch uint64) {
+		for i := 0; i < n; i++ {
+			delta := int64(1)
+			counter := model.NewCounter("c0", &delta)
+			counter.Labels = labels
+			msg := ToProto(counter)
+			if i == n-1 {
+				msg.Batch = batch
+			}
+			require.NoError(t, stream.Send(msg))
+		}
+	}
+
+	// every marked batch is stored and acknowledged while the stream is open
+	stream, err := client.StreamMetrics(ctx)
+	require.NoError(t, err)
+	send(stream, 3, 1)
+	summary, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), summary.GetBatch())
+	assert.Equal(t, uint64(3), summary.GetStored())
+	assert.Equal(t, int64(3), read())
+	send(stream, 2, 2)
+	summary, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), summary.GetBatch())
+	assert.Equal(t, uint64(5), summary.GetStored())
+
+	// metrics without batch are stored when the stream is closed
+	send(stream, 1, 0)
+	require.NoError(t, stream.Send(&pb.Metric{Id: "unknown"}))
+	require.NoError(t, stream.CloseSend())
+	summary, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), summary.GetBatch())
+	assert.Equal(t, uint64(7), summary.GetReceived())
+	assert.Equal(t, uint64(6), summary.GetStored())
+	assert.Equal(t, int64(6), read())
+
+	// nothing of the batch is stored when the stream fails before the batch is complete
+	streamCtx, cancel := context.WithCancel(ctx)
+	stream, err = client.StreamMetrics(streamCtx)
+	require.NoError(t, err)
+	send(stream, 2, 0)
+	cancel()
+	_, err = stream.Recv()
+	require.Error(t, err)
+	assert.Equal(t, int64(6), read())
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/itallix/go-metrics/internal/grpc/proto"
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+// WatchBufferSize is the number of updates buffered for a watcher, further updates are dropped
+// until the watcher catches up.
+const WatchBufferSize = 256
+
+// Watch streams every stored metric matching the request filters until the client cancels the call
+// or the server shuts down. Updates dropped for a slow watcher are counted in the next sent update.
+func (srv *Server) Watch(in *pb.WatchRequest, stream grpc.ServerStreamingServer[pb.MetricUpdate]) error {
+	if srv.hub == nil {
+		return status.Error(codes.Unimplemented, "watching metrics is not enabled")
+	}
+	filter, err := watchFilter(in)
+	if err != nil {
+		return err
+	}
+	sub := srv.hub.Subscribe(filter, WatchBufferSize)
+	defer sub.Close()
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change, ok := <-sub.Changes():
+			if !ok {
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			if change.Dropped > 0 {
+				logger.Log().Warnf("Watcher is too slow, %d metric updates have been dropped", change.Dropped)
+			}
+			if err = stream.Send(&pb.MetricUpdate{Metric: ToProto(&change.Metric), Dropped: change.Dropped}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func watchFilter(in *pb.WatchRequest) (storage.Filter, error) {
+	var mtype model.MetricType
+	if in.GetMtype() != pb.Metric_M_TYPE_UNSPECIFIED {
+		var ok bool
+		if mtype, ok = MTypeFromProto(in.GetMtype()); !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown metric type %v", in.GetMtype())
+		}
+	}
+	id, prefix := in.GetId(), in.GetPrefix()
+	return func(metric *model.Metrics) bool {
+		return (id == "" || metric.ID == id) &&
+			(mtype == "" || metric.MType == mtype) &&
+			strings.HasPrefix(metric.ID, prefix)
+	}, nil
+}
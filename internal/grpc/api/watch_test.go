@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/itallix/go-metrics/internal/grpc/proto"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+func TestServer_Watch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	hub := storage.NewHub()
+	s := memory.NewMemStorage(ctx, nil, nil).WithHub(hub)
+	client := startServer(t, NewServer(s).WithHub(hub))
+
+	stream, err := client.Watch(ctx, &pb.WatchRequest{Mtype: pb.Metric_M_TYPE_COUNTER, Prefix: "app_"})
+	require.NoError(t, err)
+	// the subscription is registered asynchronously, so keep updating until the first update arrives
+	received := make(chan *pb.MetricUpdate)
+	go func() {
+		for {
+			update, recvErr := stream.Recv()
+			if recvErr != nil {
+				close(received)
+				return
+			}
+			received <- update
+		}
+	}()
+
+	c, g := int64(2), 1.5
+	var update *pb.MetricUpdate
+	for update == nil {
+		require.NoError(t, s.UpdateBatch(ctx, []model.Metrics{*model.NewGauge("app_g0", &g),
+			*model.NewCounter("other", &c)}))
+		require.NoError(t, s.Update(ctx, model.NewCounter("app_c0", &c)))
+		select {
+		case update = <-received:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	assert.Equal(t, "app_c0", update.GetMetric().GetId())
+	assert.Equal(t, pb.Metric_M_TYPE_COUNTER, update.GetMetric().GetMtype())
+
+	// watchers are finished when the hub is closed
+	hub.Close()
+	for update = range received {
+		assert.Equal(t, "app_c0", update.GetMetric().GetId())
+	}
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestServer_WatchNotEnabled(t *testing.T) {
+	ctx := context.Background()
+	client := startServer(t, NewServer(memory.NewMemStorage(ctx, nil, nil)))
+
+	stream, err := client.Watch(ctx, &pb.WatchRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
@@ -0,0 +1,226 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/security"
+	"github.com/itallix/go-metrics/internal/service"
+)
+
+// TrustedSubnetUnary rejects calls whose X-Real-IP metadata is not within the trusted subnet.
+func TrustedSubnetUnary(trustedSubnet string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkAddr(ctx, trustedSubnet); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// TrustedSubnetStream is TrustedSubnetUnary for streaming calls.
+func TrustedSubnetStream(trustedSubnet string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkAddr(ss.Context(), trustedSubnet); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func checkAddr(ctx context.Context, trustedSubnet string) error {
+	if err := security.CheckAddr(trustedSubnet, firstValue(ctx, model.XRealIPHeader)); err != nil {
+		logger.Log().Errorf("Rejecting call: %v", err)
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+// VerifyHashUnary checks the request against the HashSHA256 metadata or, when the request message has one,
+// its hash field. The response of a signed request is signed with the HashSHA256 header.
+func VerifyHashUnary(hashService service.HashService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+		signed, err := verifyRequest(ctx, hashService, msg)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		if err != nil || !signed {
+			return resp, err
+		}
+		if respMsg, ok := resp.(proto.Message); ok {
+			if err = signResponse(respMsg, hashService, func(md metadata.MD) error {
+				return grpc.SetHeader(ctx, md)
+			}); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+}
+
+// VerifyHashStream checks the hash field of every received message. When the client has signed its messages,
+// the responses with the hash field are signed with it, the response of a client-streaming call without the field
+// is signed with the HashSHA256 trailer.
+func VerifyHashStream(hashService service.HashService) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		stream := &hashStream{ServerStream: ss, hashService: hashService, signResponse: !info.IsServerStream}
+		return handler(srv, stream)
+	}
+}
+
+type hashStream struct {
+	grpc.ServerStream
+
+	hashService  service.HashService
+	signResponse bool
+	signed       bool
+}
+
+func (s *hashStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil
+	}
+	hash, payload, err := messageHash(msg)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if err = verify(s.hashService, payload, hash); err != nil {
+		return err
+	}
+	s.signed = s.signed || hash != ""
+	return nil
+}
+
+func (s *hashStream) SendMsg(m any) error {
+	msg, ok := m.(proto.Message)
+	switch {
+	case !ok || !s.signed:
+	case field(msg, hashField) != nil:
+		if err := SignMessage(s.hashService, msg); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	case s.signResponse:
+		if err := signResponse(msg, s.hashService, func(md metadata.MD) error {
+			s.SetTrailer(md)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+// verifyRequest reports whether the request has been signed and checks its hash.
+func verifyRequest(ctx context.Context, hashService service.HashService, msg proto.Message) (bool, error) {
+	var (
+		hash    = firstValue(ctx, model.HashSha256Header)
+		payload []byte
+		err     error
+	)
+	if hash != "" {
+		payload, err = Payload(msg)
+	} else {
+		hash, payload, err = messageHash(msg)
+	}
+	if err != nil {
+		return false, status.Error(codes.Internal, err.Error())
+	}
+	return hash != "", verify(hashService, payload, hash)
+}
+
+func verify(hashService service.HashService, payload []byte, hash string) error {
+	if err := security.VerifyHash(hashService, payload, hash); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+func signResponse(msg proto.Message, hashService service.HashService, set func(md metadata.MD) error) error {
+	payload, err := Payload(msg)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return set(metadata.Pairs(model.HashSha256Header, hashService.Sha256sum(payload)))
+}
+
+// DecryptUnary decrypts requests that have the encrypted field with the private key located at privateKeyPath.
+// Such requests must be encrypted, the same as every HTTP request when the crypto key is configured.
+func DecryptUnary(privateKeyPath string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if msg, ok := req.(proto.Message); ok {
+			if err := decrypt(msg, privateKeyPath); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// DecryptStream is DecryptUnary for every received message of streaming calls.
+func DecryptStream(privateKeyPath string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &decryptStream{ServerStream: ss, privateKeyPath: privateKeyPath})
+	}
+}
+
+type decryptStream struct {
+	grpc.ServerStream
+
+	privateKeyPath string
+}
+
+func (s *decryptStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if msg, ok := m.(proto.Message); ok {
+		return decrypt(msg, s.privateKeyPath)
+	}
+	return nil
+}
+
+// decrypt replaces the content of the message with the decrypted envelope from its encrypted field.
+func decrypt(msg proto.Message, privateKeyPath string) error {
+	fd := field(msg, encryptedField)
+	if fd == nil {
+		return nil
+	}
+	encrypted := msg.ProtoReflect().Get(fd).Bytes()
+	if len(encrypted) == 0 {
+		return status.Error(codes.InvalidArgument, "payload must be encrypted")
+	}
+	payload, err := security.Decrypt(encrypted, privateKeyPath)
+	if err != nil {
+		logger.Log().Errorf("Error decrypting the request payload %v", err)
+		return status.Error(codes.InvalidArgument, security.ErrDecrypt.Error())
+	}
+	proto.Reset(msg)
+	if err = proto.Unmarshal(payload, msg); err != nil {
+		return status.Error(codes.InvalidArgument, errors.Join(security.ErrDecrypt, err).Error())
+	}
+	return nil
+}
+
+func firstValue(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,181 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/itallix/go-metrics/internal/grpc/api"
+	pb "github.com/itallix/go-metrics/internal/grpc/proto"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/service"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+const (
+	publicKeyPath  = "../../../test_data/client.pem"
+	privateKeyPath = "../../../test_data/server.pem"
+)
+
+func startServer(t *testing.T, opts ...grpc.ServerOption) pb.MetricsClient {
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(opts...)
+	pb.RegisterMetricsServer(grpcServer, api.NewServer(memory.NewMemStorage(context.Background(), nil, nil)))
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewMetricsClient(conn)
+}
+
+func gauge(id string, value float64) *pb.Metric {
+	return api.ToProto(model.NewGauge(id, &value))
+}
+
+func TestVerifyHash_Unary(t *testing.T) {
+	hashService := service.NewHashService("secret")
+	client := startServer(t, grpc.UnaryInterceptor(VerifyHashUnary(hashService)))
+	req := &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{gauge("g0", 1)}}
+	payload, err := Payload(req)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		hash     string
+		wantCode codes.Code
+		wantSign bool
+	}{
+		{name: "signed", hash: hashService.Sha256sum(payload), wantSign: true},
+		{name: "not signed"},
+		{name: "other secret", hash: service.NewHashService("other").Sha256sum(payload), wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.hash != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, model.HashSha256Header, tt.hash)
+			}
+			var header metadata.MD
+			resp, err := client.UpdateMetrics(ctx, req, grpc.Header(&header))
+			require.Equal(t, tt.wantCode, status.Code(err))
+			if !tt.wantSign {
+				assert.Empty(t, header.Get(model.HashSha256Header))
+				return
+			}
+			respPayload, err := Payload(resp)
+			require.NoError(t, err)
+			assert.Equal(t, []string{hashService.Sha256sum(respPayload)}, header.Get(model.HashSha256Header))
+		})
+	}
+}
+
+func TestVerifyHash_Stream(t *testing.T) {
+	hashService := service.NewHashService("secret")
+	client := startServer(t, grpc.StreamInterceptor(VerifyHashStream(hashService)))
+	ctx := context.Background()
+
+	stream, err := client.StreamMetrics(ctx)
+	require.NoError(t, err)
+	msg := gauge("g0", 1)
+	msg.Labels = map[string]string{"a": "1", "b": "2", "c": "3"}
+	require.NoError(t, SignMessage(hashService, msg))
+	require.NoError(t, stream.Send(msg))
+	require.NoError(t, stream.CloseSend())
+	summary, err := stream.Recv()
+	require.NoError(t, err)
+	hash, payload, err := messageHash(summary)
+	require.NoError(t, err)
+	assert.Equal(t, hashService.Sha256sum(payload), hash)
+
+	stream, err = client.StreamMetrics(ctx)
+	require.NoError(t, err)
+	msg = gauge("g0", 1)
+	require.NoError(t, SignMessage(service.NewHashService("other"), msg))
+	require.NoError(t, stream.Send(msg))
+	require.NoError(t, stream.CloseSend())
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestDecrypt(t *testing.T) {
+	client := startServer(t,
+		grpc.UnaryInterceptor(DecryptUnary(privateKeyPath)),
+		grpc.StreamInterceptor(DecryptStream(privateKeyPath)))
+	ctx := context.Background()
+
+	req := &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{gauge("g0", 1)}}
+	require.NoError(t, SealMessage(req, publicKeyPath))
+	assert.Empty(t, req.GetMetrics())
+	resp, err := client.UpdateMetrics(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resp.GetMetrics(), 1)
+	assert.Equal(t, "g0", resp.GetMetrics()[0].GetId())
+
+	stream, err := client.StreamMetrics(ctx)
+	require.NoError(t, err)
+	msg := gauge("g1", 2)
+	require.NoError(t, SealMessage(msg, publicKeyPath))
+	require.NoError(t, stream.Send(msg))
+	require.NoError(t, stream.CloseSend())
+	summary, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), summary.GetStored())
+
+	metric, err := client.GetMetric(ctx, &pb.MetricKey{Id: "g1", Mtype: pb.Metric_M_TYPE_GAUGE})
+	require.NoError(t, err)
+	assert.InDelta(t, 2.0, metric.GetValue(), 0.0001)
+
+	_, err = client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{gauge("g0", 1)}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Encrypted: []byte("garbage")})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestTrustedSubnet(t *testing.T) {
+	client := startServer(t,
+		grpc.UnaryInterceptor(TrustedSubnetUnary("192.168.1.0/24")),
+		grpc.StreamInterceptor(TrustedSubnetStream("192.168.1.0/24")))
+
+	tests := []struct {
+		name     string
+		realIP   string
+		wantCode codes.Code
+	}{
+		{name: "trusted", realIP: "192.168.1.10"},
+		{name: "untrusted", realIP: "10.0.0.1", wantCode: codes.PermissionDenied},
+		{name: "missing", wantCode: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.realIP != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, model.XRealIPHeader, tt.realIP)
+			}
+			_, err := client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+
+			stream, err := client.StreamMetrics(ctx)
+			require.NoError(t, err)
+			require.NoError(t, stream.CloseSend())
+			_, err = stream.Recv()
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Package interceptor provides gRPC server interceptors with the same security checks as the HTTP middlewares.
+// Unary requests are signed with the HashSHA256 metadata, streamed messages carry their own hash field.
+// Messages with the encrypted field are sent as an envelope of the marshalled message.
+package interceptor
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/itallix/go-metrics/internal/service"
+)
+
+const (
+	hashField      = "hash"
+	encryptedField = "encrypted"
+)
+
+var marshalOptions = proto.MarshalOptions{Deterministic: true}
+
+// SignMessage sets the hash field of the message to the HMAC of the message without the hash.
+func SignMessage(hashService service.HashService, msg proto.Message) error {
+	fd := field(msg, hashField)
+	if fd == nil {
+		return fmt.Errorf("message %s has no hash field", msg.ProtoReflect().Descriptor().FullName())
+	}
+	msg.ProtoReflect().Clear(fd)
+	payload, err := marshalOptions.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("cannot marshall message to bytes: %w", err)
+	}
+	msg.ProtoReflect().Set(fd, protoreflect.ValueOfString(hashService.Sha256sum(payload)))
+	return nil
+}
+
+// SealMessage replaces the content of the message with the encrypted envelope of it.
+func SealMessage(msg proto.Message, publicKeyPath string) error {
+	fd := field(msg, encryptedField)
+	if fd == nil {
+		return fmt.Errorf("message %s has no encrypted field", msg.ProtoReflect().Descriptor().FullName())
+	}
+	payload, err := marshalOptions.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("cannot marshall message to bytes: %w", err)
+	}
+	encrypted, err := service.EncryptData(payload, publicKeyPath)
+	if err != nil {
+		return err
+	}
+	proto.Reset(msg)
+	msg.ProtoReflect().Set(fd, protoreflect.ValueOfBytes(encrypted))
+	return nil
+}
+
+// Payload returns the deterministically marshalled message, which is the signed payload.
+func Payload(msg proto.Message) ([]byte, error) {
+	payload, err := marshalOptions.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshall message to bytes: %w", err)
+	}
+	return payload, nil
+}
+
+// messageHash returns the hash of the message and its payload without the hash,
+// an empty hash when the message is not signed.
+func messageHash(msg proto.Message) (string, []byte, error) {
+	fd := field(msg, hashField)
+	if fd == nil || !msg.ProtoReflect().Has(fd) {
+		return "", nil, nil
+	}
+	hash := msg.ProtoReflect().Get(fd).String()
+	unsigned := proto.Clone(msg)
+	unsigned.ProtoReflect().Clear(fd)
+	payload, err := Payload(unsigned)
+	return hash, payload, err
+}
+
+// field returns the descriptor of the message field with the given name, nil when there is no such field.
+func field(msg proto.Message, name protoreflect.Name) protoreflect.FieldDescriptor {
+	return msg.ProtoReflect().Descriptor().Fields().ByName(name)
+}
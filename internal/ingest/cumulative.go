@@ -0,0 +1,220 @@
+// Package ingest holds the helpers shared by the receivers of third party protocols, see its subpackages.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+// DefaultCumulativeTTL is how long the last total of a series is remembered after it has been reported.
+const DefaultCumulativeTTL = time.Hour
+
+// CounterFunc converts the cumulative total of a counter series to the counter delta, it returns nil when
+// the total has not changed.
+type CounterFunc func(ctx context.Context, id string, labels model.Labels, total int64) (*model.Metrics, error)
+
+// HistogramFunc converts the cumulative histogram of a series, i.e. all observations since the source has started,
+// to the histogram of the observations made since the previous report, it returns nil when there are none.
+type HistogramFunc func(ctx context.Context, id string, labels model.Labels, total *model.HistogramValue) (
+	*model.Metrics, error)
+
+// Cumulative stores counters reported as cumulative totals, e.g. the bytes sent by an interface, while
+// the storage counters are increased by deltas. A counter is increased by the difference with the previously
+// reported total of the series, the stored counter is taken as the previous total of a series reported for
+// the first time, e.g. after the server restart. A total lower than the previous one means the source has been
+// restarted, the counter is increased by the whole total then. Totals of series that have not been reported
+// for the TTL are forgotten, so series that are gone do not hold memory, such series continue from the stored
+// counter when they are reported again.
+type Cumulative struct {
+	storage storage.Storage
+	ttl     time.Duration
+	// last holds the last reported total of every counter series.
+	last map[string]lastTotal
+	// lastHistograms holds the last reported total of every histogram series.
+	lastHistograms map[string]lastHistogram
+	// pruned is the time expired totals were dropped last.
+	pruned time.Time
+	// mu serializes the read-modify-write of counters.
+	mu sync.Mutex
+}
+
+type lastTotal struct {
+	value int64
+	seen  time.Time
+}
+
+type lastHistogram struct {
+	value *model.HistogramValue
+	seen  time.Time
+}
+
+func NewCumulative(storage storage.Storage) *Cumulative {
+	return &Cumulative{
+		storage:        storage,
+		ttl:            DefaultCumulativeTTL,
+		last:           make(map[string]lastTotal),
+		lastHistograms: make(map[string]lastHistogram),
+		pruned:         time.Now(),
+	}
+}
+
+// WithTTL sets how long the last total of a series is remembered after it has been reported.
+func (c *Cumulative) WithTTL(ttl time.Duration) *Cumulative {
+	c.ttl = ttl
+	return c
+}
+
+// Write stores the metrics returned by build, which converts the totals of counters with the given function.
+// The totals become the previous ones only when the metrics are stored.
+func (c *Cumulative) Write(ctx context.Context, build func(counter CounterFunc) ([]model.Metrics, error)) error {
+	return c.WriteWithHistograms(ctx, func(counter CounterFunc, _ HistogramFunc) ([]model.Metrics, error) {
+		return build(counter)
+	})
+}
+
+// WriteWithHistograms is Write for sources reporting cumulative histograms as well. A histogram is converted
+// the same way as a counter: it holds the observations made since the previous report of the series, the stored
+// histogram is taken as the previous report of a series reported for the first time. A histogram with fewer
+// observations than the previous one or with another bucket layout means the source has been restarted.
+func (c *Cumulative) WriteWithHistograms(ctx context.Context,
+	build func(counter CounterFunc, histogram HistogramFunc) ([]model.Metrics, error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+
+	// the totals reported by the batch, the same series can be reported several times
+	counters := make(map[string]int64)
+	histograms := make(map[string]*model.HistogramValue)
+	metrics, err := build(
+		func(ctx context.Context, id string, labels model.Labels, total int64) (*model.Metrics, error) {
+			return c.counter(ctx, counters, now, id, labels, total)
+		},
+		func(ctx context.Context, id string, labels model.Labels, total *model.HistogramValue) (*model.Metrics,
+			error) {
+			return c.histogram(ctx, histograms, now, id, labels, total)
+		})
+	if err != nil {
+		return err
+	}
+	if len(metrics) > 0 {
+		if err = c.storage.UpdateBatch(ctx, metrics); err != nil {
+			return fmt.Errorf("cannot store metrics: %w", err)
+		}
+	}
+	for key, value := range counters {
+		c.last[key] = lastTotal{value: value, seen: now}
+	}
+	for key, value := range histograms {
+		c.lastHistograms[key] = lastHistogram{value: value, seen: now}
+	}
+	c.prune(now)
+	return nil
+}
+
+// counter returns the counter increased by the difference of the total with the previous one.
+func (c *Cumulative) counter(ctx context.Context, totals map[string]int64, now time.Time, id string,
+	labels model.Labels, total int64) (*model.Metrics, error) {
+	key := model.SeriesKey(id, labels)
+	last, ok := totals[key]
+	if !ok {
+		var prev lastTotal
+		prev, ok = c.last[key]
+		ok = ok && now.Sub(prev.seen) < c.ttl
+		last = prev.value
+	}
+	if !ok {
+		current := model.Metrics{ID: id, MType: model.Counter, Labels: labels}
+		err := c.storage.Read(ctx, &current)
+		if err != nil && !errors.Is(err, storage.ErrMetricNotFound) {
+			return nil, fmt.Errorf("cannot read counter %s: %w", key, err)
+		}
+		if err == nil {
+			last = *current.Delta
+		}
+	}
+	delta := total - last
+	if delta < 0 {
+		delta = total
+	}
+	totals[key] = total
+	if delta == 0 {
+		return nil, nil
+	}
+	metric := model.NewCounter(id, &delta)
+	metric.Labels = labels
+	return metric, nil
+}
+
+// histogram returns the histogram of the observations made since the previous total.
+func (c *Cumulative) histogram(ctx context.Context, totals map[string]*model.HistogramValue, now time.Time, id string,
+	labels model.Labels, total *model.HistogramValue) (*model.Metrics, error) {
+	key := model.SeriesKey(id, labels)
+	last, ok := totals[key]
+	if !ok {
+		var prev lastHistogram
+		prev, ok = c.lastHistograms[key]
+		ok = ok && now.Sub(prev.seen) < c.ttl
+		last = prev.value
+	}
+	if !ok {
+		current := model.Metrics{ID: id, MType: model.Histogram, Labels: labels}
+		err := c.storage.Read(ctx, &current)
+		if err != nil && !errors.Is(err, storage.ErrMetricNotFound) {
+			return nil, fmt.Errorf("cannot read histogram %s: %w", key, err)
+		}
+		if err == nil {
+			last = current.Histogram
+		}
+	}
+	totals[key] = total.Clone()
+	delta := increase(total, last)
+	if delta.Count == 0 {
+		return nil, nil
+	}
+	metric := model.NewHistogram(id, delta)
+	metric.Labels = labels
+	return metric, nil
+}
+
+// increase returns the observations of the total made after the last one, the whole total when the last one
+// is missing or is not an earlier state of the total.
+func increase(total, last *model.HistogramValue) *model.HistogramValue {
+	delta := total.Clone()
+	if last == nil || total.Count < last.Count || !slices.Equal(total.Bounds(), last.Bounds()) {
+		return delta
+	}
+	for i := range delta.Buckets {
+		if delta.Buckets[i].Count < last.Buckets[i].Count {
+			return total.Clone()
+		}
+		delta.Buckets[i].Count -= last.Buckets[i].Count
+	}
+	delta.Count -= last.Count
+	delta.Sum -= last.Sum
+	return delta
+}
+
+// prune drops the expired totals, the totals are scanned at most once per TTL.
+func (c *Cumulative) prune(now time.Time) {
+	if now.Sub(c.pruned) < c.ttl {
+		return
+	}
+	for key, prev := range c.last {
+		if now.Sub(prev.seen) >= c.ttl {
+			delete(c.last, key)
+		}
+	}
+	for key, prev := range c.lastHistograms {
+		if now.Sub(prev.seen) >= c.ttl {
+			delete(c.lastHistograms, key)
+		}
+	}
+	c.pruned = now
+}
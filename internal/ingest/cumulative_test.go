@@ -0,0 +1,75 @@
+package ingest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/ingest"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+func TestCumulative_Write(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	labels := model.Labels{"interface": "eth0"}
+	write := func(c *ingest.Cumulative, totals ...int64) error {
+		return c.Write(ctx, func(counter ingest.CounterFunc) ([]model.Metrics, error) {
+			var metrics []model.Metrics
+			for _, total := range totals {
+				metric, err := counter(ctx, "bytes_sent", labels, total)
+				if err != nil {
+					return nil, err
+				}
+				if metric != nil {
+					metrics = append(metrics, *metric)
+				}
+			}
+			return metrics, nil
+		})
+	}
+	read := func() int64 {
+		metric := model.Metrics{ID: "bytes_sent", MType: model.Counter, Labels: labels}
+		require.NoError(t, s.Read(ctx, &metric))
+		return *metric.Delta
+	}
+
+	c := ingest.NewCumulative(s)
+	require.NoError(t, write(c, 100, 150))
+	assert.Equal(t, int64(150), read(), "totals of the same batch")
+	require.NoError(t, write(c, 150))
+	assert.Equal(t, int64(150), read(), "unchanged total")
+	require.NoError(t, write(c, 20, 30))
+	assert.Equal(t, int64(180), read(), "reset source")
+
+	failed := c.Write(ctx, func(counter ingest.CounterFunc) ([]model.Metrics, error) {
+		_, err := counter(ctx, "bytes_sent", labels, 1000)
+		require.NoError(t, err)
+		return nil, errors.New("invalid batch")
+	})
+	require.Error(t, failed)
+	require.NoError(t, write(c, 40))
+	assert.Equal(t, int64(190), read(), "totals of the failed batch are discarded")
+
+	// a new instance, e.g. after the restart, continues from the stored counter
+	require.NoError(t, write(ingest.NewCumulative(s), 200))
+	assert.Equal(t, int64(200), read())
+
+	// expired totals are forgotten, the series continues from the stored counter, which is increased by
+	// other sources meanwhile, the same as after the restart
+	c = ingest.NewCumulative(s).WithTTL(10 * time.Millisecond)
+	require.NoError(t, write(c, 300))
+	assert.Equal(t, int64(300), read())
+	delta := int64(50)
+	other := model.NewCounter("bytes_sent", &delta)
+	other.Labels = labels
+	require.NoError(t, s.Update(ctx, other))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, write(c, 320))
+	assert.Equal(t, int64(670), read(), "expired total")
+}
@@ -0,0 +1,71 @@
+package graphite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+var ErrInvalidLine = errors.New("invalid graphite line")
+
+// sample is a single value of a Graphite path, the zero time means the value was received without timestamp.
+type sample struct {
+	path  string
+	value float64
+	time  time.Time
+}
+
+// parseLine parses the plaintext line "<path> <value> [<timestamp>]", the timestamp is in Unix seconds
+// and -1 means the time of receiving as well as no timestamp.
+func parseLine(line string) (sample, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 && len(fields) != 3 {
+		return sample{}, fmt.Errorf("%w %q: expected <path> <value> [<timestamp>]", ErrInvalidLine, line)
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return sample{}, fmt.Errorf("%w %q: invalid value: %w", ErrInvalidLine, line, err)
+	}
+	s := sample{path: fields[0], value: value}
+	if len(fields) == 3 {
+		if s.time, err = parseTimestamp(fields[2]); err != nil {
+			return sample{}, fmt.Errorf("%w %q: %w", ErrInvalidLine, line, err)
+		}
+	}
+	return s.validate(line)
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	ts, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(ts) || math.IsInf(ts, 0) {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return timestamp(ts), nil
+}
+
+func timestamp(ts float64) time.Time {
+	if ts == -1 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second)))
+}
+
+// validate rejects empty paths and values which cannot be stored, source describes the sample in errors.
+func (s sample) validate(source string) (sample, error) {
+	if s.path == "" || strings.ContainsAny(s.path, " \t\r\n") {
+		return sample{}, fmt.Errorf("%w %q: invalid path", ErrInvalidLine, source)
+	}
+	if err := model.ValidateSeries(s.path, nil); err != nil {
+		return sample{}, fmt.Errorf("%w %q: %w", ErrInvalidLine, source, err)
+	}
+	if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+		return sample{}, fmt.Errorf("%w %q: value is not finite", ErrInvalidLine, source)
+	}
+	return s, nil
+}
@@ -0,0 +1,136 @@
+package graphite
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		give    string
+		want    sample
+		wantErr bool
+	}{
+		{
+			give: "servers.web-1.cpu 0.5 1700000000\n",
+			want: sample{path: "servers.web-1.cpu", value: 0.5, time: time.Unix(1700000000, 0)},
+		},
+		{
+			give: "servers.web-1.cpu 1e3 1700000000.25",
+			want: sample{path: "servers.web-1.cpu", value: 1000, time: time.Unix(1700000000, int64(250*time.Millisecond))},
+		},
+		{give: "servers.web-1.cpu -2 -1", want: sample{path: "servers.web-1.cpu", value: -2}},
+		{give: "servers.web-1.cpu 7", want: sample{path: "servers.web-1.cpu", value: 7}},
+		{give: "servers.web-1.cpu", wantErr: true},
+		{give: "servers.web-1.cpu 1 2 3", wantErr: true},
+		{give: "servers.web-1.cpu one", wantErr: true},
+		{give: "servers.web-1.cpu NaN", wantErr: true},
+		{give: "servers.web-1.cpu 1 yesterday", wantErr: true},
+		{give: "weird{name 1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.give, func(t *testing.T) {
+			got, err := parseLine(tt.give)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidLine)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.path, got.path)
+			assert.InDelta(t, tt.want.value, got.value, 1e-9)
+			assert.True(t, tt.want.time.Equal(got.time), got.time)
+		})
+	}
+}
+
+func TestParsePickle(t *testing.T) {
+	// pickle.dumps([("servers.web-1.cpu", (1700000000, 0.5)), ("servers.web-1.mem", (1700000001.5, 42))], protocol)
+	metrics := []sample{
+		{path: "servers.web-1.cpu", value: 0.5, time: time.Unix(1700000000, 0)},
+		{path: "servers.web-1.mem", value: 42, time: time.Unix(1700000001, int64(500*time.Millisecond))},
+	}
+	tests := []struct {
+		name     string
+		give     string
+		want     []sample
+		wantErrs int
+		wantErr  bool
+	}{
+		{
+			name: "Protocol2",
+			give: "80025d7100285811000000736572766572732e7765622d312e63707571014a00f15365473fe000000000000086710286" +
+				"71035811000000736572766572732e7765622d312e6d656d71044741d954fc406000004b2a867105867106652e",
+			want: metrics,
+		},
+		{
+			name: "Protocol4",
+			give: "8004954e000000000000005d94288c11736572766572732e7765622d312e637075944a00f15365473fe0000000000000" +
+				"869486948c11736572766572732e7765622d312e6d656d944741d954fc406000004b2a86948694652e",
+			want: metrics,
+		},
+		{
+			// [("big", (2**40, -2**40))]
+			name: "Long",
+			give: "80025d7100580300000062696771018a060000000000018a060000000000ff867102867103612e",
+			want: []sample{{path: "big", value: -(1 << 40), time: time.Unix(1<<40, 0)}},
+		},
+		{
+			// [("a", (1, 1))] * 2
+			name: "Memo",
+			give: "80025d71002858010000006171014b014b018671028671036803652e",
+			want: []sample{{path: "a", value: 1, time: time.Unix(1, 0)}, {path: "a", value: 1, time: time.Unix(1, 0)}},
+		},
+		{
+			// [("ok", (1700000000, 1)), ("bad", (1700000000, "x")), "garbage"]
+			name: "InvalidDatapoints",
+			give: "80025d71002858020000006f6b71014a00f153654b01867102867103580300000062616471044a00f153655801000000" +
+				"7871058671068671075807000000676172626167657108652e",
+			want:     []sample{{path: "ok", value: 1, time: time.Unix(1700000000, 0)}},
+			wantErrs: 2,
+		},
+		{
+			// t = (); t = (t, t) 40 times; [t], the memoized tuples must not be formatted in the error
+			name: "NestedMemo",
+			give: "80025d710029298671016801867102680286710368038671046804867105680586710668068671076807867108680886" +
+				"7109680986710a680a86710b680b86710c680c86710d680d86710e680e86710f680f8671106810867111681186711268" +
+				"12867113681386711468148671156815867116681686711768178671186818867119681986711a681a86711b681b8671" +
+				"1c681c86711d681d86711e681e86711f681f867120682086712168218671226822867123682386712468248671256825" +
+				"86712668268671276827867128612e",
+			wantErrs: 1,
+		},
+		{
+			// {"a": 1}
+			name:    "NotList",
+			give:    "80027d710058010000006171014b01732e",
+			wantErr: true,
+		},
+		{
+			name:    "Truncated",
+			give:    "80025d7100285811000000736572",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.give)
+			require.NoError(t, err)
+			got, errs, err := parsePickle(data)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidPickle)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, errs, tt.wantErrs)
+			require.Len(t, got, len(tt.want))
+			for i, want := range tt.want {
+				assert.Equal(t, want.path, got[i].path)
+				assert.InDelta(t, want.value, got[i].value, 1e-9)
+				assert.True(t, want.time.Equal(got[i].time), got[i].time)
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package graphite
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// maxPickleSize is the max size of a pickle payload.
+const maxPickleSize = 1 << 20
+
+var ErrInvalidPickle = errors.New("invalid graphite pickle")
+
+// Pickle opcodes of the protocols 2 and higher used to encode lists of metric tuples.
+const (
+	opMark            = '('
+	opStop            = '.'
+	opNone            = 'N'
+	opBinInt          = 'J'
+	opBinInt1         = 'K'
+	opBinInt2         = 'M'
+	opBinFloat        = 'G'
+	opBinString       = 'T'
+	opShortBinString  = 'U'
+	opBinUnicode      = 'X'
+	opAppend          = 'a'
+	opAppends         = 'e'
+	opBinGet          = 'h'
+	opLongBinGet      = 'j'
+	opEmptyList       = ']'
+	opList            = 'l'
+	opBinPut          = 'q'
+	opLongBinPut      = 'r'
+	opTuple           = 't'
+	opEmptyTuple      = ')'
+	opBinBytes        = 'B'
+	opShortBinBytes   = 'C'
+	opProto           = 0x80
+	opTuple1          = 0x85
+	opTuple2          = 0x86
+	opTuple3          = 0x87
+	opNewTrue         = 0x88
+	opNewFalse        = 0x89
+	opLong1           = 0x8a
+	opShortBinUnicode = 0x8c
+	opBinUnicode8     = 0x8d
+	opBinBytes8       = 0x8e
+	opMemoize         = 0x94
+	opFrame           = 0x95
+)
+
+// unpickler decodes the subset of pickle needed for Carbon payloads: lists, tuples, strings and numbers.
+// Lists are kept as pointers, so appending to a memoized list changes the memoized value too.
+type unpickler struct {
+	data  []byte
+	pos   int
+	stack []any
+	marks []int
+	memo  map[uint32]any
+}
+
+// parsePickle decodes the payload of the pickle protocol, a list of (path, (timestamp, value)) tuples.
+func parsePickle(data []byte) ([]sample, []error, error) {
+	u := &unpickler{data: data, memo: make(map[uint32]any)}
+	result, err := u.load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidPickle, err)
+	}
+	list, ok := result.(*[]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: expected a list of metrics, got %T", ErrInvalidPickle, result)
+	}
+
+	var (
+		samples []sample
+		errs    []error
+	)
+	for i, item := range *list {
+		s, err := pickleSample(i, item)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		samples = append(samples, s)
+	}
+	return samples, errs, nil
+}
+
+// pickleSample converts the i-th item of the list. Items are described by their index and type in errors, never
+// formatted, since memoized objects may nest each other and would take exponential time and memory to format.
+func pickleSample(i int, item any) (sample, error) {
+	metric, ok := sequence(item)
+	if !ok || len(metric) != 2 {
+		return sample{}, fmt.Errorf("%w: item %d: expected (path, (timestamp, value)), got %T", ErrInvalidLine, i, item)
+	}
+	path, ok := metric[0].(string)
+	datapoint, okDatapoint := sequence(metric[1])
+	if !ok || !okDatapoint || len(datapoint) != 2 {
+		return sample{}, fmt.Errorf("%w: item %d: expected (path, (timestamp, value)), got (%T, %T)", ErrInvalidLine, i,
+			metric[0], metric[1])
+	}
+	ts, okTimestamp := number(datapoint[0])
+	value, okValue := number(datapoint[1])
+	if !okTimestamp || !okValue || math.IsNaN(ts) || math.IsInf(ts, 0) {
+		return sample{}, fmt.Errorf("%w: item %d: invalid datapoint (%T, %T)", ErrInvalidLine, i, datapoint[0],
+			datapoint[1])
+	}
+	return sample{path: path, value: value, time: timestamp(ts)}.validate(path)
+}
+
+func sequence(v any) ([]any, bool) {
+	switch s := v.(type) {
+	case []any:
+		return s, true
+	case *[]any:
+		return *s, true
+	default:
+		return nil, false
+	}
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func (u *unpickler) load() (any, error) {
+	for {
+		op, err := u.readByte()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opProto:
+			if _, err = u.read(1); err != nil {
+				return nil, err
+			}
+		case opFrame:
+			if _, err = u.read(8); err != nil {
+				return nil, err
+			}
+		case opStop:
+			return u.pop()
+		case opMark:
+			u.marks = append(u.marks, len(u.stack))
+		case opNone:
+			u.push(nil)
+		case opNewTrue:
+			u.push(int64(1))
+		case opNewFalse:
+			u.push(int64(0))
+		case opBinInt:
+			b, err := u.read(4)
+			if err != nil {
+				return nil, err
+			}
+			u.push(int64(int32(binary.LittleEndian.Uint32(b))))
+		case opBinInt1:
+			b, err := u.read(1)
+			if err != nil {
+				return nil, err
+			}
+			u.push(int64(b[0]))
+		case opBinInt2:
+			b, err := u.read(2)
+			if err != nil {
+				return nil, err
+			}
+			u.push(int64(binary.LittleEndian.Uint16(b)))
+		case opLong1:
+			n, err := u.readByte()
+			if err != nil {
+				return nil, err
+			}
+			b, err := u.read(int(n))
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeLong(b)
+			if err != nil {
+				return nil, err
+			}
+			u.push(v)
+		case opBinFloat:
+			b, err := u.read(8)
+			if err != nil {
+				return nil, err
+			}
+			u.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+		case opShortBinString, opShortBinUnicode, opShortBinBytes:
+			err = u.pushString(1)
+		case opBinString, opBinUnicode, opBinBytes:
+			err = u.pushString(4)
+		case opBinUnicode8, opBinBytes8:
+			err = u.pushString(8)
+		case opEmptyList:
+			u.push(&[]any{})
+		case opList:
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			u.push(&items)
+		case opAppend:
+			var item any
+			if item, err = u.pop(); err == nil {
+				err = u.appends([]any{item})
+			}
+		case opAppends:
+			var items []any
+			if items, err = u.popMark(); err == nil {
+				err = u.appends(items)
+			}
+		case opEmptyTuple:
+			u.push([]any{})
+		case opTuple:
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			u.push(items)
+		case opTuple1, opTuple2, opTuple3:
+			err = u.tuple(int(op-opTuple1) + 1)
+		case opBinPut:
+			err = u.put(1)
+		case opLongBinPut:
+			err = u.put(4)
+		case opMemoize:
+			var top any
+			if top, err = u.top(); err == nil {
+				u.memo[uint32(len(u.memo))] = top
+			}
+		case opBinGet:
+			err = u.get(1)
+		case opLongBinGet:
+			err = u.get(4)
+		default:
+			return nil, fmt.Errorf("unsupported opcode 0x%02x at %d", op, u.pos-1)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (u *unpickler) read(n int) ([]byte, error) {
+	if n < 0 || len(u.data)-u.pos < n {
+		return nil, errors.New("unexpected end of data")
+	}
+	b := u.data[u.pos : u.pos+n]
+	u.pos += n
+	return b, nil
+}
+
+func (u *unpickler) readByte() (byte, error) {
+	b, err := u.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readSize reads the little endian unsigned integer of the width.
+func (u *unpickler) readSize(width int) (uint64, error) {
+	b, err := u.read(width)
+	if err != nil {
+		return 0, err
+	}
+	var size uint64
+	for i := width - 1; i >= 0; i-- {
+		size = size<<8 | uint64(b[i])
+	}
+	return size, nil
+}
+
+func (u *unpickler) pushString(width int) error {
+	size, err := u.readSize(width)
+	if err != nil {
+		return err
+	}
+	if size > uint64(len(u.data)) {
+		return errors.New("unexpected end of data")
+	}
+	b, err := u.read(int(size))
+	if err != nil {
+		return err
+	}
+	u.push(string(b))
+	return nil
+}
+
+func (u *unpickler) push(v any) {
+	u.stack = append(u.stack, v)
+}
+
+func (u *unpickler) top() (any, error) {
+	if len(u.stack) == 0 {
+		return nil, errors.New("stack is empty")
+	}
+	return u.stack[len(u.stack)-1], nil
+}
+
+func (u *unpickler) pop() (any, error) {
+	v, err := u.top()
+	if err != nil {
+		return nil, err
+	}
+	u.stack = u.stack[:len(u.stack)-1]
+	return v, nil
+}
+
+// popMark pops the items pushed after the last mark.
+func (u *unpickler) popMark() ([]any, error) {
+	if len(u.marks) == 0 {
+		return nil, errors.New("mark is not found")
+	}
+	mark := u.marks[len(u.marks)-1]
+	u.marks = u.marks[:len(u.marks)-1]
+	if mark > len(u.stack) {
+		return nil, errors.New("mark is beyond the stack")
+	}
+	items := make([]any, len(u.stack)-mark)
+	copy(items, u.stack[mark:])
+	u.stack = u.stack[:mark]
+	return items, nil
+}
+
+// appends appends the items to the list on top of the stack.
+func (u *unpickler) appends(items []any) error {
+	top, err := u.top()
+	if err != nil {
+		return err
+	}
+	list, ok := top.(*[]any)
+	if !ok {
+		return fmt.Errorf("cannot append to %T", top)
+	}
+	*list = append(*list, items...)
+	return nil
+}
+
+func (u *unpickler) tuple(n int) error {
+	if len(u.stack) < n {
+		return errors.New("stack is empty")
+	}
+	items := make([]any, n)
+	copy(items, u.stack[len(u.stack)-n:])
+	u.stack = append(u.stack[:len(u.stack)-n], items)
+	return nil
+}
+
+func (u *unpickler) put(width int) error {
+	index, err := u.readSize(width)
+	if err != nil {
+		return err
+	}
+	top, err := u.top()
+	if err != nil {
+		return err
+	}
+	u.memo[uint32(index)] = top
+	return nil
+}
+
+func (u *unpickler) get(width int) error {
+	index, err := u.readSize(width)
+	if err != nil {
+		return err
+	}
+	v, ok := u.memo[uint32(index)]
+	if !ok {
+		return fmt.Errorf("memo %d is not found", index)
+	}
+	u.push(v)
+	return nil
+}
+
+// decodeLong decodes the little endian two's complement integer, it must fit into int64.
+func decodeLong(b []byte) (int64, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	v := new(big.Int).SetBytes(be)
+	if b[len(b)-1]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8))
+	}
+	if !v.IsInt64() {
+		return 0, errors.New("integer overflows int64")
+	}
+	return v.Int64(), nil
+}
@@ -0,0 +1,271 @@
+package graphite
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+const (
+	// maxLineSize is the max size of a plaintext line.
+	maxLineSize = 64 << 10
+	// maxBatchSize is the max number of samples stored at once, smaller batches are stored as soon as
+	// the connection has no more buffered data.
+	maxBatchSize = 1000
+)
+
+// readFunc reads the samples of the next line or payload of the connection.
+type readFunc func(r *bufio.Reader) ([]sample, error)
+
+// Server accepts Carbon connections and stores the received values as gauges named by the templates.
+// Values of a batch are stored in the order of their timestamps, so the newest value wins. Invalid lines
+// and datapoints are skipped.
+type Server struct {
+	storage   storage.Storage
+	templates *Templates
+	rateLimit int
+	conns     map[net.Conn]struct{}
+	closed    bool
+	mu        sync.Mutex
+}
+
+// NewServer constructs a server, paths are used as names without labels when templates are nil.
+func NewServer(storage storage.Storage, templates *Templates) *Server {
+	if templates == nil {
+		templates = &Templates{}
+	}
+	return &Server{
+		storage:   storage,
+		templates: templates,
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// WithRateLimit limits the number of datapoints every connection can send per second, reading from
+// connections exceeding the limit is delayed. Zero means no limit.
+func (s *Server) WithRateLimit(perSecond int) *Server {
+	s.rateLimit = perSecond
+	return s
+}
+
+// ListenAndServe listens on TCP at the plaintext and pickle addresses and serves until the context is done,
+// an empty address disables the protocol.
+func (s *Server) ListenAndServe(ctx context.Context, address, pickleAddress string) error {
+	var plain, pickle net.Listener
+	if address != "" {
+		lis, err := net.Listen("tcp", address)
+		if err != nil {
+			return fmt.Errorf("cannot listen graphite plaintext: %w", err)
+		}
+		logger.Log().Infof("Graphite plaintext listener is starting on %s...", address)
+		plain = lis
+	}
+	if pickleAddress != "" {
+		lis, err := net.Listen("tcp", pickleAddress)
+		if err != nil {
+			if plain != nil {
+				_ = plain.Close()
+			}
+			return fmt.Errorf("cannot listen graphite pickle: %w", err)
+		}
+		logger.Log().Infof("Graphite pickle listener is starting on %s...", pickleAddress)
+		pickle = lis
+	}
+	return s.Serve(ctx, plain, pickle)
+}
+
+// Serve reads plaintext lines from connections accepted by plain and pickle payloads from connections accepted
+// by pickle, either can be nil. When the context is done, the listeners and connections are closed and the
+// received values are stored before it returns.
+func (s *Server) Serve(ctx context.Context, plain, pickle net.Listener) error {
+	var wg sync.WaitGroup
+	for _, l := range []struct {
+		lis  net.Listener
+		read readFunc
+	}{{plain, readLine}, {pickle, readPickle}} {
+		if l.lis == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.accept(ctx, l.lis, l.read, &wg)
+		}()
+	}
+
+	<-ctx.Done()
+	s.close(plain, pickle)
+	wg.Wait()
+	return nil
+}
+
+func (s *Server) close(listeners ...net.Listener) {
+	for _, lis := range listeners {
+		if lis != nil {
+			_ = lis.Close()
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+func (s *Server) accept(ctx context.Context, lis net.Listener, read readFunc, wg *sync.WaitGroup) {
+	for {
+		c, err := lis.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Log().Errorf("Cannot accept graphite connection: %v", err)
+			}
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = c.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, c, read)
+		}()
+	}
+}
+
+func (s *Server) serveConn(ctx context.Context, c net.Conn, read readFunc) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		_ = c.Close()
+	}()
+	// received values are stored even when the server is stopping
+	storeCtx := context.WithoutCancel(ctx)
+	var limiter *rate.Limiter
+	if s.rateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(s.rateLimit), s.rateLimit)
+	}
+
+	reader := bufio.NewReaderSize(c, maxLineSize)
+	var batch []sample
+	for {
+		samples, err := read(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				logger.Log().Errorf("Cannot read graphite connection %s: %v", c.RemoteAddr(), err)
+			}
+			break
+		}
+		batch = append(batch, samples...)
+		if limiter != nil && wait(ctx, limiter, len(samples)) != nil {
+			break
+		}
+		if len(batch) >= maxBatchSize || reader.Buffered() == 0 {
+			s.store(storeCtx, batch)
+			batch = batch[:0]
+		}
+	}
+	s.store(storeCtx, batch)
+}
+
+// wait waits for n events in chunks, the limiter does not allow more events than its burst at once.
+func wait(ctx context.Context, limiter *rate.Limiter, n int) error {
+	for n > 0 {
+		chunk := min(n, limiter.Burst())
+		if err := limiter.WaitN(ctx, chunk); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+func (s *Server) store(ctx context.Context, samples []sample) {
+	if len(samples) == 0 {
+		return
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return !samples[i].time.IsZero() && (samples[j].time.IsZero() || samples[i].time.Before(samples[j].time))
+	})
+	metrics := make([]model.Metrics, 0, len(samples))
+	for _, point := range samples {
+		name, labels := s.templates.Apply(point.path)
+		gauge := model.NewGauge(name, &point.value)
+		gauge.Labels = labels
+		metrics = append(metrics, *gauge)
+	}
+	if err := s.storage.UpdateBatch(ctx, metrics); err != nil {
+		logger.Log().Errorf("Cannot store graphite metrics: %v", err)
+	}
+}
+
+// readLine reads the next plaintext line, too long and invalid lines are skipped.
+func readLine(r *bufio.Reader) ([]sample, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		for errors.Is(err, bufio.ErrBufferFull) {
+			_, err = r.ReadSlice('\n')
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		logger.Log().Debugf("Skipping graphite line: exceeds %d bytes", maxLineSize)
+		return nil, nil
+	}
+	// the last line can end without newline
+	if err != nil && (!errors.Is(err, io.EOF) || len(line) == 0) {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil, nil
+	}
+	s, err := parseLine(string(line))
+	if err != nil {
+		logger.Log().Debugf("Skipping graphite line: %v", err)
+		return nil, nil
+	}
+	return []sample{s}, nil
+}
+
+// readPickle reads the next pickle payload prefixed by its size, invalid payloads and datapoints are skipped.
+func readPickle(r *bufio.Reader) ([]sample, error) {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size > maxPickleSize {
+		return nil, fmt.Errorf("%w: payload of %d bytes exceeds %d bytes", ErrInvalidPickle, size, maxPickleSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	samples, errs, err := parsePickle(data)
+	if err != nil {
+		logger.Log().Debugf("Skipping graphite pickle: %v", err)
+		return nil, nil
+	}
+	for _, err = range errs {
+		logger.Log().Debugf("Skipping graphite datapoint: %v", err)
+	}
+	return samples, nil
+}
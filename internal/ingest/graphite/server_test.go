@@ -0,0 +1,127 @@
+package graphite
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+// stored reports whether the storage has the gauge with the value.
+func stored(s *memory.MemStorage, id string, labels model.Labels, value float64) bool {
+	gauge := model.Metrics{ID: id, MType: model.Gauge, Labels: labels}
+	return s.Read(context.Background(), &gauge) == nil && *gauge.Value == value
+}
+
+func TestServer_Serve(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	templates, err := ParseTemplates([]string{"servers.* .host.measurement*"})
+	require.NoError(t, err)
+
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	pickle, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := NewServer(s, templates)
+	serveCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		done <- server.Serve(serveCtx, plain, pickle)
+	}()
+
+	c, err := net.Dial("tcp", plain.Addr().String())
+	require.NoError(t, err)
+	_, err = c.Write([]byte("servers.web-1.load 3 1700000010\nservers.web-1.load 1 1700000000\n" +
+		"garbage\njobs.backup.duration 12.5 -1\n" + strings.Repeat("x", maxLineSize+1) + " 1\n" +
+		"jobs.backup.size 2048"))
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+
+	// pickle.dumps([("servers.web-1.cpu", (1700000000, 0.5)), ("servers.web-1.mem", (1700000001.5, 42))], 2)
+	payload, err := hex.DecodeString("80025d7100285811000000736572766572732e7765622d312e63707571014a00f15365473fe0" +
+		"0000000000008671028671035811000000736572766572732e7765622d312e6d656d71044741d954fc406000004b2a867105867106652e")
+	require.NoError(t, err)
+	c, err = net.Dial("tcp", pickle.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, binary.Write(c, binary.BigEndian, uint32(len(payload))))
+	_, err = c.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+
+	host := model.Labels{"host": "web-1"}
+	require.Eventually(t, func() bool {
+		return stored(s, "mem", host, 42) && stored(s, "jobs.backup.size", nil, 2048)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the connection is kept open, stopping the server closes it
+	c, err = net.Dial("tcp", plain.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = c.Write([]byte("jobs.backup.duration 15\n"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return stored(s, "jobs.backup.duration", nil, 15)
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	gauges := []struct {
+		id     string
+		labels model.Labels
+		want   float64
+	}{
+		{id: "load", labels: host, want: 3},
+		{id: "cpu", labels: host, want: 0.5},
+		{id: "mem", labels: host, want: 42},
+		{id: "jobs.backup.duration", want: 15},
+		{id: "jobs.backup.size", want: 2048},
+	}
+	for _, tt := range gauges {
+		gauge := model.Metrics{ID: tt.id, MType: model.Gauge, Labels: tt.labels}
+		require.NoError(t, s.Read(ctx, &gauge), tt.id)
+		assert.InDelta(t, tt.want, *gauge.Value, 1e-9, tt.id)
+	}
+	all, err := s.GetGauges(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, len(gauges), "invalid lines are skipped")
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		_ = NewServer(s, nil).WithRateLimit(20).Serve(serveCtx, plain, nil)
+	}()
+
+	c, err := net.Dial("tcp", plain.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	start := time.Now()
+	var lines strings.Builder
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(&lines, "jobs.processed %d\n", i)
+	}
+	_, err = c.Write([]byte(lines.String()))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return stored(s, "jobs.processed", nil, 30)
+	}, 5*time.Second, 10*time.Millisecond)
+	// the burst of 20 datapoints is read at once, the next 10 take half a second
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
@@ -0,0 +1,161 @@
+// Package graphite receives the Carbon plaintext and pickle protocols over TCP and stores the received
+// values as gauges, Graphite paths are mapped to metric names and labels with templates.
+package graphite
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// Template keywords.
+const (
+	measurement     = "measurement"
+	measurementRest = "measurement*"
+)
+
+var ErrInvalidTemplate = errors.New("invalid graphite template")
+
+// Template maps the dot separated parts of a path to a metric name and labels: "[filter] template [label=value,...]".
+//
+// The template has a part for every part of the path: "measurement" parts make up the name joined with dots,
+// "measurement*" takes all the remaining parts, an empty part skips the path part and any other part is a label
+// name. Path parts without a template part are skipped. The whole path is the name when there are no measurement
+// parts. The filter selects paths by parts, "*" matches any part, e.g. the template
+// "servers.* .host.measurement* dc=eu" maps servers.web-1.cpu.load to cpu.load{host="web-1",dc="eu"}.
+type Template struct {
+	filter []string
+	parts  []string
+	labels model.Labels
+}
+
+// ParseTemplate parses the template, see Template.
+func ParseTemplate(s string) (*Template, error) {
+	fields := strings.Fields(s)
+	t := &Template{}
+	var labels string
+	switch {
+	case len(fields) == 1:
+		t.parts = strings.Split(fields[0], ".")
+	case len(fields) == 2 && strings.Contains(fields[1], "="):
+		t.parts, labels = strings.Split(fields[0], "."), fields[1]
+	case len(fields) == 2:
+		t.filter, t.parts = strings.Split(fields[0], "."), strings.Split(fields[1], ".")
+	case len(fields) == 3:
+		t.filter, t.parts, labels = strings.Split(fields[0], "."), strings.Split(fields[1], "."), fields[2]
+	default:
+		return nil, fmt.Errorf("%w %q: expected [filter] template [label=value,...]", ErrInvalidTemplate, s)
+	}
+	if labels != "" {
+		t.labels = make(model.Labels)
+		for _, label := range strings.Split(labels, ",") {
+			name, value, ok := strings.Cut(label, "=")
+			if !ok || name == "" {
+				return nil, fmt.Errorf("%w %q: invalid label %q", ErrInvalidTemplate, s, label)
+			}
+			t.labels[name] = value
+		}
+	}
+	names := maps.Clone(t.labels)
+	for i, part := range t.parts {
+		switch {
+		case part == measurementRest && i != len(t.parts)-1:
+			return nil, fmt.Errorf("%w %q: %s must be the last part", ErrInvalidTemplate, s, measurementRest)
+		case part != "" && part != measurement && part != measurementRest:
+			if names == nil {
+				names = make(model.Labels)
+			}
+			names[part] = ""
+		}
+	}
+	if err := model.ValidateSeries("", names); err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidTemplate, s, err)
+	}
+	return t, nil
+}
+
+func (t *Template) match(parts []string) bool {
+	if len(t.filter) > len(parts) {
+		return false
+	}
+	for i, filter := range t.filter {
+		if filter != "*" && filter != parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *Template) apply(path string, parts []string) (string, model.Labels) {
+	var name []string
+	labels := maps.Clone(t.labels)
+	for i := 0; i < len(parts) && i < len(t.parts); i++ {
+		switch part := t.parts[i]; part {
+		case "":
+		case measurement:
+			name = append(name, parts[i])
+		case measurementRest:
+			name = append(name, parts[i:]...)
+		default:
+			if labels == nil {
+				labels = make(model.Labels)
+			}
+			if current, ok := labels[part]; ok && current != "" {
+				labels[part] = current + "." + parts[i]
+			} else {
+				labels[part] = parts[i]
+			}
+		}
+	}
+	if len(name) == 0 {
+		return path, labels
+	}
+	return strings.Join(name, "."), labels
+}
+
+// Templates is an ordered set of templates, a path is mapped by the first template with a matching filter
+// or by the template without filter. Paths matched by no template are used as names without labels.
+type Templates struct {
+	filtered []*Template
+	fallback *Template
+}
+
+// ParseTemplates parses the templates, at most one of them can have no filter.
+func ParseTemplates(templates []string) (*Templates, error) {
+	result := &Templates{}
+	for _, s := range templates {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		t, err := ParseTemplate(s)
+		if err != nil {
+			return nil, err
+		}
+		if t.filter != nil {
+			result.filtered = append(result.filtered, t)
+			continue
+		}
+		if result.fallback != nil {
+			return nil, fmt.Errorf("%w %q: only one template can have no filter", ErrInvalidTemplate, s)
+		}
+		result.fallback = t
+	}
+	return result, nil
+}
+
+// Apply maps the path to the metric name and labels.
+func (ts *Templates) Apply(path string) (string, model.Labels) {
+	parts := strings.Split(path, ".")
+	for _, t := range ts.filtered {
+		if t.match(parts) {
+			return t.apply(path, parts)
+		}
+	}
+	if ts.fallback != nil {
+		return ts.fallback.apply(path, parts)
+	}
+	return path, nil
+}
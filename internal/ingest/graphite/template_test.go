@@ -0,0 +1,89 @@
+package graphite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+func TestTemplates_Apply(t *testing.T) {
+	templates, err := ParseTemplates([]string{
+		"servers.* .host.measurement* dc=eu",
+		"stats.*.*.count .env.app.measurement.",
+		"apps.* .app.region.region.measurement",
+		"measurement.measurement.field",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		give       string
+		wantName   string
+		wantLabels model.Labels
+	}{
+		{
+			give:       "servers.web-1.cpu.load",
+			wantName:   "cpu.load",
+			wantLabels: model.Labels{"host": "web-1", "dc": "eu"},
+		},
+		{
+			give:       "stats.prod.checkout.count.total",
+			wantName:   "count",
+			wantLabels: model.Labels{"env": "prod", "app": "checkout"},
+		},
+		{
+			give:       "apps.checkout.eu.west.latency",
+			wantName:   "latency",
+			wantLabels: model.Labels{"app": "checkout", "region": "eu.west"},
+		},
+		{
+			give:       "apps.checkout",
+			wantName:   "apps.checkout",
+			wantLabels: model.Labels{"app": "checkout"},
+		},
+		{
+			give:       "jobs.backup.duration",
+			wantName:   "jobs.backup",
+			wantLabels: model.Labels{"field": "duration"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.give, func(t *testing.T) {
+			name, labels := templates.Apply(tt.give)
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantLabels, labels)
+		})
+	}
+
+	name, labels := (&Templates{}).Apply("jobs.backup.duration")
+	assert.Equal(t, "jobs.backup.duration", name, "paths without templates are names")
+	assert.Nil(t, labels)
+}
+
+func TestParseTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		give    []string
+		wantErr bool
+	}{
+		{name: "Empty", give: []string{"", " "}},
+		{name: "FilterAndLabels", give: []string{"servers.* .host.measurement* dc=eu,env=prod", "measurement*"}},
+		{name: "TooManyFields", give: []string{"servers.* .host measurement dc=eu"}, wantErr: true},
+		{name: "InvalidLabel", give: []string{"servers.* .host.measurement dc"}, wantErr: true},
+		{name: "MeasurementRestNotLast", give: []string{"measurement*.host"}, wantErr: true},
+		{name: "InvalidLabelName", give: []string{"servers.* .host{.measurement"}, wantErr: true},
+		{name: "SeveralWithoutFilter", give: []string{"measurement", "host.measurement"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplates(tt.give)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidTemplate)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Package influx parses InfluxDB line protocol and writes the points to the storage, so tools like Telegraf
+// can push metrics with their stock InfluxDB outputs.
+package influx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+var ErrInvalidLine = errors.New("invalid line protocol")
+
+// Point is a single line: <measurement>[,<tag>=<value>...] <field>=<value>[,<field>=<value>...] [<timestamp>].
+type Point struct {
+	Measurement string
+	Tags        model.Labels
+	Fields      []Field
+	// Time is zero when the line has no timestamp.
+	Time time.Time
+}
+
+// Field is a point field, Value is float64, int64, uint64, string or bool.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// ParsePrecision converts the precision of the write request to the duration of a timestamp unit,
+// both InfluxDB v1 (n, u, ms, s, m, h) and v2 (ns, us, ms, s) values are accepted. Empty means nanoseconds.
+func ParsePrecision(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "n", "ns":
+		return time.Nanosecond, nil
+	case "u", "us", "µ":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unknown precision %q", precision)
+	}
+}
+
+// Parse parses the newline delimited points, empty lines and comments are skipped.
+func Parse(data []byte, precision time.Duration) ([]Point, error) {
+	var points []Point
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 4096), len(data)+1)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		point, err := parseLine(line, precision)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		points = append(points, point)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read lines: %w", err)
+	}
+	return points, nil
+}
+
+func parseLine(line string, precision time.Duration) (Point, error) {
+	seriesEnd := indexUnescaped(line, ' ', false)
+	if seriesEnd <= 0 {
+		return Point{}, fmt.Errorf("%w %q: missing fields", ErrInvalidLine, line)
+	}
+	series, rest := line[:seriesEnd], strings.TrimLeft(line[seriesEnd+1:], " ")
+	fields, timestamp := rest, ""
+	if fieldsEnd := indexUnescaped(rest, ' ', true); fieldsEnd >= 0 {
+		fields, timestamp = rest[:fieldsEnd], strings.TrimSpace(rest[fieldsEnd+1:])
+	}
+
+	var point Point
+	parts := splitUnescaped(series, ',', false)
+	point.Measurement = unescape(parts[0])
+	if point.Measurement == "" {
+		return Point{}, fmt.Errorf("%w %q: missing measurement", ErrInvalidLine, line)
+	}
+	for _, tag := range parts[1:] {
+		key, value, ok := cutUnescaped(tag)
+		if !ok || key == "" || value == "" {
+			return Point{}, fmt.Errorf("%w %q: invalid tag %q", ErrInvalidLine, line, tag)
+		}
+		if point.Tags == nil {
+			point.Tags = make(model.Labels)
+		}
+		point.Tags[unescape(key)] = unescape(value)
+	}
+
+	for _, field := range splitUnescaped(fields, ',', true) {
+		key, value, ok := cutUnescaped(field)
+		if !ok || key == "" {
+			return Point{}, fmt.Errorf("%w %q: invalid field %q", ErrInvalidLine, line, field)
+		}
+		parsed, err := parseFieldValue(value)
+		if err != nil {
+			return Point{}, fmt.Errorf("%w %q: field %q: %w", ErrInvalidLine, line, key, err)
+		}
+		point.Fields = append(point.Fields, Field{Key: unescape(key), Value: parsed})
+	}
+
+	if timestamp != "" {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return Point{}, fmt.Errorf("%w %q: invalid timestamp: %w", ErrInvalidLine, line, err)
+		}
+		point.Time = time.Unix(0, ts*int64(precision))
+	}
+	return point, nil
+}
+
+func parseFieldValue(value string) (any, error) {
+	switch {
+	case value == "":
+		return nil, errors.New("missing value")
+	case value[0] == '"':
+		if len(value) < 2 || value[len(value)-1] != '"' {
+			return nil, errors.New("unterminated string")
+		}
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1]), nil
+	case strings.HasSuffix(value, "i"):
+		return strconv.ParseInt(value[:len(value)-1], 10, 64)
+	case strings.HasSuffix(value, "u"):
+		return strconv.ParseUint(value[:len(value)-1], 10, 64)
+	}
+	switch value {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, errors.New("value is not finite")
+	}
+	return f, nil
+}
+
+// indexUnescaped returns the index of the first sep not escaped by a backslash and, when quoted is set,
+// not within a double-quoted string.
+func indexUnescaped(s string, sep byte, quoted bool) int {
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quoted && s[i] == '"':
+			inString = !inString
+		case !inString && s[i] == sep:
+			return i
+		}
+	}
+	return -1
+}
+
+func splitUnescaped(s string, sep byte, quoted bool) []string {
+	var parts []string
+	for {
+		i := indexUnescaped(s, sep, quoted)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+1:]
+	}
+}
+
+func cutUnescaped(s string) (string, string, bool) {
+	i := indexUnescaped(s, '=', false)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+1:], true
+}
+
+// unescape removes the backslashes escaping commas, equal signs and spaces in names, tag keys and values.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ").Replace(s)
+}
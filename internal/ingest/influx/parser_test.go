@@ -0,0 +1,105 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		giveData  string
+		precision time.Duration
+		want      []Point
+		wantErr   bool
+	}{
+		{
+			name:     "FieldTypes",
+			giveData: `system,host=web-1 load1=0.5,n_cpus=4i,uptime=100u,ok=true,status="up" 1700000000000000000`,
+			want: []Point{{
+				Measurement: "system",
+				Tags:        model.Labels{"host": "web-1"},
+				Fields: []Field{
+					{Key: "load1", Value: 0.5}, {Key: "n_cpus", Value: int64(4)}, {Key: "uptime", Value: uint64(100)},
+					{Key: "ok", Value: true}, {Key: "status", Value: "up"},
+				},
+				Time: time.Unix(1700000000, 0),
+			}},
+		},
+		{
+			name:      "Precision",
+			giveData:  "cpu usage=1 1700000000",
+			precision: time.Second,
+			want: []Point{{
+				Measurement: "cpu",
+				Fields:      []Field{{Key: "usage", Value: 1.0}},
+				Time:        time.Unix(1700000000, 0),
+			}},
+		},
+		{
+			name:     "EscapesAndComments",
+			giveData: "# comment\n\nmy\\ disk,path=/mnt/a\\,b,label\\=x=y free=1,msg=\"a \\\"b\\\", c=d\"\n",
+			want: []Point{{
+				Measurement: "my disk",
+				Tags:        model.Labels{"path": "/mnt/a,b", "label=x": "y"},
+				Fields:      []Field{{Key: "free", Value: 1.0}, {Key: "msg", Value: `a "b", c=d`}},
+			}},
+		},
+		{
+			name:     "MultipleLines",
+			giveData: "a x=1\nb y=2i",
+			want: []Point{
+				{Measurement: "a", Fields: []Field{{Key: "x", Value: 1.0}}},
+				{Measurement: "b", Fields: []Field{{Key: "y", Value: int64(2)}}},
+			},
+		},
+		{name: "MissingFields", giveData: "cpu", wantErr: true},
+		{name: "MissingMeasurement", giveData: ",host=a x=1", wantErr: true},
+		{name: "InvalidTag", giveData: "cpu,host x=1", wantErr: true},
+		{name: "InvalidField", giveData: "cpu x", wantErr: true},
+		{name: "InvalidInteger", giveData: "cpu x=1.5i", wantErr: true},
+		{name: "UnterminatedString", giveData: `cpu x="a`, wantErr: true},
+		{name: "NotFinite", giveData: "cpu x=NaN", wantErr: true},
+		{name: "InvalidTimestamp", giveData: "cpu x=1 now", wantErr: true},
+		{name: "InvalidSecondLine", giveData: "cpu x=1\ncpu", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			precision := tt.precision
+			if precision == 0 {
+				precision = time.Nanosecond
+			}
+			got, err := Parse([]byte(tt.giveData), precision)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidLine)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, got, len(tt.want))
+			for i, want := range tt.want {
+				assert.Equal(t, want.Measurement, got[i].Measurement)
+				assert.Equal(t, want.Tags, got[i].Tags)
+				assert.Equal(t, want.Fields, got[i].Fields)
+				assert.True(t, want.Time.Equal(got[i].Time), "time %v", got[i].Time)
+			}
+		})
+	}
+}
+
+func TestParsePrecision(t *testing.T) {
+	for precision, want := range map[string]time.Duration{
+		"": time.Nanosecond, "ns": time.Nanosecond, "n": time.Nanosecond, "us": time.Microsecond,
+		"u": time.Microsecond, "ms": time.Millisecond, "s": time.Second, "m": time.Minute, "h": time.Hour,
+	} {
+		got, err := ParsePrecision(precision)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, precision)
+	}
+	_, err := ParsePrecision("d")
+	require.Error(t, err)
+}
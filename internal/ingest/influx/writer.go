@@ -0,0 +1,104 @@
+package influx
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/itallix/go-metrics/internal/ingest"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+// Writer maps points to metrics and writes them to the storage. Every numeric field becomes a series named
+// <measurement>_<field> (or just <measurement> for the "value" field) labelled by the point tags.
+//
+// Float and boolean fields are gauges. Integer fields are gauges as well unless the metric name matches one of
+// the counter patterns: such fields are cumulative totals, see ingest.Cumulative. String fields are skipped.
+type Writer struct {
+	cumulative *ingest.Cumulative
+	counters   []string
+}
+
+// NewWriter constructs a writer, counters are shell patterns (see path.Match) of metric names, e.g. "net_*".
+func NewWriter(storage storage.Storage, counters []string) (*Writer, error) {
+	for _, pattern := range counters {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid counter pattern %q: %w", pattern, err)
+		}
+	}
+	return &Writer{cumulative: ingest.NewCumulative(storage), counters: counters}, nil
+}
+
+// Write stores the points in the order of their timestamps, so the newest value of a gauge wins.
+// Points without timestamp are considered to be written now.
+func (w *Writer) Write(ctx context.Context, points []Point) error {
+	sorted := make([]Point, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return !sorted[i].Time.IsZero() && (sorted[j].Time.IsZero() || sorted[i].Time.Before(sorted[j].Time))
+	})
+
+	return w.cumulative.Write(ctx, func(counter ingest.CounterFunc) ([]model.Metrics, error) {
+		var metrics []model.Metrics
+		for _, point := range sorted {
+			for _, field := range point.Fields {
+				metric, err := w.toMetric(ctx, point, field, counter)
+				if err != nil {
+					return nil, err
+				}
+				if metric != nil {
+					metrics = append(metrics, *metric)
+				}
+			}
+		}
+		return metrics, nil
+	})
+}
+
+// toMetric returns nil for string fields and unchanged counters.
+func (w *Writer) toMetric(ctx context.Context, point Point, field Field, counter ingest.CounterFunc) (*model.Metrics,
+	error) {
+	id := metricID(point.Measurement, field.Key)
+	var value float64
+	switch v := field.Value.(type) {
+	case float64:
+		value = v
+	case bool:
+		if v {
+			value = 1
+		}
+	case int64:
+		if w.isCounter(id) {
+			return counter(ctx, id, point.Tags, v)
+		}
+		value = float64(v)
+	case uint64:
+		if w.isCounter(id) {
+			return counter(ctx, id, point.Tags, int64(v))
+		}
+		value = float64(v)
+	default:
+		return nil, nil
+	}
+	metric := model.NewGauge(id, &value)
+	metric.Labels = point.Tags
+	return metric, nil
+}
+
+func (w *Writer) isCounter(id string) bool {
+	for _, pattern := range w.counters {
+		if matched, _ := path.Match(pattern, id); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func metricID(measurement, field string) string {
+	if field == "value" {
+		return measurement
+	}
+	return measurement + "_" + field
+}
@@ -0,0 +1,83 @@
+package influx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+func TestNewWriter(t *testing.T) {
+	_, err := NewWriter(memory.NewMemStorage(context.Background(), nil, nil), []string{"net_["})
+	require.Error(t, err)
+}
+
+func TestWriter_Write(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	w, err := NewWriter(s, []string{"net_bytes_*"})
+	require.NoError(t, err)
+
+	eth0 := model.Labels{"interface": "eth0"}
+	now := time.Now()
+	points := []Point{
+		{Measurement: "net", Tags: eth0, Fields: []Field{{Key: "bytes_recv", Value: int64(300)}}, Time: now},
+		{Measurement: "net", Tags: eth0, Fields: []Field{
+			{Key: "bytes_recv", Value: int64(100)}, {Key: "drop_in", Value: int64(2)},
+		}, Time: now.Add(-time.Second)},
+		{Measurement: "mem", Fields: []Field{
+			{Key: "used_percent", Value: 42.5}, {Key: "swap", Value: false}, {Key: "status", Value: "ok"},
+		}},
+		{Measurement: "temp", Fields: []Field{{Key: "value", Value: uint64(60)}}},
+	}
+	require.NoError(t, w.Write(ctx, points))
+
+	counter := model.Metrics{ID: "net_bytes_recv", MType: model.Counter, Labels: eth0}
+	require.NoError(t, s.Read(ctx, &counter))
+	assert.Equal(t, int64(300), *counter.Delta, "older point is applied first")
+
+	for id, want := range map[string]float64{"net_drop_in": 2, "mem_used_percent": 42.5, "mem_swap": 0, "temp": 60} {
+		gauge := model.Metrics{ID: id, MType: model.Gauge}
+		if id == "net_drop_in" {
+			gauge.Labels = eth0
+		}
+		require.NoError(t, s.Read(ctx, &gauge), id)
+		assert.InDelta(t, want, *gauge.Value, 1e-9, id)
+	}
+	status := model.Metrics{ID: "mem_status", MType: model.Gauge}
+	require.Error(t, s.Read(ctx, &status), "string fields are skipped")
+
+	tests := []struct {
+		name  string
+		total int64
+		want  int64
+	}{
+		{name: "Increased", total: 350, want: 350},
+		{name: "Unchanged", total: 350, want: 350},
+		{name: "Reset", total: 20, want: 370},
+		{name: "IncreasedAfterReset", total: 25, want: 375},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, w.Write(ctx, []Point{
+				{Measurement: "net", Tags: eth0, Fields: []Field{{Key: "bytes_recv", Value: tt.total}}},
+			}))
+			require.NoError(t, s.Read(ctx, &counter))
+			assert.Equal(t, tt.want, *counter.Delta)
+		})
+	}
+
+	// a new writer, e.g. after the restart, continues from the stored counter
+	w, err = NewWriter(s, []string{"net_bytes_*"})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(ctx, []Point{
+		{Measurement: "net", Tags: eth0, Fields: []Field{{Key: "bytes_recv", Value: int64(400)}}},
+	}))
+	require.NoError(t, s.Read(ctx, &counter))
+	assert.Equal(t, int64(400), *counter.Delta)
+}
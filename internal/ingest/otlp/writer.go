@@ -0,0 +1,341 @@
+// Package otlp receives OpenTelemetry metrics exported over OTLP, both gRPC and HTTP transports share the Writer.
+package otlp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/itallix/go-metrics/internal/ingest"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+// errRejected marks data points that cannot be stored, they are reported back as rejected.
+var errRejected = errors.New("data point is rejected")
+
+// Writer stores the data points of exported metrics, it implements the OTLP MetricsService.
+//
+// Every data point is a series of the metric name labelled by the resource and data point attributes,
+// the latter take precedence. Nested attribute values are flattened into dotted label names, e.g.
+// k8s={pod: a} becomes k8s.pod=a and list=[a, b] becomes list.0=a and list.1=b.
+//
+// Gauges are stored as gauges. Monotonic sums are counters: delta sums increase the counter by the rounded value,
+// cumulative sums are totals, see ingest.Cumulative. Non-monotonic sums are gauges, delta values change the stored
+// gauge. Summaries are stored as summaries. Explicit bucket histograms are stored as histograms, delta histograms
+// add their observations, cumulative ones are totals, see ingest.Cumulative. Exponential histograms, data points
+// without temporality or without recorded value and histograms with inconsistent buckets are rejected and reported
+// with the partial success of the response.
+type Writer struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+
+	storage    storage.Storage
+	cumulative *ingest.Cumulative
+}
+
+func NewWriter(storage storage.Storage) *Writer {
+	return &Writer{storage: storage, cumulative: ingest.NewCumulative(storage)}
+}
+
+// Export stores the data points of the request. Invalid series names are returned with the InvalidArgument code,
+// other storage errors with the Unavailable code, so the exporter retries the request.
+func (w *Writer) Export(ctx context.Context,
+	req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	var (
+		rejected int64
+		reason   error
+	)
+	err := w.cumulative.WriteWithHistograms(ctx, func(counter ingest.CounterFunc,
+		histogram ingest.HistogramFunc) ([]model.Metrics, error) {
+		rejected, reason = 0, nil
+		batch := &batch{writer: w, counter: counter, histogram: histogram, gauges: make(map[string]float64)}
+		for _, resourceMetrics := range req.GetResourceMetrics() {
+			resource := attributes(nil, resourceMetrics.GetResource().GetAttributes())
+			for _, scopeMetrics := range resourceMetrics.GetScopeMetrics() {
+				for _, metric := range scopeMetrics.GetMetrics() {
+					n, err := batch.add(ctx, resource, metric)
+					if errors.Is(err, errRejected) {
+						rejected += n
+						reason = err
+						continue
+					}
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		return batch.metrics, nil
+	})
+	if errors.Is(err, model.ErrInvalidSeries) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	resp := &colmetricspb.ExportMetricsServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{
+			RejectedDataPoints: rejected,
+			ErrorMessage:       reason.Error(),
+		}
+	}
+	return resp, nil
+}
+
+// batch collects the metrics of a single request.
+type batch struct {
+	writer    *Writer
+	counter   ingest.CounterFunc
+	histogram ingest.HistogramFunc
+	metrics   []model.Metrics
+	// gauges holds the values of gauges changed by non-monotonic delta sums of the batch by series key.
+	gauges map[string]float64
+}
+
+// add adds the data points of the metric, it returns the number of rejected data points with errRejected.
+func (b *batch) add(ctx context.Context, resource model.Labels, metric *metricspb.Metric) (int64, error) {
+	name := metric.GetName()
+	switch {
+	case metric.GetGauge() != nil:
+		var rejected int64
+		for _, point := range metric.GetGauge().GetDataPoints() {
+			value, ok := numberValue(point)
+			if !ok {
+				rejected++
+				continue
+			}
+			gauge := model.NewGauge(name, &value)
+			gauge.Labels = attributes(resource, point.GetAttributes())
+			b.metrics = append(b.metrics, *gauge)
+		}
+		return rejectedPoints(rejected, name, "no finite value")
+	case metric.GetSum() != nil:
+		return b.addSum(ctx, name, resource, metric.GetSum())
+	case metric.GetSummary() != nil:
+		var rejected int64
+		for _, point := range metric.GetSummary().GetDataPoints() {
+			summary := &model.SummaryValue{Sum: point.GetSum(), Count: point.GetCount()}
+			for _, quantile := range point.GetQuantileValues() {
+				summary.Quantiles = append(summary.Quantiles,
+					model.Quantile{Quantile: quantile.GetQuantile(), Value: quantile.GetValue()})
+			}
+			if summary.Validate() != nil {
+				rejected++
+				continue
+			}
+			metric := model.NewSummary(name, summary)
+			metric.Labels = attributes(resource, point.GetAttributes())
+			b.metrics = append(b.metrics, *metric)
+		}
+		return rejectedPoints(rejected, name, "invalid quantiles")
+	case metric.GetHistogram() != nil:
+		return b.addHistogram(ctx, name, resource, metric.GetHistogram())
+	case metric.GetExponentialHistogram() != nil:
+		return rejectedPoints(int64(len(metric.GetExponentialHistogram().GetDataPoints())), name,
+			"exponential histograms are not supported")
+	default:
+		return 0, nil
+	}
+}
+
+func (b *batch) addSum(ctx context.Context, name string, resource model.Labels, sum *metricspb.Sum) (int64, error) {
+	temporality := sum.GetAggregationTemporality()
+	if temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED {
+		return rejectedPoints(int64(len(sum.GetDataPoints())), name, "aggregation temporality is unspecified")
+	}
+	delta := temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+
+	var rejected int64
+	for _, point := range sum.GetDataPoints() {
+		value, ok := numberValue(point)
+		if !ok {
+			rejected++
+			continue
+		}
+		labels := attributes(resource, point.GetAttributes())
+		switch {
+		case sum.GetIsMonotonic() && delta:
+			if increase := int64(math.Round(value)); increase != 0 {
+				counter := model.NewCounter(name, &increase)
+				counter.Labels = labels
+				b.metrics = append(b.metrics, *counter)
+			}
+		case sum.GetIsMonotonic():
+			counter, err := b.counter(ctx, name, labels, int64(math.Round(value)))
+			if err != nil {
+				return 0, err
+			}
+			if counter != nil {
+				b.metrics = append(b.metrics, *counter)
+			}
+		case delta:
+			gauge, err := b.changeGauge(ctx, name, labels, value)
+			if err != nil {
+				return 0, err
+			}
+			b.metrics = append(b.metrics, *gauge)
+		default:
+			gauge := model.NewGauge(name, &value)
+			gauge.Labels = labels
+			b.metrics = append(b.metrics, *gauge)
+		}
+	}
+	return rejectedPoints(rejected, name, "no finite value")
+}
+
+func (b *batch) addHistogram(ctx context.Context, name string, resource model.Labels,
+	histogram *metricspb.Histogram) (int64, error) {
+	temporality := histogram.GetAggregationTemporality()
+	if temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED {
+		return rejectedPoints(int64(len(histogram.GetDataPoints())), name, "aggregation temporality is unspecified")
+	}
+
+	var rejected int64
+	for _, point := range histogram.GetDataPoints() {
+		value, ok := histogramValue(point)
+		if !ok {
+			rejected++
+			continue
+		}
+		labels := attributes(resource, point.GetAttributes())
+		if temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+			if value.Count > 0 {
+				metric := model.NewHistogram(name, value)
+				metric.Labels = labels
+				b.metrics = append(b.metrics, *metric)
+			}
+			continue
+		}
+		metric, err := b.histogram(ctx, name, labels, value)
+		if err != nil {
+			return 0, err
+		}
+		if metric != nil {
+			b.metrics = append(b.metrics, *metric)
+		}
+	}
+	return rejectedPoints(rejected, name, "no recorded value or inconsistent buckets")
+}
+
+// changeGauge returns the gauge changed by the delta, the stored value or the value changed earlier in the batch.
+func (b *batch) changeGauge(ctx context.Context, name string, labels model.Labels, delta float64) (*model.Metrics,
+	error) {
+	key := model.SeriesKey(name, labels)
+	value, ok := b.gauges[key]
+	if !ok {
+		current := model.Metrics{ID: name, MType: model.Gauge, Labels: labels}
+		err := b.writer.storage.Read(ctx, &current)
+		if err != nil && !errors.Is(err, storage.ErrMetricNotFound) {
+			return nil, fmt.Errorf("cannot read gauge %s: %w", key, err)
+		}
+		if err == nil {
+			value = *current.Value
+		}
+	}
+	value += delta
+	b.gauges[key] = value
+	gauge := model.NewGauge(name, &value)
+	gauge.Labels = labels
+	return gauge, nil
+}
+
+func rejectedPoints(rejected int64, name, reason string) (int64, error) {
+	if rejected == 0 {
+		return 0, nil
+	}
+	return rejected, fmt.Errorf("%w: metric %s: %s", errRejected, name, reason)
+}
+
+// numberValue returns the value of the data point, false when it has no finite value.
+func numberValue(point *metricspb.NumberDataPoint) (float64, bool) {
+	if point.GetFlags()&uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0 {
+		return 0, false
+	}
+	var value float64
+	switch v := point.GetValue().(type) {
+	case *metricspb.NumberDataPoint_AsDouble:
+		value = v.AsDouble
+	case *metricspb.NumberDataPoint_AsInt:
+		value = float64(v.AsInt)
+	default:
+		return 0, false
+	}
+	return value, !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// histogramValue converts the data point, false when it has no recorded value or its buckets are inconsistent.
+// OTLP buckets count the observations within their bounds and the last one has no upper bound, while the model
+// buckets are cumulative and the +Inf bucket is implied by the count.
+func histogramValue(point *metricspb.HistogramDataPoint) (*model.HistogramValue, bool) {
+	if point.GetFlags()&uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0 {
+		return nil, false
+	}
+	bounds, counts := point.GetExplicitBounds(), point.GetBucketCounts()
+	// a data point without buckets has the count and the sum only
+	if len(counts) == 0 {
+		counts, bounds = []uint64{point.GetCount()}, nil
+	}
+	if len(counts) != len(bounds)+1 {
+		return nil, false
+	}
+	value := &model.HistogramValue{Buckets: make([]model.Bucket, 0, len(bounds)), Sum: point.GetSum(),
+		Count: point.GetCount()}
+	var cumulative uint64
+	for i, bound := range bounds {
+		cumulative += counts[i]
+		value.Buckets = append(value.Buckets, model.Bucket{UpperBound: bound, Count: cumulative})
+	}
+	if cumulative+counts[len(bounds)] != value.Count || math.IsNaN(value.Sum) || math.IsInf(value.Sum, 0) {
+		return nil, false
+	}
+	return value, value.Validate() == nil
+}
+
+// attributes returns the labels of base overridden by the attributes, nil when there are none.
+func attributes(base model.Labels, attrs []*commonpb.KeyValue) model.Labels {
+	if len(base) == 0 && len(attrs) == 0 {
+		return nil
+	}
+	labels := make(model.Labels, len(base)+len(attrs))
+	for name, value := range base {
+		labels[name] = value
+	}
+	for _, attr := range attrs {
+		flatten(labels, attr.GetKey(), attr.GetValue())
+	}
+	return labels
+}
+
+func flatten(labels model.Labels, name string, value *commonpb.AnyValue) {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		labels[name] = v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		labels[name] = strconv.FormatBool(v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		labels[name] = strconv.FormatInt(v.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		labels[name] = strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	case *commonpb.AnyValue_BytesValue:
+		labels[name] = base64.StdEncoding.EncodeToString(v.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		for i, item := range v.ArrayValue.GetValues() {
+			flatten(labels, name+"."+strconv.Itoa(i), item)
+		}
+	case *commonpb.AnyValue_KvlistValue:
+		for _, kv := range v.KvlistValue.GetValues() {
+			flatten(labels, name+"."+kv.GetKey(), kv.GetValue())
+		}
+	}
+}
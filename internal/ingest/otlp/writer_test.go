@@ -0,0 +1,221 @@
+package otlp
+
+import (
+	"context"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+const (
+	cumulative = metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	delta      = metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+)
+
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func doublePoint(value float64, attrs ...*commonpb.KeyValue) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{Attributes: attrs, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: value}}
+}
+
+func intPoint(value int64, attrs ...*commonpb.KeyValue) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{Attributes: attrs, Value: &metricspb.NumberDataPoint_AsInt{AsInt: value}}
+}
+
+func sum(name string, temporality metricspb.AggregationTemporality, monotonic bool,
+	points ...*metricspb.NumberDataPoint) *metricspb.Metric {
+	return &metricspb.Metric{Name: name, Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+		DataPoints: points, AggregationTemporality: temporality, IsMonotonic: monotonic,
+	}}}
+}
+
+func request(metrics ...*metricspb.Metric) *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{ResourceMetrics: []*metricspb.ResourceMetrics{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			stringAttr("service.name", "checkout"),
+			stringAttr("host", "resource"),
+			{Key: "k8s", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{
+				KvlistValue: &commonpb.KeyValueList{Values: []*commonpb.KeyValue{stringAttr("pod", "checkout-1")}},
+			}}},
+		}},
+		ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: metrics}},
+	}}}
+}
+
+func labels(extra ...string) model.Labels {
+	l := model.Labels{"service.name": "checkout", "host": "resource", "k8s.pod": "checkout-1"}
+	for i := 0; i < len(extra); i += 2 {
+		l[extra[i]] = extra[i+1]
+	}
+	return l
+}
+
+func TestWriter_Export(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	w := NewWriter(s)
+
+	resp, err := w.Export(ctx, request(
+		&metricspb.Metric{Name: "cpu.temperature", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{
+			DataPoints: []*metricspb.NumberDataPoint{
+				doublePoint(61.5, stringAttr("host", "web-1")),
+				doublePoint(math.NaN()),
+			},
+		}}},
+		sum("http.requests", cumulative, true, intPoint(100), intPoint(100)),
+		sum("http.requests", cumulative, true, intPoint(150)),
+		sum("bytes.sent", delta, true, doublePoint(10.4), doublePoint(5)),
+		sum("queue.size", cumulative, false, intPoint(7)),
+		sum("active.sessions", delta, false, intPoint(3), intPoint(-1)),
+		sum("legacy", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED, true, intPoint(1)),
+		&metricspb.Metric{Name: "rpc.duration", Data: &metricspb.Metric_Summary{Summary: &metricspb.Summary{
+			DataPoints: []*metricspb.SummaryDataPoint{{
+				Count: 4, Sum: 2,
+				QuantileValues: []*metricspb.SummaryDataPoint_ValueAtQuantile{{Quantile: 0.5, Value: 0.4}},
+			}},
+		}}},
+		&metricspb.Metric{Name: "latency", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			DataPoints: []*metricspb.HistogramDataPoint{{Count: 1}},
+		}}},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), resp.GetPartialSuccess().GetRejectedDataPoints())
+	assert.NotEmpty(t, resp.GetPartialSuccess().GetErrorMessage())
+
+	counters := map[string]int64{"http.requests": 150, "bytes.sent": 15}
+	for id, want := range counters {
+		metric := model.Metrics{ID: id, MType: model.Counter, Labels: labels()}
+		require.NoError(t, s.Read(ctx, &metric), id)
+		assert.Equal(t, want, *metric.Delta, id)
+	}
+	gauges := []struct {
+		id     string
+		labels model.Labels
+		want   float64
+	}{
+		{id: "cpu.temperature", labels: labels("host", "web-1"), want: 61.5},
+		{id: "queue.size", labels: labels(), want: 7},
+		{id: "active.sessions", labels: labels(), want: 2},
+	}
+	for _, tt := range gauges {
+		metric := model.Metrics{ID: tt.id, MType: model.Gauge, Labels: tt.labels}
+		require.NoError(t, s.Read(ctx, &metric), tt.id)
+		assert.InDelta(t, tt.want, *metric.Value, 1e-9, tt.id)
+	}
+	summary := model.Metrics{ID: "rpc.duration", MType: model.Summary, Labels: labels()}
+	require.NoError(t, s.Read(ctx, &summary))
+	assert.Equal(t, uint64(4), summary.Summary.Count)
+
+	// cumulative sums follow the totals, delta sums change the stored values
+	resp, err = w.Export(ctx, request(
+		sum("http.requests", cumulative, true, intPoint(175)),
+		sum("active.sessions", delta, false, intPoint(-2)),
+	))
+	require.NoError(t, err)
+	assert.Nil(t, resp.GetPartialSuccess())
+	requests := model.Metrics{ID: "http.requests", MType: model.Counter, Labels: labels()}
+	require.NoError(t, s.Read(ctx, &requests))
+	assert.Equal(t, int64(175), *requests.Delta)
+	sessions := model.Metrics{ID: "active.sessions", MType: model.Gauge, Labels: labels()}
+	require.NoError(t, s.Read(ctx, &sessions))
+	assert.InDelta(t, 0.0, *sessions.Value, 1e-9)
+}
+
+func histogram(name string, temporality metricspb.AggregationTemporality,
+	points ...*metricspb.HistogramDataPoint) *metricspb.Metric {
+	return &metricspb.Metric{Name: name, Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+		DataPoints: points, AggregationTemporality: temporality,
+	}}}
+}
+
+func histogramPoint(sum float64, counts ...uint64) *metricspb.HistogramDataPoint {
+	point := &metricspb.HistogramDataPoint{Sum: &sum, ExplicitBounds: []float64{0.1, 1}, BucketCounts: counts}
+	for _, count := range counts {
+		point.Count += count
+	}
+	return point
+}
+
+func TestWriter_ExportHistograms(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	w := NewWriter(s)
+	read := func(id string) *model.HistogramValue {
+		metric := model.Metrics{ID: id, MType: model.Histogram, Labels: labels()}
+		require.NoError(t, s.Read(ctx, &metric), id)
+		return metric.Histogram
+	}
+
+	// per bucket counts become cumulative ones, the last bucket is the implied +Inf one
+	mismatch := histogramPoint(1, 1, 1)
+	mismatch.Count = 3
+	resp, err := w.Export(ctx, request(
+		histogram("rpc.duration", cumulative, histogramPoint(2.5, 1, 2, 1)),
+		histogram("db.duration", delta, histogramPoint(0.5, 2, 1, 0)),
+		histogram("invalid", delta, &metricspb.HistogramDataPoint{Count: 1, ExplicitBounds: []float64{1},
+			BucketCounts: []uint64{1}}, mismatch),
+	))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), resp.GetPartialSuccess().GetRejectedDataPoints())
+	want := &model.HistogramValue{Buckets: []model.Bucket{{UpperBound: 0.1, Count: 1}, {UpperBound: 1, Count: 3}},
+		Sum: 2.5, Count: 4}
+	assert.Equal(t, want, read("rpc.duration"))
+
+	// cumulative histograms follow the totals, delta histograms add their observations
+	resp, err = w.Export(ctx, request(
+		histogram("rpc.duration", cumulative, histogramPoint(4, 1, 3, 2)),
+		histogram("db.duration", delta, histogramPoint(3, 0, 1, 1)),
+	))
+	require.NoError(t, err)
+	assert.Nil(t, resp.GetPartialSuccess())
+	want = &model.HistogramValue{Buckets: []model.Bucket{{UpperBound: 0.1, Count: 1}, {UpperBound: 1, Count: 4}},
+		Sum: 4, Count: 6}
+	assert.Equal(t, want, read("rpc.duration"))
+	want = &model.HistogramValue{Buckets: []model.Bucket{{UpperBound: 0.1, Count: 2}, {UpperBound: 1, Count: 4}},
+		Sum: 3.5, Count: 5}
+	assert.Equal(t, want, read("db.duration"))
+
+	// a restarted source reports fewer observations, which are added entirely
+	_, err = w.Export(ctx, request(histogram("rpc.duration", cumulative, histogramPoint(0.05, 1, 0, 0))))
+	require.NoError(t, err)
+	want = &model.HistogramValue{Buckets: []model.Bucket{{UpperBound: 0.1, Count: 2}, {UpperBound: 1, Count: 5}},
+		Sum: 4.05, Count: 7}
+	assert.Equal(t, want, read("rpc.duration"))
+}
+
+func TestWriter_ExportGRPC(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(server, NewWriter(s))
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = colmetricspb.NewMetricsServiceClient(conn).Export(ctx, request(sum("http.requests", delta, true, intPoint(3))))
+	require.NoError(t, err)
+
+	metric := model.Metrics{ID: "http.requests", MType: model.Counter, Labels: labels()}
+	require.NoError(t, s.Read(ctx, &metric))
+	assert.Equal(t, int64(3), *metric.Delta)
+}
@@ -0,0 +1,165 @@
+// Package remotewrite receives the Prometheus remote write requests, so Prometheus or vmagent can forward
+// the scraped series to the storage.
+package remotewrite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/itallix/go-metrics/internal/ingest"
+	"github.com/itallix/go-metrics/internal/ingest/remotewrite/prompb"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+const nameLabel = "__name__"
+
+// MaxDecodedSize limits the size of the decompressed WriteRequest, the snappy header declares the size, so
+// larger requests are rejected before decompression.
+const MaxDecodedSize = 32 << 20
+
+var ErrInvalidRequest = errors.New("invalid remote write request")
+
+// counterSuffixes are the suffixes of the cumulative series of counters, histograms and summaries.
+var counterSuffixes = []string{"_total", "_bucket", "_count"}
+
+// Decode decodes the snappy compressed WriteRequest of at most MaxDecodedSize bytes.
+func Decode(body []byte) (*prompb.WriteRequest, error) {
+	size, err := snappy.DecodedLen(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	if size > MaxDecodedSize {
+		return nil, fmt.Errorf("%w: decoded size %d exceeds %d bytes", ErrInvalidRequest, size, MaxDecodedSize)
+	}
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	var req prompb.WriteRequest
+	if err = proto.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	return &req, nil
+}
+
+// Writer stores the latest sample of every series of the remote write requests, labelled by the series labels
+// except __name__, which becomes the metric ID.
+//
+// The type of a series is inferred from the metadata of its family, which Prometheus sends periodically rather
+// than with every request, so the types are remembered. Samples of counters and of the _bucket and _count series
+// of histograms and summaries are cumulative totals rounded to integers, see ingest.Cumulative. Other series are
+// gauges. Series without known metadata are counters when the name ends with _total. Samples that are not finite,
+// e.g. staleness markers, are skipped.
+type Writer struct {
+	cumulative *ingest.Cumulative
+	// types holds the metric type of every family received with the metadata.
+	types map[string]prompb.MetricMetadata_MetricType
+	mu    sync.RWMutex
+}
+
+func NewWriter(storage storage.Storage) *Writer {
+	return &Writer{
+		cumulative: ingest.NewCumulative(storage),
+		types:      make(map[string]prompb.MetricMetadata_MetricType),
+	}
+}
+
+func (w *Writer) Write(ctx context.Context, req *prompb.WriteRequest) error {
+	w.mu.Lock()
+	for _, metadata := range req.GetMetadata() {
+		w.types[metadata.GetMetricFamilyName()] = metadata.GetType()
+	}
+	w.mu.Unlock()
+
+	return w.cumulative.Write(ctx, func(counter ingest.CounterFunc) ([]model.Metrics, error) {
+		var metrics []model.Metrics
+		for _, series := range req.GetTimeseries() {
+			metric, err := w.toMetric(ctx, series, counter)
+			if err != nil {
+				return nil, err
+			}
+			if metric != nil {
+				metrics = append(metrics, *metric)
+			}
+		}
+		return metrics, nil
+	})
+}
+
+// toMetric returns nil for series without finite samples and unchanged counters.
+func (w *Writer) toMetric(ctx context.Context, series *prompb.TimeSeries, counter ingest.CounterFunc) (*model.Metrics,
+	error) {
+	var (
+		id     string
+		labels model.Labels
+	)
+	for _, label := range series.GetLabels() {
+		if label.GetName() == nameLabel {
+			id = label.GetValue()
+			continue
+		}
+		if labels == nil {
+			labels = make(model.Labels)
+		}
+		labels[label.GetName()] = label.GetValue()
+	}
+	if id == "" {
+		return nil, fmt.Errorf("%w: series %v has no %s label", ErrInvalidRequest, labels, nameLabel)
+	}
+
+	var latest *prompb.Sample
+	for _, sample := range series.GetSamples() {
+		if math.IsNaN(sample.GetValue()) || math.IsInf(sample.GetValue(), 0) {
+			continue
+		}
+		if latest == nil || sample.GetTimestamp() >= latest.GetTimestamp() {
+			latest = sample
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+
+	value := latest.GetValue()
+	if w.isCounter(id) {
+		return counter(ctx, id, labels, int64(math.Round(value)))
+	}
+	metric := model.NewGauge(id, &value)
+	metric.Labels = labels
+	return metric, nil
+}
+
+func (w *Writer) isCounter(id string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if mtype, ok := w.types[id]; ok {
+		return mtype == prompb.MetricMetadata_COUNTER
+	}
+	for _, suffix := range counterSuffixes {
+		family, ok := strings.CutSuffix(id, suffix)
+		if !ok {
+			continue
+		}
+		if mtype, known := w.types[family]; known {
+			switch mtype {
+			case prompb.MetricMetadata_COUNTER:
+				// OpenMetrics counter families are named without the _total suffix
+				return suffix == "_total"
+			case prompb.MetricMetadata_HISTOGRAM, prompb.MetricMetadata_SUMMARY:
+				return suffix != "_total"
+			default:
+				return false
+			}
+		}
+	}
+	// the family is unknown, e.g. its metadata has not been received yet
+	return strings.HasSuffix(id, "_total")
+}
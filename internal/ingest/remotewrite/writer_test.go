@@ -0,0 +1,123 @@
+package remotewrite
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/itallix/go-metrics/internal/ingest/remotewrite/prompb"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+// staleNaN is the Prometheus staleness marker.
+var staleNaN = math.Float64frombits(0x7ff0000000000002)
+
+func series(name string, labels map[string]string, samples ...*prompb.Sample) *prompb.TimeSeries {
+	ts := &prompb.TimeSeries{Labels: []*prompb.Label{{Name: nameLabel, Value: name}}, Samples: samples}
+	for labelName, value := range labels {
+		ts.Labels = append(ts.Labels, &prompb.Label{Name: labelName, Value: value})
+	}
+	return ts
+}
+
+func TestDecode(t *testing.T) {
+	req := &prompb.WriteRequest{Timeseries: []*prompb.TimeSeries{
+		series("up", map[string]string{"job": "node"}, &prompb.Sample{Value: 1, Timestamp: 1000}),
+	}}
+	data, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	got, err := Decode(snappy.Encode(nil, data))
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(req, got))
+
+	_, err = Decode(data)
+	require.ErrorIs(t, err, ErrInvalidRequest, "not compressed")
+	_, err = Decode(snappy.Encode(nil, []byte("not a protobuf")))
+	require.ErrorIs(t, err, ErrInvalidRequest)
+	// the header declares 2 GiB of decoded data, which is rejected without allocating it
+	_, err = Decode(binary.AppendUvarint(nil, 1<<31))
+	require.ErrorIs(t, err, ErrInvalidRequest, "too large")
+}
+
+func TestWriter_Write(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	w := NewWriter(s)
+
+	node := map[string]string{"job": "node"}
+	require.NoError(t, w.Write(ctx, &prompb.WriteRequest{
+		Metadata: []*prompb.MetricMetadata{
+			{Type: prompb.MetricMetadata_COUNTER, MetricFamilyName: "node_cpu_seconds"},
+			{Type: prompb.MetricMetadata_HISTOGRAM, MetricFamilyName: "http_duration_seconds"},
+			{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: "queue_total"},
+			{Type: prompb.MetricMetadata_SUMMARY, MetricFamilyName: "rpc_seconds"},
+		},
+		Timeseries: []*prompb.TimeSeries{
+			series("node_cpu_seconds_total", node,
+				&prompb.Sample{Value: 12.6, Timestamp: 2000}, &prompb.Sample{Value: 10.2, Timestamp: 1000}),
+			series("http_duration_seconds_bucket", map[string]string{"le": "0.1"}, &prompb.Sample{Value: 7}),
+			series("http_duration_seconds_count", nil, &prompb.Sample{Value: 9}),
+			series("http_duration_seconds_sum", nil, &prompb.Sample{Value: 0.75}),
+			series("queue_total", nil, &prompb.Sample{Value: 3}),
+			series("rpc_seconds", map[string]string{"quantile": "0.5"}, &prompb.Sample{Value: 0.2}),
+			series("requests_total", nil, &prompb.Sample{Value: 42}),
+			series("node_load1", node,
+				&prompb.Sample{Value: 0.5, Timestamp: 1000}, &prompb.Sample{Value: staleNaN, Timestamp: 2000}),
+			series("node_load5", node, &prompb.Sample{Value: staleNaN, Timestamp: 2000}),
+		},
+	}))
+
+	counters := []struct {
+		id     string
+		labels model.Labels
+		want   int64
+	}{
+		{id: "node_cpu_seconds_total", labels: model.Labels{"job": "node"}, want: 13},
+		{id: "http_duration_seconds_bucket", labels: model.Labels{"le": "0.1"}, want: 7},
+		{id: "http_duration_seconds_count", want: 9},
+		{id: "requests_total", want: 42},
+	}
+	for _, tt := range counters {
+		metric := model.Metrics{ID: tt.id, MType: model.Counter, Labels: tt.labels}
+		require.NoError(t, s.Read(ctx, &metric), tt.id)
+		assert.Equal(t, tt.want, *metric.Delta, tt.id)
+	}
+	gauges := []struct {
+		id     string
+		labels model.Labels
+		want   float64
+	}{
+		{id: "http_duration_seconds_sum", want: 0.75},
+		{id: "queue_total", want: 3},
+		{id: "rpc_seconds", labels: model.Labels{"quantile": "0.5"}, want: 0.2},
+		{id: "node_load1", labels: model.Labels{"job": "node"}, want: 0.5},
+	}
+	for _, tt := range gauges {
+		metric := model.Metrics{ID: tt.id, MType: model.Gauge, Labels: tt.labels}
+		require.NoError(t, s.Read(ctx, &metric), tt.id)
+		assert.InDelta(t, tt.want, *metric.Value, 1e-9, tt.id)
+	}
+	stale := model.Metrics{ID: "node_load5", MType: model.Gauge, Labels: model.Labels{"job": "node"}}
+	require.Error(t, s.Read(ctx, &stale), "series with stale samples only are skipped")
+
+	// the metadata is remembered and counters follow the reported totals
+	require.NoError(t, w.Write(ctx, &prompb.WriteRequest{Timeseries: []*prompb.TimeSeries{
+		series("http_duration_seconds_count", nil, &prompb.Sample{Value: 12}),
+	}}))
+	count := model.Metrics{ID: "http_duration_seconds_count", MType: model.Counter}
+	require.NoError(t, s.Read(ctx, &count))
+	assert.Equal(t, int64(12), *count.Delta)
+
+	err := w.Write(ctx, &prompb.WriteRequest{Timeseries: []*prompb.TimeSeries{
+		{Labels: []*prompb.Label{{Name: "job", Value: "node"}}, Samples: []*prompb.Sample{{Value: 1}}},
+	}})
+	require.ErrorIs(t, err, ErrInvalidRequest, "series without name")
+}
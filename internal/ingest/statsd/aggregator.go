@@ -0,0 +1,152 @@
+package statsd
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// aggregator accumulates the samples of the current flush window per series:
+// counters are summed up, gauges keep the last value, timers and histograms are observed into
+// a histogram and sets count unique values.
+type aggregator struct {
+	counters   map[string]*counter
+	gauges     map[string]*gauge
+	histograms map[string]*model.Metrics
+	sets       map[string]*set
+	buckets    []float64
+	mu         sync.Mutex
+}
+
+type counter struct {
+	id     string
+	labels model.Labels
+	sum    float64
+}
+
+type gauge struct {
+	id     string
+	labels model.Labels
+	value  float64
+	// relative is set while the window has only signed changes, they are applied to the stored value on flush.
+	relative bool
+}
+
+type set struct {
+	id     string
+	labels model.Labels
+	values map[string]struct{}
+}
+
+func newAggregator(buckets []float64) *aggregator {
+	a := &aggregator{buckets: buckets}
+	a.reset()
+	return a
+}
+
+func (a *aggregator) reset() {
+	a.counters = make(map[string]*counter)
+	a.gauges = make(map[string]*gauge)
+	a.histograms = make(map[string]*model.Metrics)
+	a.sets = make(map[string]*set)
+}
+
+func (a *aggregator) add(s sample) {
+	key := model.SeriesKey(s.name, s.labels)
+	// values are validated by the parser
+	value, _ := strconv.ParseFloat(s.value, 64)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch s.mtype {
+	case typeCounter:
+		c, ok := a.counters[key]
+		if !ok {
+			c = &counter{id: s.name, labels: s.labels}
+			a.counters[key] = c
+		}
+		c.sum += value / s.rate
+	case typeGauge:
+		g, ok := a.gauges[key]
+		if !ok {
+			g = &gauge{id: s.name, labels: s.labels, relative: true}
+			a.gauges[key] = g
+		}
+		if s.relative {
+			g.value += value
+		} else {
+			g.value = value
+			g.relative = false
+		}
+	case typeTimer, typeHistogram:
+		h, ok := a.histograms[key]
+		if !ok {
+			h = model.NewHistogram(s.name, model.NewHistogramValue(a.buckets))
+			h.Labels = s.labels
+			a.histograms[key] = h
+		}
+		if s.mtype == typeTimer {
+			// timers are reported in milliseconds, histograms are in seconds the same as the agent latencies
+			value /= 1000
+		}
+		h.Histogram.ObserveN(value, uint64(math.Round(1/s.rate)))
+	case typeSet:
+		st, ok := a.sets[key]
+		if !ok {
+			st = &set{id: s.name, labels: s.labels, values: make(map[string]struct{})}
+			a.sets[key] = st
+		}
+		st.values[s.value] = struct{}{}
+	}
+}
+
+// flush returns the aggregates of the window ordered by series key and starts a new window.
+// Gauges that have only been changed by signed values are returned separately.
+func (a *aggregator) flush() ([]model.Metrics, []model.Metrics) {
+	a.mu.Lock()
+	counters, gauges, histograms, sets := a.counters, a.gauges, a.histograms, a.sets
+	a.reset()
+	a.mu.Unlock()
+
+	var metrics, relative []model.Metrics
+	for _, key := range sortedKeys(counters) {
+		c := counters[key]
+		delta := int64(math.Round(c.sum))
+		metric := model.NewCounter(c.id, &delta)
+		metric.Labels = c.labels
+		metrics = append(metrics, *metric)
+	}
+	for _, key := range sortedKeys(gauges) {
+		g := gauges[key]
+		metric := model.NewGauge(g.id, &g.value)
+		metric.Labels = g.labels
+		if g.relative {
+			relative = append(relative, *metric)
+			continue
+		}
+		metrics = append(metrics, *metric)
+	}
+	for _, key := range sortedKeys(histograms) {
+		metrics = append(metrics, *histograms[key])
+	}
+	for _, key := range sortedKeys(sets) {
+		st := sets[key]
+		count := float64(len(st.values))
+		metric := model.NewGauge(st.id, &count)
+		metric.Labels = st.labels
+		metrics = append(metrics, *metric)
+	}
+	return metrics, relative
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
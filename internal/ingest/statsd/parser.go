@@ -0,0 +1,97 @@
+// Package statsd receives StatsD and DogStatsD lines over UDP and TCP, aggregates them in a flush window
+// and writes the aggregates to the storage.
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// StatsD metric types.
+const (
+	typeCounter   = "c"
+	typeGauge     = "g"
+	typeTimer     = "ms"
+	typeHistogram = "h"
+	typeSet       = "s"
+)
+
+// minSampleRate keeps the number of events a sampled value stands for within uint32.
+const minSampleRate = 1.0 / math.MaxUint32
+
+var ErrInvalidLine = errors.New("invalid statsd line")
+
+// sample is a single parsed line: <name>:<value>|<type>[|@<sample rate>][|#<tag>:<value>,...].
+type sample struct {
+	name  string
+	value string
+	mtype string
+	// rate is the share of the sampled events, the value stands for 1/rate events.
+	rate   float64
+	labels model.Labels
+	// relative is set for gauges with a signed value, which changes the gauge instead of setting it.
+	relative bool
+}
+
+func parseLine(line string) (sample, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return sample{}, fmt.Errorf("%w %q: missing name", ErrInvalidLine, line)
+	}
+	parts := strings.Split(rest, "|")
+	if len(parts) < 2 || parts[0] == "" {
+		return sample{}, fmt.Errorf("%w %q: missing value or type", ErrInvalidLine, line)
+	}
+	s := sample{name: name, value: parts[0], mtype: parts[1], rate: 1}
+	switch s.mtype {
+	case typeCounter, typeTimer, typeHistogram:
+		if _, err := strconv.ParseFloat(s.value, 64); err != nil {
+			return sample{}, fmt.Errorf("%w %q: %w", ErrInvalidLine, line, err)
+		}
+	case typeGauge:
+		if _, err := strconv.ParseFloat(s.value, 64); err != nil {
+			return sample{}, fmt.Errorf("%w %q: %w", ErrInvalidLine, line, err)
+		}
+		s.relative = s.value[0] == '+' || s.value[0] == '-'
+	case typeSet:
+	default:
+		return sample{}, fmt.Errorf("%w %q: unknown type %q", ErrInvalidLine, line, s.mtype)
+	}
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate < minSampleRate || rate > 1 {
+				return sample{}, fmt.Errorf("%w %q: invalid sample rate", ErrInvalidLine, line)
+			}
+			s.rate = rate
+		case strings.HasPrefix(part, "#"):
+			s.labels = parseTags(part[1:])
+		default:
+			// other DogStatsD extensions, e.g. timestamps and container ids, are ignored
+		}
+	}
+	if err := model.ValidateSeries(s.name, s.labels); err != nil {
+		return sample{}, fmt.Errorf("%w %q: %w", ErrInvalidLine, line, err)
+	}
+	return s, nil
+}
+
+// parseTags converts DogStatsD tags to labels, a tag without value becomes a label with an empty value.
+func parseTags(tags string) model.Labels {
+	labels := make(model.Labels)
+	for _, tag := range strings.Split(tags, ",") {
+		if tag == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(tag, ":")
+		labels[name] = value
+	}
+	return labels
+}
@@ -0,0 +1,65 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    sample
+		wantErr bool
+	}{
+		{
+			name: "counter",
+			line: "api.requests:1|c",
+			want: sample{name: "api.requests", value: "1", mtype: typeCounter, rate: 1},
+		},
+		{
+			name: "sampled counter with tags",
+			line: "api.requests:2|c|@0.5|#env:prod,canary",
+			want: sample{name: "api.requests", value: "2", mtype: typeCounter, rate: 0.5,
+				labels: model.Labels{"env": "prod", "canary": ""}},
+		},
+		{
+			name: "relative gauge",
+			line: "queue.size:-3|g",
+			want: sample{name: "queue.size", value: "-3", mtype: typeGauge, rate: 1, relative: true},
+		},
+		{
+			name: "timer with timestamp",
+			line: "db.query:12.5|ms|#db:users|T1656581400",
+			want: sample{name: "db.query", value: "12.5", mtype: typeTimer, rate: 1,
+				labels: model.Labels{"db": "users"}},
+		},
+		{
+			name: "set",
+			line: "users.unique:alice|s",
+			want: sample{name: "users.unique", value: "alice", mtype: typeSet, rate: 1},
+		},
+		{name: "missing type", line: "api.requests:1", wantErr: true},
+		{name: "missing name", line: ":1|c", wantErr: true},
+		{name: "unknown type", line: "api.requests:1|x", wantErr: true},
+		{name: "invalid name", line: "weird{name:1|c", wantErr: true},
+		{name: "invalid value", line: "api.requests:one|c", wantErr: true},
+		{name: "invalid rate", line: "api.requests:1|c|@2", wantErr: true},
+		{name: "too low rate", line: "api.requests:1|c|@1e-300", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLine(tt.line)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidLine)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
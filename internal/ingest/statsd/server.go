@@ -0,0 +1,203 @@
+package statsd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+// maxPacketSize is the max size of a UDP datagram.
+const maxPacketSize = 64 << 10
+
+// Server listens for StatsD lines and writes the aggregates of every flush window to the storage.
+// Timers are stored as histograms in seconds, sets as gauges with the number of unique values.
+type Server struct {
+	storage       storage.Storage
+	flushInterval time.Duration
+	aggregator    *aggregator
+	conns         map[net.Conn]struct{}
+	closed        bool
+	mu            sync.Mutex
+}
+
+func NewServer(storage storage.Storage, flushInterval time.Duration) *Server {
+	return &Server{
+		storage:       storage,
+		flushInterval: flushInterval,
+		aggregator:    newAggregator(model.DefaultBuckets),
+		conns:         make(map[net.Conn]struct{}),
+	}
+}
+
+// WithBuckets sets the upper bounds of the histogram buckets timers and histograms are observed into.
+func (s *Server) WithBuckets(buckets []float64) *Server {
+	s.aggregator = newAggregator(buckets)
+	return s
+}
+
+// ListenAndServe listens on both UDP and TCP at the address and serves until the context is done.
+func (s *Server) ListenAndServe(ctx context.Context, address string) error {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return fmt.Errorf("cannot listen statsd udp: %w", err)
+	}
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("cannot listen statsd tcp: %w", err)
+	}
+	logger.Log().Infof("StatsD listener is starting on %s...", address)
+	return s.Serve(ctx, conn, lis)
+}
+
+// Serve reads datagrams from conn and newline delimited lines from connections accepted by lis, either can be nil.
+// When the context is done, the listeners are closed and the last window is flushed.
+func (s *Server) Serve(ctx context.Context, conn net.PacketConn, lis net.Listener) error {
+	var wg sync.WaitGroup
+	if conn != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveUDP(conn)
+		}()
+	}
+	if lis != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveTCP(lis, &wg)
+		}()
+	}
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(ctx); err != nil {
+				logger.Log().Errorf("Cannot flush statsd metrics: %v", err)
+			}
+		case <-ctx.Done():
+			s.close(conn, lis)
+			wg.Wait()
+			// the context is done, but the last window still has to be stored
+			return s.Flush(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+func (s *Server) close(conn net.PacketConn, lis net.Listener) {
+	if conn != nil {
+		_ = conn.Close()
+	}
+	if lis != nil {
+		_ = lis.Close()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+func (s *Server) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Log().Errorf("Cannot read statsd packet: %v", err)
+			}
+			return
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			s.handle(line)
+		}
+	}
+}
+
+func (s *Server) serveTCP(lis net.Listener, wg *sync.WaitGroup) {
+	for {
+		c, err := lis.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Log().Errorf("Cannot accept statsd connection: %v", err)
+			}
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = c.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(c)
+		}()
+	}
+}
+
+func (s *Server) serveConn(c net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		_ = c.Close()
+	}()
+	scanner := bufio.NewScanner(c)
+	scanner.Buffer(make([]byte, 0, 4096), maxPacketSize)
+	for scanner.Scan() {
+		s.handle(scanner.Text())
+	}
+}
+
+func (s *Server) handle(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	sample, err := parseLine(line)
+	if err != nil {
+		logger.Log().Debugf("Skipping statsd line: %v", err)
+		return
+	}
+	s.aggregator.add(sample)
+}
+
+// Flush writes the aggregates of the current window to the storage. Gauges changed by signed values only
+// are applied to their stored values.
+func (s *Server) Flush(ctx context.Context) error {
+	metrics, relative := s.aggregator.flush()
+	for _, metric := range relative {
+		current := model.Metrics{ID: metric.ID, MType: model.Gauge, Labels: metric.Labels}
+		err := s.storage.Read(ctx, &current)
+		if err != nil && !errors.Is(err, storage.ErrMetricNotFound) {
+			return fmt.Errorf("cannot read gauge %s: %w", metric.SeriesKey(), err)
+		}
+		if err == nil {
+			*metric.Value += *current.Value
+		}
+		metrics = append(metrics, metric)
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+	if err := s.storage.UpdateBatch(ctx, metrics); err != nil {
+		return fmt.Errorf("cannot store statsd metrics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage/memory"
+)
+
+// received reports whether the aggregator has the counter sum and the number of unique set values.
+func received(a *aggregator, counterKey string, sum float64, setKey string, unique int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, okCounter := a.counters[counterKey]
+	s, okSet := a.sets[setKey]
+	return okCounter && c.sum == sum && okSet && len(s.values) == unique
+}
+
+func TestServer_Serve(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	stored := 10.0
+	require.NoError(t, s.Update(ctx, model.NewGauge("queue.size", &stored)))
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	// the window is only flushed when the server stops
+	server := NewServer(s, time.Hour)
+	serveCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		done <- server.Serve(serveCtx, conn, lis)
+	}()
+
+	udp, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer udp.Close()
+	for _, packet := range []string{
+		"api.requests:1|c|#env:prod\napi.requests:2|c|@0.5|#env:prod",
+		"queue.size:-3|g\nqueue.size:+1|g",
+		"cpu.temp:40|g\ncpu.temp:42|g",
+		"db.query:20|ms\ndb.query:200|ms|@0.5",
+		"garbage",
+		"users.unique:alice|s\nusers.unique:bob|s\nusers.unique:alice|s",
+	} {
+		_, err = udp.Write([]byte(packet))
+		require.NoError(t, err)
+	}
+	tcp, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	_, err = tcp.Write([]byte("api.requests:3|c|#env:prod\n"))
+	require.NoError(t, err)
+	require.NoError(t, tcp.Close())
+
+	counterKey := model.SeriesKey("api.requests", model.Labels{"env": "prod"})
+	require.Eventually(t, func() bool {
+		return received(server.aggregator, counterKey, 8, "users.unique", 2)
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	counter := model.Metrics{ID: "api.requests", MType: model.Counter, Labels: model.Labels{"env": "prod"}}
+	require.NoError(t, s.Read(ctx, &counter))
+	assert.Equal(t, int64(8), *counter.Delta)
+
+	gauges := map[string]float64{"queue.size": 8, "cpu.temp": 42, "users.unique": 2}
+	for id, want := range gauges {
+		gauge := model.Metrics{ID: id, MType: model.Gauge}
+		require.NoError(t, s.Read(ctx, &gauge))
+		assert.InDelta(t, want, *gauge.Value, 1e-9, id)
+	}
+
+	histogram := model.Metrics{ID: "db.query", MType: model.Histogram}
+	require.NoError(t, s.Read(ctx, &histogram))
+	assert.Equal(t, uint64(3), histogram.Histogram.Count)
+	assert.InDelta(t, 0.42, histogram.Histogram.Sum, 1e-9)
+}
+
+func TestServer_Flush(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage(ctx, nil, nil)
+	server := NewServer(s, time.Hour).WithBuckets([]float64{1, 10})
+
+	require.NoError(t, server.Flush(ctx), "empty window is not stored")
+	gauges, err := s.GetGauges(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, gauges)
+
+	// the heavily sampled value stands for a billion observations, which are added at once
+	for _, line := range []string{"workers:+4|g", "latency:5|h", "latency:50|h", "latency:5|h|@0.000000001"} {
+		server.handle(line)
+	}
+	require.NoError(t, server.Flush(ctx))
+
+	gauge := model.Metrics{ID: "workers", MType: model.Gauge}
+	require.NoError(t, s.Read(ctx, &gauge))
+	assert.InDelta(t, 4.0, *gauge.Value, 1e-9, "relative change of a missing gauge starts from zero")
+
+	histogram := model.Metrics{ID: "latency", MType: model.Histogram}
+	require.NoError(t, s.Read(ctx, &histogram))
+	assert.Equal(t, []model.Bucket{{UpperBound: 1, Count: 0}, {UpperBound: 10, Count: 1e9 + 1}},
+		histogram.Histogram.Buckets)
+	assert.Equal(t, uint64(1e9+2), histogram.Histogram.Count)
+
+	server.handle("workers:-1|g")
+	require.NoError(t, server.Flush(ctx))
+	require.NoError(t, s.Read(ctx, &gauge))
+	assert.InDelta(t, 3.0, *gauge.Value, 1e-9)
+}
@@ -9,9 +9,12 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/itallix/go-metrics/internal/logger"
-	"github.com/itallix/go-metrics/internal/service"
+	"github.com/itallix/go-metrics/internal/security"
 )
 
+// DecryptMiddleware decrypts the request payload with the private key located at privateKeyPath.
+// Both the hybrid AES-GCM + RSA-OAEP envelope and the legacy RSA PKCS #1 v1.5 payload are accepted,
+// so agents can be migrated to the envelope format one by one.
 func DecryptMiddleware(privateKeyPath string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		encryptedData, err := io.ReadAll(c.Request.Body)
@@ -20,16 +23,13 @@ func DecryptMiddleware(privateKeyPath string) gin.HandlerFunc {
 			return
 		}
 
-		if len(encryptedData) > 0 {
-			decryptedData, err := service.DecryptData(encryptedData, privateKeyPath)
-			if err != nil {
-				logger.Log().Errorf("Error decrypting the request payload %v", err)
-				_ = c.AbortWithError(http.StatusInternalServerError, errors.New("failed to decrypt data"))
-				return
-			}
-
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(decryptedData))
+		decryptedData, err := security.Decrypt(encryptedData, privateKeyPath)
+		if err != nil {
+			logger.Log().Errorf("Error decrypting the request payload %v", err)
+			_ = c.AbortWithError(http.StatusInternalServerError, security.ErrDecrypt)
+			return
 		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(decryptedData))
 
 		c.Next()
 	}
@@ -2,9 +2,14 @@ package middleware
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -35,3 +40,32 @@ func TestDecryptMiddleware_OK(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "secret", w.Body.String())
 }
+
+func TestDecryptMiddleware_Legacy(t *testing.T) {
+	publicKeyPEM, err := os.ReadFile("../../test_data/client.pem")
+	require.NoError(t, err)
+	block, _ := pem.Decode(publicKeyPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	require.True(t, ok)
+	message, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, []byte("secret"))
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(DecryptMiddleware("../../test_data/server.pem"))
+
+	r.GET("/test", func(c *gin.Context) {
+		read, _ := io.ReadAll(c.Request.Body)
+		c.String(200, string(read))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", bytes.NewReader(message))
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "secret", w.Body.String())
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/itallix/go-metrics/internal/logger"
 	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/security"
 	"github.com/itallix/go-metrics/internal/service"
 )
 
@@ -22,7 +23,7 @@ func VerifyHash(hashSrv service.HashService) gin.HandlerFunc {
 				return
 			}
 			c.Request.Body = io.NopCloser(bytes.NewReader(b))
-			if !hashSrv.Matches(b, hashSha256) {
+			if err = security.VerifyHash(hashSrv, b, hashSha256); err != nil {
 				c.AbortWithStatus(http.StatusBadRequest)
 				return
 			}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/itallix/go-metrics/internal/service"
+)
+
+// AgentIdentityKey is the key of the agent identity in the gin context.
+const AgentIdentityKey = "agentIdentity"
+
+// ClientIdentity makes the common name of the verified client certificate the agent identity of the request.
+func ClientIdentity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if identity := service.PeerIdentity(c.Request.TLS); identity != "" {
+			c.Set(AgentIdentityKey, identity)
+		}
+		c.Next()
+	}
+}
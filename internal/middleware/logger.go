@@ -24,6 +24,7 @@ func LoggerWithZap(logger *zap.SugaredLogger) gin.HandlerFunc {
 			"latency", latency,
 			"status", statusCode,
 			"responseSize", responseSize,
+			"agent", c.GetString(AgentIdentityKey),
 		)
 	}
 }
@@ -9,6 +9,13 @@ import (
 	"strings"
 )
 
+// Storage engines supported by the server.
+const (
+	EngineMemory   = "memory"   // In-memory storage synchronized with the JSON file.
+	EnginePostgres = "postgres" // PostgreSQL database.
+	EngineBolt     = "bolt"     // Embedded key-value store in a single file.
+)
+
 // ServerConfig describes customization settings for the server.
 type ServerConfig struct {
 	Address       string `env:"ADDRESS" json:"address"`               // Address where server will be started.
@@ -19,6 +26,57 @@ type ServerConfig struct {
 	Key           string `env:"KEY" json:"hash_secret"`               // Secret for a hash function.
 	CryptoKey     string `env:"CRYPTO_KEY" json:"crypto_key"`         // Private key used to decrypt request payload.
 	TrustedSubnet string `env:"TRUSTED_SUBNET" json:"trusted_subnet"` // Whitelisted CIDR subnet addr.
+	GRPCAddress   string `env:"GRPC_ADDRESS" json:"grpc_address"`     // Address where gRPC server will be started.
+	// How long (in seconds) the history of every series is kept.
+	RetentionInterval int `env:"RETENTION_INTERVAL" json:"retention_interval"`
+	// Max number of samples kept per series by the in-memory storage.
+	HistorySize int `env:"HISTORY_SIZE" json:"history_size"`
+	// Should every mutation be logged to the write-ahead log next to the file storage?
+	WAL bool `env:"WAL" json:"wal"`
+	// How many snapshot generations are kept, the older ones are used when the latest cannot be read.
+	StoreGenerations int `env:"STORE_GENERATIONS" json:"store_generations"`
+	// Storage engine: memory, postgres or bolt. When empty, postgres is used if DatabaseDSN is set and memory otherwise.
+	StorageEngine string `env:"STORAGE_ENGINE" json:"storage_engine"`
+	// Location of the database file of the bolt storage engine.
+	BoltPath string `env:"BOLT_PATH" json:"bolt_path"`
+	// Paths to the PEM certificate and key, both HTTP and gRPC listeners serve TLS when they are set.
+	TLSCert string `env:"TLS_CERT" json:"tls_cert"`
+	TLSKey  string `env:"TLS_KEY" json:"tls_key"`
+	// Path to the PEM CA bundle, clients have to present a certificate signed by it when it is set.
+	TLSClientCA string `env:"TLS_CLIENT_CA" json:"tls_client_ca"`
+	// Address where the StatsD listener (both UDP and TCP) will be started, it is disabled when empty.
+	StatsDAddress string `env:"STATSD_ADDRESS" json:"statsd_address"`
+	// How often (in seconds) the aggregated StatsD metrics are written to the storage.
+	StatsDFlushInterval int `env:"STATSD_FLUSH_INTERVAL" json:"statsd_flush_interval"`
+	// Shell patterns of metric names, e.g. "net_bytes_*", whose integer fields written in the InfluxDB line
+	// protocol are cumulative counters, the other integer fields are stored as gauges.
+	InfluxCounterFields []string `env:"INFLUX_COUNTER_FIELDS" envSeparator:"," json:"influx_counter_fields"`
+	// Addresses where the Graphite plaintext and pickle TCP listeners will be started, each is disabled when empty.
+	GraphiteAddress       string `env:"GRAPHITE_ADDRESS" json:"graphite_address"`
+	GraphitePickleAddress string `env:"GRAPHITE_PICKLE_ADDRESS" json:"graphite_pickle_address"`
+	// Templates mapping Graphite paths to metric names and labels, e.g. "servers.* .host.measurement* dc=eu",
+	// paths matched by no template are used as names.
+	GraphiteTemplates []string `env:"GRAPHITE_TEMPLATES" envSeparator:";" json:"graphite_templates"`
+	// Max number of Graphite datapoints per second accepted from every connection, zero means no limit.
+	GraphiteRateLimit int `env:"GRAPHITE_RATE_LIMIT" json:"graphite_rate_limit"`
+	// Max size in bytes of the request bodies accepted by the ingestion endpoints.
+	MaxBodySize int64 `env:"MAX_BODY_SIZE" json:"max_body_size"`
+}
+
+// Engine gives the storage engine taking into account the default choice by DatabaseDSN.
+func (c *ServerConfig) Engine() string {
+	if c.StorageEngine != "" {
+		return c.StorageEngine
+	}
+	if c.DatabaseDSN != "" {
+		return EnginePostgres
+	}
+	return EngineMemory
+}
+
+// TLSEnabled reports whether the listeners serve TLS.
+func (c *ServerConfig) TLSEnabled() bool {
+	return c.TLSCert != ""
 }
 
 // AgentConfig describes customization settings for the agent.
@@ -30,6 +88,38 @@ type AgentConfig struct {
 	RateLimit      int    `env:"RATE_LIMIT" json:"rate_limit"`           // Limits number of concurrent requests to the server.
 	CryptoKey      string `env:"CRYPTO_KEY" json:"crypto_key"`           // Public key used to encrypt request payload.
 	Schema         string `env:"SCHEMA" json:"schema"`                   // Protocol (http or grpc) to communicate with the server.
+	// gRPC target of the server, e.g. host:port or dns:///host:port to balance between every resolved address.
+	GRPCTarget string `env:"GRPC_TARGET" json:"grpc_target"`
+	// Upper bounds (in seconds) of the ReportLatency histogram buckets.
+	LatencyBuckets []float64 `env:"LATENCY_BUCKETS" envSeparator:"," json:"latency_buckets"`
+	// Path to the PEM CA bundle used to verify the server certificate.
+	TLSCA string `env:"TLS_CA" json:"tls_ca"`
+	// Paths to the PEM client certificate and key presented to the server for mutual TLS.
+	TLSCert string `env:"TLS_CERT" json:"tls_cert"`
+	TLSKey  string `env:"TLS_KEY" json:"tls_key"`
+	// Enabled collectors, each optionally followed by its interval in seconds, e.g. "runtime", "system:5".
+	// Collectors without an interval run every PollInterval, every built-in collector is enabled when empty.
+	Collectors []string `env:"COLLECTORS" envSeparator:"," json:"collectors"`
+	// Shell patterns of the mount points reported by the disk collector and of the excluded ones.
+	MountInclude []string `env:"MOUNT_INCLUDE" envSeparator:"," json:"mount_include"`
+	MountExclude []string `env:"MOUNT_EXCLUDE" envSeparator:"," json:"mount_exclude"`
+	// Shell patterns of the network interfaces reported by the network collector and of the excluded ones.
+	InterfaceInclude []string `env:"INTERFACE_INCLUDE" envSeparator:"," json:"interface_include"`
+	InterfaceExclude []string `env:"INTERFACE_EXCLUDE" envSeparator:"," json:"interface_exclude"`
+	// Number of processes reported by the process collector by CPU and by resident memory.
+	TopProcesses int `env:"TOP_PROCESSES" json:"top_processes"`
+	// Reports every supported runtime/metrics metric along with the MemStats compatible gauges.
+	RuntimeMetricsAll bool `env:"RUNTIME_METRICS_ALL" json:"runtime_metrics_all"`
+	// Directory of the on-disk queue that keeps batches until they are delivered, the queue is disabled when empty.
+	QueueDir string `env:"QUEUE_DIR" json:"queue_dir"`
+	// Limits of the on-disk queue: total size in bytes and age in seconds, the oldest batches are dropped beyond them.
+	QueueMaxSize int64 `env:"QUEUE_MAX_SIZE" json:"queue_max_size"`
+	QueueMaxAge  int   `env:"QUEUE_MAX_AGE" json:"queue_max_age"`
+}
+
+// TLSEnabled reports whether the agent connects to the server over TLS.
+func (c *AgentConfig) TLSEnabled() bool {
+	return c.TLSCA != "" || c.TLSCert != ""
 }
 
 var re = regexp.MustCompile(`("\w*_interval"):\s*"(\d+)s`)
@@ -0,0 +1,166 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+// DefaultBuckets are the upper bounds used for histograms when no buckets are configured.
+// They are tailored to measure latencies in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	ErrInvalidHistogram     = errors.New("histogram is invalid")
+	ErrInvalidSummary       = errors.New("summary is invalid")
+	ErrBucketLayoutMismatch = errors.New("histogram buckets do not match the stored series")
+)
+
+// Bucket is a cumulative histogram bucket: the number of observations less than or equal to UpperBound.
+type Bucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+// HistogramValue describes the observations of a histogram metric.
+// Buckets contain finite upper bounds only, the implicit +Inf bucket is equal to Count.
+//
+// Histograms are reported as deltas, i.e. observations made since the previous report.
+// The storage merges histograms of the same series (including the ones received from several agents)
+// by adding up bucket counts, sum and count, which requires identical bucket bounds.
+type HistogramValue struct {
+	Buckets []Bucket `json:"buckets"`
+	Sum     float64  `json:"sum"`
+	Count   uint64   `json:"count"`
+}
+
+// Quantile is a single φ-quantile of a summary, e.g. 0.99 → 12.5.
+type Quantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// SummaryValue describes a summary metric calculated by the reporter.
+// Quantiles cannot be aggregated, so the storage keeps the last reported summary of every series.
+type SummaryValue struct {
+	Quantiles []Quantile `json:"quantiles"`
+	Sum       float64    `json:"sum"`
+	Count     uint64     `json:"count"`
+}
+
+// NewHistogramValue constructs empty histogram with the given bucket upper bounds.
+// Bounds are sorted and +Inf is dropped since it is always implied.
+func NewHistogramValue(bounds []float64) *HistogramValue {
+	sorted := slices.Clone(bounds)
+	sort.Float64s(sorted)
+	sorted = slices.Compact(sorted)
+
+	buckets := make([]Bucket, 0, len(sorted))
+	for _, bound := range sorted {
+		if math.IsInf(bound, 1) {
+			continue
+		}
+		buckets = append(buckets, Bucket{UpperBound: bound})
+	}
+	return &HistogramValue{Buckets: buckets}
+}
+
+// Observe adds a single observation to the histogram.
+func (h *HistogramValue) Observe(v float64) {
+	h.ObserveN(v, 1)
+}
+
+// ObserveN adds n observations of the same value to the histogram, e.g. a value sampled at 1/n rate.
+func (h *HistogramValue) ObserveN(v float64, n uint64) {
+	for i := range h.Buckets {
+		if v <= h.Buckets[i].UpperBound {
+			h.Buckets[i].Count += n
+		}
+	}
+	h.Sum += v * float64(n)
+	h.Count += n
+}
+
+// Reset drops all observations keeping the bucket layout.
+func (h *HistogramValue) Reset() {
+	for i := range h.Buckets {
+		h.Buckets[i].Count = 0
+	}
+	h.Sum = 0
+	h.Count = 0
+}
+
+// Clone gives a deep copy of the histogram.
+func (h *HistogramValue) Clone() *HistogramValue {
+	return &HistogramValue{
+		Buckets: slices.Clone(h.Buckets),
+		Sum:     h.Sum,
+		Count:   h.Count,
+	}
+}
+
+// Bounds gives the upper bounds of histogram buckets.
+func (h *HistogramValue) Bounds() []float64 {
+	bounds := make([]float64, len(h.Buckets))
+	for i, b := range h.Buckets {
+		bounds[i] = b.UpperBound
+	}
+	return bounds
+}
+
+// Validate checks that bounds are strictly increasing and bucket counts are cumulative.
+func (h *HistogramValue) Validate() error {
+	for i, b := range h.Buckets {
+		if math.IsNaN(b.UpperBound) || math.IsInf(b.UpperBound, 0) {
+			return fmt.Errorf("%w: bucket bound must be finite", ErrInvalidHistogram)
+		}
+		if b.Count > h.Count {
+			return fmt.Errorf("%w: bucket count exceeds total count", ErrInvalidHistogram)
+		}
+		if i == 0 {
+			continue
+		}
+		if b.UpperBound <= h.Buckets[i-1].UpperBound {
+			return fmt.Errorf("%w: bucket bounds must be increasing", ErrInvalidHistogram)
+		}
+		if b.Count < h.Buckets[i-1].Count {
+			return fmt.Errorf("%w: bucket counts must be cumulative", ErrInvalidHistogram)
+		}
+	}
+	return nil
+}
+
+// Merge adds observations of the other histogram. Both histograms must have identical bucket bounds,
+// otherwise ErrBucketLayoutMismatch is returned and the histogram is left untouched.
+func (h *HistogramValue) Merge(other *HistogramValue) error {
+	if !slices.Equal(h.Bounds(), other.Bounds()) {
+		return ErrBucketLayoutMismatch
+	}
+	for i := range h.Buckets {
+		h.Buckets[i].Count += other.Buckets[i].Count
+	}
+	h.Sum += other.Sum
+	h.Count += other.Count
+	return nil
+}
+
+// Validate checks that every quantile is within [0, 1].
+func (s *SummaryValue) Validate() error {
+	for _, q := range s.Quantiles {
+		if math.IsNaN(q.Quantile) || q.Quantile < 0 || q.Quantile > 1 {
+			return fmt.Errorf("%w: quantile must be within [0, 1]", ErrInvalidSummary)
+		}
+	}
+	return nil
+}
+
+// Clone gives a deep copy of the summary.
+func (s *SummaryValue) Clone() *SummaryValue {
+	return &SummaryValue{
+		Quantiles: slices.Clone(s.Quantiles),
+		Sum:       s.Sum,
+		Count:     s.Count,
+	}
+}
@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// Sample is a value of the series at the given point in time.
+// Counters are sampled with the accumulated value after each update.
+type Sample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// MaxRangePoints is the max number of points of a range query, either downsampled or raw samples.
+const MaxRangePoints = 11000
+
+// RangeQuery describes request for the history of a single series.
+// When Step is set, the history is downsampled to one sample per step (see Downsample).
+type RangeQuery struct {
+	ID     string
+	MType  MetricType
+	Labels Labels
+	From   time.Time
+	To     time.Time
+	Step   time.Duration
+}
+
+// SeriesKey gives a unique key of the requested series (see SeriesKey function).
+func (q RangeQuery) SeriesKey() string {
+	return SeriesKey(q.ID, q.Labels)
+}
+
+// Downsample reduces time ordered samples to a grid of points from, from+step, ..., to.
+// Every point takes the latest sample within (point-step, point] and is skipped when there is none.
+// With zero step samples are returned as is. The grid must have at most MaxRangePoints points.
+func Downsample(samples []Sample, from, to time.Time, step time.Duration) []Sample {
+	if step <= 0 {
+		return samples
+	}
+	result := make([]Sample, 0, len(samples))
+	i := 0
+	for point := from; !point.After(to); point = point.Add(step) {
+		var (
+			last  Sample
+			found bool
+		)
+		for ; i < len(samples) && !samples[i].Timestamp.After(point); i++ {
+			if samples[i].Timestamp.After(point.Add(-step)) {
+				last, found = samples[i], true
+			}
+		}
+		if found {
+			result = append(result, Sample{Timestamp: point, Value: last.Value})
+		}
+	}
+	return result
+}
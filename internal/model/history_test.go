@@ -0,0 +1,20 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownsample(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(seconds int, value float64) Sample {
+		return Sample{Timestamp: from.Add(time.Duration(seconds) * time.Second), Value: value}
+	}
+	samples := []Sample{at(1, 1), at(4, 2), at(9, 3), at(31, 4)}
+
+	assert.Equal(t, samples, Downsample(samples, from, from.Add(time.Minute), 0))
+	assert.Equal(t, []Sample{at(10, 3), at(40, 4)}, Downsample(samples, from, from.Add(time.Minute), 10*time.Second))
+	assert.Empty(t, Downsample(nil, from, from.Add(time.Minute), 10*time.Second))
+}
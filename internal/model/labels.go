@@ -0,0 +1,124 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Labels is a set of name/value pairs which, together with the metric ID, identifies a series.
+type Labels map[string]string
+
+var (
+	ErrInvalidSeries      = errors.New("series is invalid")
+	errMalformedSeriesKey = errors.New("malformed series key")
+)
+
+// String gives a canonical representation of the label set with names sorted alphabetically.
+// Example: `{core="3",host="web-1"}`. An empty label set is rendered as an empty string.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(labelValueEscaper.Replace(l[name]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// Equal reports whether both label sets contain the same pairs. Nil and empty sets are equal.
+func (l Labels) Equal(other Labels) bool {
+	if len(l) != len(other) {
+		return false
+	}
+	for name, value := range l {
+		if v, ok := other[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	labelValueEscaper   = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	labelValueUnescaper = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n")
+)
+
+// SeriesKey builds a unique key for the series identified by id and labels.
+// Series without labels are keyed by id only, so they stay compatible with unlabeled metrics.
+func SeriesKey(id string, labels Labels) string {
+	return id + labels.String()
+}
+
+// ValidateSeries checks that the series key of id and labels can be parsed back: the id must not contain
+// braces and double quotes, label names must not be empty and must not contain braces, double quotes,
+// commas and equal signs. Otherwise `cpu{core="1"}` without labels would be the same series as cpu with core=1.
+func ValidateSeries(id string, labels Labels) error {
+	if strings.ContainsAny(id, `{}"`) {
+		return fmt.Errorf("%w: metric id %q must not contain braces and double quotes", ErrInvalidSeries, id)
+	}
+	for name := range labels {
+		if name == "" || strings.ContainsAny(name, `{}",=`) {
+			return fmt.Errorf("%w: invalid label name %q", ErrInvalidSeries, name)
+		}
+	}
+	return nil
+}
+
+// ParseSeriesKey splits the key produced by SeriesKey back into metric id and labels.
+func ParseSeriesKey(key string) (string, Labels, error) {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key, nil, nil
+	}
+	if !strings.HasSuffix(key, "}") {
+		return "", nil, errMalformedSeriesKey
+	}
+	id, rest := key[:start], key[start+1:len(key)-1]
+	labels := make(Labels)
+	for rest != "" {
+		eq := strings.Index(rest, `="`)
+		if eq <= 0 {
+			return "", nil, errMalformedSeriesKey
+		}
+		name := rest[:eq]
+		rest = rest[eq+2:]
+
+		end := closingQuote(rest)
+		if end < 0 {
+			return "", nil, errMalformedSeriesKey
+		}
+		labels[name] = labelValueUnescaper.Replace(rest[:end])
+		rest = strings.TrimPrefix(rest[end+1:], ",")
+	}
+	return id, labels, nil
+}
+
+// closingQuote returns the index of the first unescaped double quote in s or -1.
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
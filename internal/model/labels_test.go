@@ -0,0 +1,67 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabels_String(t *testing.T) {
+	assert.Empty(t, Labels(nil).String())
+	assert.Equal(t, `{core="3",host="web-1"}`, Labels{"host": "web-1", "core": "3"}.String())
+	assert.Equal(t, `{path="C:\\tmp \"x\""}`, Labels{"path": `C:\tmp "x"`}.String())
+}
+
+func TestSeriesKey_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		labels Labels
+	}{
+		{name: "NoLabels", id: "cpu"},
+		{name: "Labels", id: "cpu", labels: Labels{"host": "web-1", "core": "3"}},
+		{name: "Escaped", id: "disk", labels: Labels{"path": `C:\tmp "x",y`, "a": "{}"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, labels, err := ParseSeriesKey(SeriesKey(tt.id, tt.labels))
+			require.NoError(t, err)
+			assert.Equal(t, tt.id, id)
+			assert.True(t, tt.labels.Equal(labels))
+		})
+	}
+}
+
+func TestParseSeriesKey_Malformed(t *testing.T) {
+	for _, key := range []string{`cpu{host="a"`, `cpu{host}`, `cpu{host="a}`} {
+		_, _, err := ParseSeriesKey(key)
+		assert.Error(t, err, key)
+	}
+}
+
+func TestValidateSeries(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		labels  Labels
+		wantErr bool
+	}{
+		{name: "Valid", id: "servers.web-1/cpu_load", labels: Labels{"service.name": `C:\tmp "x",{y}`}},
+		{name: "OpenBrace", id: "weird{name", wantErr: true},
+		{name: "LabelsInID", id: `cpu{core="1"}`, wantErr: true},
+		{name: "EmptyLabelName", id: "cpu", labels: Labels{"": "1"}, wantErr: true},
+		{name: "LabelNameWithQuote", id: "cpu", labels: Labels{`core="1`: "1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSeries(tt.id, tt.labels)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidSeries)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
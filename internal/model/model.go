@@ -4,18 +4,23 @@ import "fmt"
 
 // Metrics describes JSON payload for metrics of different types.
 type Metrics struct {
-	ID    string     `json:"id"`
-	MType MetricType `json:"type"`
-	Delta *int64     `json:"delta,omitempty"`
-	Value *float64   `json:"value,omitempty"`
+	ID        string          `json:"id"`
+	MType     MetricType      `json:"type"`
+	Delta     *int64          `json:"delta,omitempty"`
+	Value     *float64        `json:"value,omitempty"`
+	Histogram *HistogramValue `json:"histogram,omitempty"`
+	Summary   *SummaryValue   `json:"summary,omitempty"`
+	Labels    Labels          `json:"labels,omitempty"`
 }
 
 // MetricType is a string-based type reserved for metric types.
 type MetricType string
 
 const (
-	Counter MetricType = "counter" // defines counter metric type with integer values
-	Gauge   MetricType = "gauge"   // defines gauge metric type with float values
+	Counter   MetricType = "counter"   // defines counter metric type with integer values
+	Gauge     MetricType = "gauge"     // defines gauge metric type with float values
+	Histogram MetricType = "histogram" // defines histogram metric type with bucketed observations
+	Summary   MetricType = "summary"   // defines summary metric type with precalculated quantiles
 )
 
 // NewCounter constructs new metric instance of type Counter with specified id and value.
@@ -36,14 +41,42 @@ func NewGauge(id string, value *float64) *Metrics {
 	}
 }
 
+// NewHistogram constructs new metric instance of type Histogram with specified id and value.
+func NewHistogram(id string, value *HistogramValue) *Metrics {
+	return &Metrics{
+		ID:        id,
+		MType:     Histogram,
+		Histogram: value,
+	}
+}
+
+// NewSummary constructs new metric instance of type Summary with specified id and value.
+func NewSummary(id string, value *SummaryValue) *Metrics {
+	return &Metrics{
+		ID:      id,
+		MType:   Summary,
+		Summary: value,
+	}
+}
+
+// SeriesKey gives a unique key of the series this metric belongs to (see SeriesKey function).
+func (m Metrics) SeriesKey() string {
+	return SeriesKey(m.ID, m.Labels)
+}
+
 // String gives a string representation of the metric instance.
-// Example: "gauge: g01 = 2.345" or "counter: c01 = 64".
+// Example: "gauge: g01 = 2.345", "counter: c01 = 64", `gauge: cpu{core="1"} = 0.5`
+// or "histogram: latency = count 3, sum 0.250000".
 func (m Metrics) String() string {
 	switch m.MType {
 	case Gauge:
-		return fmt.Sprintf("%s: %s = %f", Gauge, m.ID, *m.Value)
+		return fmt.Sprintf("%s: %s = %f", Gauge, m.SeriesKey(), *m.Value)
 	case Counter:
-		return fmt.Sprintf("%s: %s = %d", Counter, m.ID, *m.Delta)
+		return fmt.Sprintf("%s: %s = %d", Counter, m.SeriesKey(), *m.Delta)
+	case Histogram:
+		return fmt.Sprintf("%s: %s = count %d, sum %f", Histogram, m.SeriesKey(), m.Histogram.Count, m.Histogram.Sum)
+	case Summary:
+		return fmt.Sprintf("%s: %s = count %d, sum %f", Summary, m.SeriesKey(), m.Summary.Count, m.Summary.Sum)
 	}
 	return ""
 }
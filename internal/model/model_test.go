@@ -1,9 +1,11 @@
 package model
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestModel_NewCounter(t *testing.T) {
@@ -35,3 +37,50 @@ func TestModel_String(t *testing.T) {
 	assert.Equal(t, "counter: c0 = 64", mc.String())
 	assert.Equal(t, "gauge: g0 = 64.000000", mg.String())
 }
+
+func TestModel_NewHistogram(t *testing.T) {
+	h := NewHistogramValue([]float64{1, 0.5, math.Inf(1), 0.5})
+	h.Observe(0.2)
+	h.Observe(0.7)
+	h.Observe(3)
+	m := NewHistogram("latency", h)
+
+	assert.Equal(t, Histogram, m.MType)
+	assert.Equal(t, []Bucket{{UpperBound: 0.5, Count: 1}, {UpperBound: 1, Count: 2}}, m.Histogram.Buckets)
+	assert.Equal(t, uint64(3), m.Histogram.Count)
+	assert.InEpsilon(t, 3.9, m.Histogram.Sum, 0.00001)
+	assert.Equal(t, "histogram: latency = count 3, sum 3.900000", m.String())
+	require.NoError(t, h.Validate())
+
+	h.ObserveN(0.7, 1e9)
+	assert.Equal(t, []Bucket{{UpperBound: 0.5, Count: 1}, {UpperBound: 1, Count: 1e9 + 2}}, h.Buckets)
+	assert.Equal(t, uint64(1e9+3), h.Count)
+	assert.InEpsilon(t, 7e8+3.9, h.Sum, 0.00001)
+}
+
+func TestHistogramValue_Merge(t *testing.T) {
+	h1 := NewHistogramValue([]float64{1, 2})
+	h1.Observe(0.5)
+	h2 := NewHistogramValue([]float64{1, 2})
+	h2.Observe(1.5)
+	h2.Observe(5)
+
+	require.NoError(t, h1.Merge(h2))
+	assert.Equal(t, []Bucket{{UpperBound: 1, Count: 1}, {UpperBound: 2, Count: 2}}, h1.Buckets)
+	assert.Equal(t, uint64(3), h1.Count)
+
+	h3 := NewHistogramValue([]float64{1, 5})
+	require.ErrorIs(t, h1.Merge(h3), ErrBucketLayoutMismatch)
+	assert.Equal(t, uint64(3), h1.Count)
+}
+
+func TestHistogramValue_Validate(t *testing.T) {
+	h := &HistogramValue{Buckets: []Bucket{{UpperBound: 2, Count: 1}, {UpperBound: 1, Count: 1}}, Count: 1}
+	require.ErrorIs(t, h.Validate(), ErrInvalidHistogram)
+
+	h = &HistogramValue{Buckets: []Bucket{{UpperBound: 1, Count: 2}, {UpperBound: 2, Count: 1}}, Count: 2}
+	require.ErrorIs(t, h.Validate(), ErrInvalidHistogram)
+
+	s := &SummaryValue{Quantiles: []Quantile{{Quantile: 1.5, Value: 1}}}
+	require.ErrorIs(t, s.Validate(), ErrInvalidSummary)
+}
@@ -0,0 +1,221 @@
+// Package queue implements a bounded on-disk FIFO queue of metric batches,
+// which keeps batches that the agent could not deliver across server outages and restarts.
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+const (
+	segmentExt    = ".batch"
+	segmentDigits = 20
+)
+
+// Queue stores every batch in its own segment file named after the batch sequence number.
+// Segments are written to a temporary file and atomically renamed, so a crash never leaves a partial batch behind.
+// The oldest segments are dropped once the total size exceeds maxSize or they get older than maxAge.
+type Queue struct {
+	dir      string
+	maxSize  int64
+	maxAge   time.Duration
+	segments []segment
+	size     int64
+	seq      uint64
+	now      func() time.Time
+	mu       sync.Mutex
+	// replayMu serializes replays, so batches are delivered in the order they were queued.
+	replayMu sync.Mutex
+}
+
+type segment struct {
+	seq     uint64
+	size    int64
+	created time.Time
+}
+
+// New opens the queue in dir, creating the directory if needed. Segments left by the previous run are kept.
+// Zero maxSize or maxAge disables the corresponding limit.
+func New(dir string, maxSize int64, maxAge time.Duration) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create queue directory: %w", err)
+	}
+	q := &Queue{dir: dir, maxSize: maxSize, maxAge: maxAge, now: time.Now}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *Queue) load() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("cannot read queue directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.Contains(name, ".tmp") {
+			// leftover of an interrupted write
+			_ = os.Remove(filepath.Join(q.dir, name))
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("cannot read queue segment: %w", err)
+		}
+		q.segments = append(q.segments, segment{seq: seq, size: info.Size(), created: info.ModTime()})
+		q.size += info.Size()
+		q.seq = max(q.seq, seq)
+	}
+	sort.Slice(q.segments, func(i, j int) bool {
+		return q.segments[i].seq < q.segments[j].seq
+	})
+	return nil
+}
+
+// Push durably appends the batch to the queue and drops the oldest batches that exceed the limits.
+func (q *Queue) Push(metrics []model.Metrics) (err error) {
+	payload, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("cannot encode batch: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	seq := q.seq + 1
+	tmp, err := os.CreateTemp(q.dir, segmentName(seq)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("cannot create queue segment: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(payload); err != nil {
+		return fmt.Errorf("cannot write queue segment: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("cannot sync queue segment: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("cannot close queue segment: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), q.path(seq)); err != nil {
+		return fmt.Errorf("cannot commit queue segment: %w", err)
+	}
+
+	q.seq = seq
+	q.segments = append(q.segments, segment{seq: seq, size: int64(len(payload)), created: q.now()})
+	q.size += int64(len(payload))
+	q.evict()
+	return nil
+}
+
+// Replay passes queued batches to send from the oldest one and removes every delivered batch, so send must return
+// nil only once the server has acknowledged the batch.
+// It stops at the first error, the failed batch stays at the head of the queue and is retried by the next replay.
+func (q *Queue) Replay(send func(metrics []model.Metrics) error) error {
+	q.replayMu.Lock()
+	defer q.replayMu.Unlock()
+
+	for {
+		metrics, seq, ok, err := q.head()
+		if err != nil || !ok {
+			return err
+		}
+		if err = send(metrics); err != nil {
+			return err
+		}
+		q.remove(seq)
+	}
+}
+
+// Len returns the number of queued batches.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.segments)
+}
+
+// head returns the oldest batch that is not expired. Segments that cannot be decoded are dropped.
+func (q *Queue) head() ([]model.Metrics, uint64, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.evict()
+	for len(q.segments) > 0 {
+		head := q.segments[0]
+		payload, err := os.ReadFile(q.path(head.seq))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, 0, false, fmt.Errorf("cannot read queue segment: %w", err)
+		}
+		var metrics []model.Metrics
+		if err == nil {
+			if err = json.Unmarshal(payload, &metrics); err == nil {
+				return metrics, head.seq, true, nil
+			}
+		}
+		logger.Log().Warnf("Dropping unreadable queue segment %d: %v", head.seq, err)
+		q.drop()
+	}
+	return nil, 0, false, nil
+}
+
+func (q *Queue) remove(seq uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// the segment could have been evicted while it was being sent
+	if len(q.segments) > 0 && q.segments[0].seq == seq {
+		q.drop()
+	}
+}
+
+// evict drops the oldest segments while they are expired or the queue exceeds its size limit.
+func (q *Queue) evict() {
+	for len(q.segments) > 0 {
+		head := q.segments[0]
+		expired := q.maxAge > 0 && q.now().Sub(head.created) > q.maxAge
+		oversized := q.maxSize > 0 && q.size > q.maxSize && len(q.segments) > 1
+		if !expired && !oversized {
+			return
+		}
+		logger.Log().Warnf("Dropping queued batch %d: expired %t, queue size %d bytes", head.seq, expired, q.size)
+		q.drop()
+	}
+}
+
+// drop removes the oldest segment.
+func (q *Queue) drop() {
+	head := q.segments[0]
+	if err := os.Remove(q.path(head.seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Log().Errorf("Cannot remove queue segment %d: %v", head.seq, err)
+	}
+	q.segments = q.segments[1:]
+	q.size -= head.size
+}
+
+func (q *Queue) path(seq uint64) string {
+	return filepath.Join(q.dir, segmentName(seq))
+}
+
+func segmentName(seq uint64) string {
+	return fmt.Sprintf("%0*d%s", segmentDigits, seq, segmentExt)
+}
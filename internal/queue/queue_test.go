@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+func batch(id string) []model.Metrics {
+	value := 1.0
+	return []model.Metrics{*model.NewGauge(id, &value)}
+}
+
+func replayed(t *testing.T, q *Queue) []string {
+	var ids []string
+	require.NoError(t, q.Replay(func(metrics []model.Metrics) error {
+		ids = append(ids, metrics[0].ID)
+		return nil
+	}))
+	return ids
+}
+
+func TestQueue_Replay(t *testing.T) {
+	dir := t.TempDir()
+	q, err := New(dir, 0, 0)
+	require.NoError(t, err)
+	for _, id := range []string{"g0", "g1", "g2"} {
+		require.NoError(t, q.Push(batch(id)))
+	}
+
+	// a failed batch stays at the head of the queue
+	failure := errors.New("server is down")
+	var sent []string
+	err = q.Replay(func(metrics []model.Metrics) error {
+		if metrics[0].ID == "g1" {
+			return failure
+		}
+		sent = append(sent, metrics[0].ID)
+		return nil
+	})
+	require.ErrorIs(t, err, failure)
+	assert.Equal(t, []string{"g0"}, sent)
+	assert.Equal(t, 2, q.Len())
+
+	// batches survive the restart and keep their order
+	require.NoError(t, q.Push(batch("g3")))
+	q, err = New(dir, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"g1", "g2", "g3"}, replayed(t, q))
+	assert.Equal(t, 0, q.Len())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestQueue_Limits(t *testing.T) {
+	q, err := New(t.TempDir(), 0, time.Minute)
+	require.NoError(t, err)
+	now := time.Now()
+	q.now = func() time.Time { return now }
+	require.NoError(t, q.Push(batch("g0")))
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, q.Push(batch("g1")))
+	assert.Equal(t, []string{"g1"}, replayed(t, q))
+
+	q, err = New(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, q.Push(batch("g0")))
+	q.maxSize = q.size * 2
+	require.NoError(t, q.Push(batch("g1")))
+	require.NoError(t, q.Push(batch("g2")))
+	assert.Equal(t, []string{"g1", "g2"}, replayed(t, q))
+}
+
+func TestQueue_Load(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentName(1)+".tmp123"), []byte("[{"), 0666))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentName(2)), []byte("garbage"), 0666))
+	q, err := New(dir, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, q.Push(batch("g0")))
+
+	assert.Equal(t, []string{"g0"}, replayed(t, q))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
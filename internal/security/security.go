@@ -0,0 +1,57 @@
+// Package security holds the transport-agnostic request checks shared by the gin middlewares
+// and the gRPC interceptors: trusted subnet, HMAC verification and payload decryption.
+package security
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/itallix/go-metrics/internal/service"
+)
+
+var (
+	ErrForbidden    = errors.New("client address is not trusted")
+	ErrHashMismatch = errors.New("hash doesn't match")
+	ErrDecrypt      = errors.New("failed to decrypt data")
+)
+
+// CheckAddr returns ErrForbidden unless addr is an IP address within the trusted subnet in CIDR notation.
+func CheckAddr(trustedSubnet string, addr string) error {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return fmt.Errorf("%w: invalid address %q", ErrForbidden, addr)
+	}
+	_, ipNet, err := net.ParseCIDR(trustedSubnet)
+	if err != nil {
+		return fmt.Errorf("%w: invalid trusted subnet: %w", ErrForbidden, err)
+	}
+	if !ipNet.Contains(ip) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// VerifyHash returns ErrHashMismatch unless hash is the HMAC of the payload. Payloads without a hash
+// are accepted, the same as when no secret is configured.
+func VerifyHash(hashService service.HashService, payload []byte, hash string) error {
+	if hash == "" || hashService == nil {
+		return nil
+	}
+	if !hashService.Matches(payload, hash) {
+		return ErrHashMismatch
+	}
+	return nil
+}
+
+// Decrypt decrypts the payload with the private key located at privateKeyPath, empty payloads are kept as is.
+func Decrypt(payload []byte, privateKeyPath string) ([]byte, error) {
+	if len(payload) == 0 {
+		return payload, nil
+	}
+	decrypted, err := service.DecryptData(payload, privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+	return decrypted, nil
+}
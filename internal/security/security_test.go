@@ -0,0 +1,59 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/service"
+)
+
+func TestCheckAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		subnet  string
+		addr    string
+		wantErr bool
+	}{
+		{name: "trusted", subnet: "192.168.1.0/24", addr: "192.168.1.5"},
+		{name: "untrusted", subnet: "192.168.1.0/24", addr: "192.168.2.5", wantErr: true},
+		{name: "invalid address", subnet: "192.168.1.0/24", addr: "host", wantErr: true},
+		{name: "invalid subnet", subnet: "192.168.1.0", addr: "192.168.1.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckAddr(tt.subnet, tt.addr)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrForbidden)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestVerifyHash(t *testing.T) {
+	hashService := service.NewHashService("secret")
+	payload := []byte("payload")
+
+	require.NoError(t, VerifyHash(hashService, payload, hashService.Sha256sum(payload)))
+	require.NoError(t, VerifyHash(hashService, payload, ""))
+	require.NoError(t, VerifyHash(nil, payload, "hash"))
+	require.ErrorIs(t, VerifyHash(hashService, []byte("other"), hashService.Sha256sum(payload)), ErrHashMismatch)
+}
+
+func TestDecrypt(t *testing.T) {
+	encrypted, err := service.EncryptData([]byte("payload"), "../../test_data/client.pem")
+	require.NoError(t, err)
+	decrypted, err := Decrypt(encrypted, "../../test_data/server.pem")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("payload"), decrypted)
+
+	empty, err := Decrypt(nil, "../../test_data/server.pem")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	_, err = Decrypt([]byte("garbage"), "../../test_data/server.pem")
+	require.ErrorIs(t, err, ErrDecrypt)
+}
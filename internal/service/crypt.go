@@ -1,17 +1,142 @@
 package service
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/binary"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 )
 
+// Envelope layout produced by EncryptData:
+//
+//	magic (3 bytes) | version (1 byte) | wrapped key length (2 bytes, big endian) |
+//	RSA-OAEP wrapped AES key | GCM nonce | AES-256-GCM ciphertext with tag
+//
+// Payloads without the magic prefix are treated as the legacy format, where the whole body
+// has been encrypted with RSA PKCS #1 v1.5.
+const (
+	EnvelopeVersion = 1
+
+	envelopeMagic    = "GME"
+	dataKeySize      = 32
+	envelopeLenSize  = 2
+	envelopeHdrSize  = len(envelopeMagic) + 1
+	envelopeMinBytes = envelopeHdrSize + envelopeLenSize
+)
+
 // EncryptData takes slice of bytes and path to public key and returns slice of bytes with encrypted data.
+// The data is encrypted with a random AES-256-GCM key, which is wrapped with RSA-OAEP and stored
+// in the envelope header, so the payload size is not limited by the RSA key size.
 func EncryptData(data []byte, publicKeyPath string) ([]byte, error) {
+	rsaPublicKey, err := readPublicKey(publicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err = io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("error generating data key: %w", err)
+	}
+
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaPublicKey, dataKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting data key: %w", err)
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	header := envelopeHeader()
+	envelope := make([]byte, 0, envelopeMinBytes+len(wrappedKey)+len(nonce)+len(data)+gcm.Overhead())
+	envelope = append(envelope, header...)
+	envelope = binary.BigEndian.AppendUint16(envelope, uint16(len(wrappedKey)))
+	envelope = append(envelope, wrappedKey...)
+	envelope = append(envelope, nonce...)
+	envelope = gcm.Seal(envelope, nonce, data, header)
+
+	return envelope, nil
+}
+
+// DecryptData takes encrypted message and path to private key and returns decrypted slice of bytes.
+// Both the envelope format and the legacy RSA PKCS #1 v1.5 format are supported.
+func DecryptData(data []byte, privateKeyPath string) ([]byte, error) {
+	privateKey, err := readPrivateKey(privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsEnvelope(data) {
+		return rsa.DecryptPKCS1v15(rand.Reader, privateKey, data)
+	}
+	return decryptEnvelope(data, privateKey)
+}
+
+// IsEnvelope reports whether data starts with the envelope header of the supported version.
+func IsEnvelope(data []byte) bool {
+	return len(data) >= envelopeMinBytes && bytes.Equal(data[:envelopeHdrSize], envelopeHeader())
+}
+
+func decryptEnvelope(data []byte, privateKey *rsa.PrivateKey) ([]byte, error) {
+	header := data[:envelopeHdrSize]
+	keyLen := int(binary.BigEndian.Uint16(data[envelopeHdrSize:envelopeMinBytes]))
+	body := data[envelopeMinBytes:]
+	if len(body) < keyLen {
+		return nil, errors.New("envelope is truncated")
+	}
+
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, body[:keyLen], nil)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %w", err)
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	body = body[keyLen:]
+	if len(body) < gcm.NonceSize() {
+		return nil, errors.New("envelope is truncated")
+	}
+
+	decrypted, err := gcm.Open(nil, body[:gcm.NonceSize()], body[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data: %w", err)
+	}
+	return decrypted, nil
+}
+
+func envelopeHeader() []byte {
+	return append([]byte(envelopeMagic), EnvelopeVersion)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+func readPublicKey(publicKeyPath string) (*rsa.PublicKey, error) {
 	publicKeyPEM, err := os.ReadFile(publicKeyPath)
 	if err != nil {
 		return nil, fmt.Errorf("error reading public key file: %w", err)
@@ -31,17 +156,10 @@ func EncryptData(data []byte, publicKeyPath string) ([]byte, error) {
 	if !ok {
 		return nil, errors.New("not an RSA public key")
 	}
-
-	encryptedData, err := rsa.EncryptPKCS1v15(rand.Reader, rsaPublicKey, data)
-	if err != nil {
-		return nil, fmt.Errorf("error encrypting data: %w", err)
-	}
-
-	return encryptedData, nil
+	return rsaPublicKey, nil
 }
 
-// DecryptData takes encrypted message and path to private key and returns decrypted slice of bytes.
-func DecryptData(data []byte, privateKeyPath string) ([]byte, error) {
+func readPrivateKey(privateKeyPath string) (*rsa.PrivateKey, error) {
 	privateKeyPEM, err := os.ReadFile(privateKeyPath)
 	if err != nil {
 		return nil, fmt.Errorf("error reading private key file: %w", err)
@@ -52,15 +170,5 @@ func DecryptData(data []byte, privateKeyPath string) ([]byte, error) {
 		return nil, errors.New("failed to parse PEM block containing the private key")
 	}
 
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return nil, err
-	}
-
-	decrypted, err := rsa.DecryptPKCS1v15(rand.Reader, privateKey, data)
-	if err != nil {
-		return nil, err
-	}
-
-	return decrypted, nil
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
 }
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -55,3 +58,35 @@ func TestDecryptData(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, []byte("secret message"), decrypted)
 }
+
+func TestDecryptData_LargePayload(t *testing.T) {
+	msg := bytes.Repeat([]byte("metrics"), 4096)
+
+	encrypted, err := EncryptData(msg, "../../test_data/client.pem")
+	require.NoError(t, err)
+	assert.True(t, IsEnvelope(encrypted))
+
+	decrypted, err := DecryptData(encrypted, "../../test_data/server.pem")
+	require.NoError(t, err)
+	assert.Equal(t, msg, decrypted)
+}
+
+func TestDecryptData_Legacy(t *testing.T) {
+	publicKey, err := readPublicKey("../../test_data/client.pem")
+	require.NoError(t, err)
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, []byte("secret message"))
+	require.NoError(t, err)
+
+	decrypted, err := DecryptData(encrypted, "../../test_data/server.pem")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret message"), decrypted)
+}
+
+func TestDecryptData_Tampered(t *testing.T) {
+	encrypted, err := EncryptData([]byte("secret message"), "../../test_data/client.pem")
+	require.NoError(t, err)
+	encrypted[len(encrypted)-1] ^= 0xff
+
+	_, err = DecryptData(encrypted, "../../test_data/server.pem")
+	require.ErrorContains(t, err, "error decrypting data")
+}
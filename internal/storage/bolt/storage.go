@@ -0,0 +1,367 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+const openTimeout = 3 * time.Second
+
+// Every metric type has its own bucket keyed by series key. Counters and gauges are stored as 8 byte
+// big endian numbers, histograms and summaries as JSON. Samples of every series are kept in a nested bucket
+// of the samples bucket, keyed by the timestamp in unix nanoseconds.
+var (
+	countersBucket   = []byte("counters")
+	gaugesBucket     = []byte("gauges")
+	histogramsBucket = []byte("histograms")
+	summariesBucket  = []byte("summaries")
+	samplesBucket    = []byte("samples")
+)
+
+// BoltStorage keeps metrics in a single file with an embedded key-value store, so they survive restarts
+// without any external service. Every Update and UpdateBatch is a single durable transaction.
+type BoltStorage struct {
+	db        *bbolt.DB
+	retention time.Duration
+	now       func() time.Time
+	hub       *storage.Hub
+}
+
+// NewBoltStorage opens (or creates) the database file. Samples older than a positive retention are dropped.
+func NewBoltStorage(path string, retention time.Duration) (*BoltStorage, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %w", err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{countersBucket, gaugesBucket, histogramsBucket, summariesBucket, samplesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+	logger.Log().Infof("Bolt storage has been opened at %s", path)
+
+	return &BoltStorage{
+		db:        db,
+		retention: retention,
+		now:       time.Now,
+	}, nil
+}
+
+// WithHub publishes every stored metric to the hub.
+func (s *BoltStorage) WithHub(hub *storage.Hub) *BoltStorage {
+	s.hub = hub
+	return s
+}
+
+func (s *BoltStorage) Update(_ context.Context, metric *model.Metrics) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		return s.apply(tx, metric)
+	})
+	if err != nil {
+		return err
+	}
+	s.hub.Publish(*metric)
+	return nil
+}
+
+// UpdateBatch applies all metrics in one transaction: either every metric is stored or none of them.
+func (s *BoltStorage) UpdateBatch(_ context.Context, metrics []model.Metrics) error {
+	stored := make([]model.Metrics, 0, len(metrics))
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		for _, metric := range metrics {
+			if err := s.apply(tx, &metric); err != nil {
+				return err
+			}
+			stored = append(stored, metric)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.hub.Publish(stored...)
+	return nil
+}
+
+// apply writes the metric within the transaction and updates the metric with the stored value.
+// Counters and histograms are accumulated, gauges and summaries are overwritten.
+func (s *BoltStorage) apply(tx *bbolt.Tx, metric *model.Metrics) error {
+	if err := model.ValidateSeries(metric.ID, metric.Labels); err != nil {
+		return err
+	}
+	key := []byte(metric.SeriesKey())
+	switch metric.MType {
+	case model.Counter:
+		if metric.Delta == nil {
+			return storage.ErrMetricNotSupported
+		}
+		bucket := tx.Bucket(countersBucket)
+		val := *metric.Delta
+		if current := bucket.Get(key); current != nil {
+			val += int64(binary.BigEndian.Uint64(current))
+		}
+		if err := bucket.Put(key, binary.BigEndian.AppendUint64(nil, uint64(val))); err != nil {
+			return err
+		}
+		metric.Delta = &val
+		return s.appendSample(tx, metric, float64(val))
+
+	case model.Gauge:
+		if metric.Value == nil {
+			return storage.ErrMetricNotSupported
+		}
+		val := *metric.Value
+		if err := tx.Bucket(gaugesBucket).Put(key, binary.BigEndian.AppendUint64(nil, math.Float64bits(val))); err != nil {
+			return err
+		}
+		metric.Value = &val
+		return s.appendSample(tx, metric, val)
+
+	case model.Histogram:
+		if metric.Histogram == nil {
+			return storage.ErrMetricNotSupported
+		}
+		if err := metric.Histogram.Validate(); err != nil {
+			return err
+		}
+		bucket := tx.Bucket(histogramsBucket)
+		merged := metric.Histogram.Clone()
+		if current := bucket.Get(key); current != nil {
+			var stored model.HistogramValue
+			if err := json.Unmarshal(current, &stored); err != nil {
+				return err
+			}
+			if err := stored.Merge(metric.Histogram); err != nil {
+				return err
+			}
+			merged = &stored
+		}
+		if err := putJSON(bucket, key, merged); err != nil {
+			return err
+		}
+		metric.Histogram = merged
+
+	case model.Summary:
+		if metric.Summary == nil {
+			return storage.ErrMetricNotSupported
+		}
+		if err := metric.Summary.Validate(); err != nil {
+			return err
+		}
+		summary := metric.Summary.Clone()
+		if err := putJSON(tx.Bucket(summariesBucket), key, summary); err != nil {
+			return err
+		}
+		metric.Summary = summary
+
+	default:
+		return storage.ErrMetricNotFound
+	}
+	return nil
+}
+
+func (s *BoltStorage) Read(_ context.Context, metric *model.Metrics) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		key := []byte(metric.SeriesKey())
+		switch metric.MType {
+		case model.Counter:
+			v := tx.Bucket(countersBucket).Get(key)
+			if v == nil {
+				return storage.ErrMetricNotFound
+			}
+			val := int64(binary.BigEndian.Uint64(v))
+			metric.Delta = &val
+		case model.Gauge:
+			v := tx.Bucket(gaugesBucket).Get(key)
+			if v == nil {
+				return storage.ErrMetricNotFound
+			}
+			val := math.Float64frombits(binary.BigEndian.Uint64(v))
+			metric.Value = &val
+		case model.Histogram:
+			v := tx.Bucket(histogramsBucket).Get(key)
+			if v == nil {
+				return storage.ErrMetricNotFound
+			}
+			metric.Histogram = &model.HistogramValue{}
+			return json.Unmarshal(v, metric.Histogram)
+		case model.Summary:
+			v := tx.Bucket(summariesBucket).Get(key)
+			if v == nil {
+				return storage.ErrMetricNotFound
+			}
+			metric.Summary = &model.SummaryValue{}
+			return json.Unmarshal(v, metric.Summary)
+		default:
+			return storage.ErrMetricNotFound
+		}
+		return nil
+	})
+}
+
+func (s *BoltStorage) GetCounters(_ context.Context) ([]model.Metrics, error) {
+	return s.list(countersBucket, func(id string, v []byte) (*model.Metrics, error) {
+		val := int64(binary.BigEndian.Uint64(v))
+		return model.NewCounter(id, &val), nil
+	})
+}
+
+func (s *BoltStorage) GetGauges(_ context.Context) ([]model.Metrics, error) {
+	return s.list(gaugesBucket, func(id string, v []byte) (*model.Metrics, error) {
+		val := math.Float64frombits(binary.BigEndian.Uint64(v))
+		return model.NewGauge(id, &val), nil
+	})
+}
+
+func (s *BoltStorage) GetHistograms(_ context.Context) ([]model.Metrics, error) {
+	return s.list(histogramsBucket, func(id string, v []byte) (*model.Metrics, error) {
+		var histogram model.HistogramValue
+		if err := json.Unmarshal(v, &histogram); err != nil {
+			return nil, err
+		}
+		return model.NewHistogram(id, &histogram), nil
+	})
+}
+
+func (s *BoltStorage) GetSummaries(_ context.Context) ([]model.Metrics, error) {
+	return s.list(summariesBucket, func(id string, v []byte) (*model.Metrics, error) {
+		var summary model.SummaryValue
+		if err := json.Unmarshal(v, &summary); err != nil {
+			return nil, err
+		}
+		return model.NewSummary(id, &summary), nil
+	})
+}
+
+// list decodes every series of the bucket. Keys are iterated in byte order, so series are ordered by series key.
+// Malformed keys, stored before series were validated, are skipped.
+func (s *BoltStorage) list(name []byte, decode func(id string, v []byte) (*model.Metrics, error)) (
+	[]model.Metrics, error) {
+	var series []model.Metrics
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(name).ForEach(func(k, v []byte) error {
+			id, labels, err := model.ParseSeriesKey(string(k))
+			if err != nil {
+				logger.Log().Warnf("Skipping series %q: %v", k, err)
+				return nil
+			}
+			metric, err := decode(id, v)
+			if err != nil {
+				return err
+			}
+			metric.Labels = labels
+			series = append(series, *metric)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// appendSample records the value of a counter or gauge and drops its samples older than the retention period.
+func (s *BoltStorage) appendSample(tx *bbolt.Tx, metric *model.Metrics, value float64) error {
+	bucket, err := tx.Bucket(samplesBucket).CreateBucketIfNotExists(historyKey(metric.MType, metric.SeriesKey()))
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	if s.retention > 0 {
+		cutoff := timeKey(now.Add(-s.retention))
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.First() {
+			if err = c.Delete(); err != nil {
+				return err
+			}
+		}
+	}
+	// bump the timestamp when several samples are recorded within the same nanosecond
+	key := timeKey(now)
+	if last, _ := bucket.Cursor().Last(); last != nil && bytes.Compare(key, last) <= 0 {
+		key = binary.BigEndian.AppendUint64(nil, binary.BigEndian.Uint64(last)+1)
+	}
+	return bucket.Put(key, binary.BigEndian.AppendUint64(nil, math.Float64bits(value)))
+}
+
+// ReadRange returns samples of a counter or gauge series recorded within the query range
+// and the retention period.
+func (s *BoltStorage) ReadRange(_ context.Context, query *model.RangeQuery) ([]model.Sample, error) {
+	switch query.MType {
+	case model.Counter, model.Gauge:
+	default:
+		return nil, storage.ErrMetricNotSupported
+	}
+
+	from := query.From
+	if oldest := s.now().Add(-s.retention); s.retention > 0 && from.Before(oldest) {
+		from = oldest
+	}
+	var samples []model.Sample
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(samplesBucket).Bucket(historyKey(query.MType, query.SeriesKey()))
+		if bucket == nil {
+			return nil
+		}
+		to := timeKey(query.To)
+		c := bucket.Cursor()
+		for k, v := c.Seek(timeKey(from)); k != nil && bytes.Compare(k, to) <= 0; k, v = c.Next() {
+			if query.Step == 0 && len(samples) == model.MaxRangePoints {
+				return storage.ErrTooManySamples
+			}
+			samples = append(samples, model.Sample{
+				Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(k))),
+				Value:     math.Float64frombits(binary.BigEndian.Uint64(v)),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return model.Downsample(samples, query.From, query.To, query.Step), nil
+}
+
+func (s *BoltStorage) Ping(_ context.Context) bool {
+	return s.db.View(func(*bbolt.Tx) error { return nil }) == nil
+}
+
+func (s *BoltStorage) Close() {
+	if err := s.db.Close(); err != nil {
+		logger.Log().Errorf("Error closing bolt storage: %v", err)
+	}
+}
+
+func putJSON(bucket *bbolt.Bucket, key []byte, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+func historyKey(mtype model.MetricType, seriesKey string) []byte {
+	return []byte(string(mtype) + ":" + seriesKey)
+}
+
+func timeKey(t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano()))
+}
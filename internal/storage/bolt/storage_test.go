@@ -0,0 +1,123 @@
+package bolt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+func TestStorage_UpdateAndRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.db")
+	s, err := NewBoltStorage(path, time.Hour)
+	require.NoError(t, err)
+
+	c, g0, g1 := int64(64), 1.5, 2.5
+	require.NoError(t, s.Update(ctx, model.NewCounter("c0", &c)))
+	counter := model.NewCounter("c0", &c)
+	counter.Labels = model.Labels{"host": "web-1"}
+	require.NoError(t, s.UpdateBatch(ctx, []model.Metrics{*model.NewCounter("c0", &c), *counter,
+		*model.NewGauge("g0", &g0), *model.NewGauge("g0", &g1)}))
+	assert.True(t, s.Ping(ctx))
+	s.Close()
+	assert.False(t, s.Ping(ctx))
+
+	s, err = NewBoltStorage(path, time.Hour)
+	require.NoError(t, err)
+	defer s.Close()
+
+	cc, err := s.GetCounters(ctx)
+	require.NoError(t, err)
+	require.Len(t, cc, 2)
+	assert.Equal(t, "c0", cc[0].ID)
+	assert.Empty(t, cc[0].Labels)
+	assert.Equal(t, int64(128), *cc[0].Delta)
+	assert.Equal(t, model.Labels{"host": "web-1"}, cc[1].Labels)
+	assert.Equal(t, int64(64), *cc[1].Delta)
+
+	gauge := model.Metrics{ID: "g0", MType: model.Gauge}
+	require.NoError(t, s.Read(ctx, &gauge))
+	assert.InDelta(t, 2.5, *gauge.Value, 0.0001)
+
+	missing := model.Metrics{ID: "g1", MType: model.Gauge}
+	require.ErrorIs(t, s.Read(ctx, &missing), storage.ErrMetricNotFound)
+}
+
+func TestStorage_UpdateBatchAtomic(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewBoltStorage(filepath.Join(t.TempDir(), "metrics.db"), 0)
+	require.NoError(t, err)
+	defer s.Close()
+
+	c := int64(1)
+	err = s.UpdateBatch(ctx, []model.Metrics{*model.NewCounter("c0", &c), {ID: "g0", MType: model.Gauge}})
+	require.ErrorIs(t, err, storage.ErrMetricNotSupported)
+
+	counter := model.Metrics{ID: "c0", MType: model.Counter}
+	require.ErrorIs(t, s.Read(ctx, &counter), storage.ErrMetricNotFound)
+}
+
+func TestStorage_Histogram(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewBoltStorage(filepath.Join(t.TempDir(), "metrics.db"), 0)
+	require.NoError(t, err)
+	defer s.Close()
+
+	histogram := model.NewHistogramValue([]float64{0.1, 1})
+	histogram.Observe(0.5)
+	require.NoError(t, s.Update(ctx, model.NewHistogram("latency", histogram)))
+	require.NoError(t, s.Update(ctx, model.NewHistogram("latency", histogram)))
+
+	hh, err := s.GetHistograms(ctx)
+	require.NoError(t, err)
+	require.Len(t, hh, 1)
+	assert.Equal(t, uint64(2), hh[0].Histogram.Count)
+	assert.Equal(t, []model.Bucket{{UpperBound: 0.1}, {UpperBound: 1, Count: 2}}, hh[0].Histogram.Buckets)
+
+	mismatch := model.NewHistogramValue([]float64{5})
+	require.ErrorIs(t, s.Update(ctx, model.NewHistogram("latency", mismatch)), model.ErrBucketLayoutMismatch)
+
+	summary := &model.SummaryValue{Quantiles: []model.Quantile{{Quantile: 0.5, Value: 1}}, Sum: 2, Count: 2}
+	require.NoError(t, s.Update(ctx, model.NewSummary("rpc", summary)))
+	read := model.Metrics{ID: "rpc", MType: model.Summary}
+	require.NoError(t, s.Read(ctx, &read))
+	assert.Equal(t, summary, read.Summary)
+}
+
+func TestStorage_ReadRange(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewBoltStorage(filepath.Join(t.TempDir(), "metrics.db"), time.Minute)
+	require.NoError(t, err)
+	defer s.Close()
+
+	start := time.Now()
+	now := start.Add(-2 * time.Minute)
+	s.now = func() time.Time { return now }
+	for _, v := range []float64{1, 2, 3} {
+		require.NoError(t, s.Update(ctx, model.NewGauge("g0", &v)))
+		now = now.Add(time.Minute)
+	}
+	now = start
+
+	// the first sample is out of retention and has been dropped
+	samples, err := s.ReadRange(ctx, &model.RangeQuery{
+		ID:    "g0",
+		MType: model.Gauge,
+		From:  start.Add(-time.Hour),
+		To:    start,
+	})
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	assert.InDelta(t, 2.0, samples[0].Value, 0.0001)
+	assert.InDelta(t, 3.0, samples[1].Value, 0.0001)
+
+	_, err = s.ReadRange(ctx, &model.RangeQuery{ID: "h0", MType: model.Histogram})
+	require.ErrorIs(t, err, storage.ErrMetricNotSupported)
+}
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+const (
+	// Histograms are merged by adding up bucket counts element-wise, which is only allowed when bounds match.
+	// On mismatch the WHERE clause skips the update and no row is returned. array_agg of histograms without
+	// finite bounds is NULL, hence COALESCE.
+	queryHistogram = `INSERT INTO histograms(id, labels, bounds, buckets, sum, count) VALUES($1, $2, $3, $4, $5, $6)
+		ON CONFLICT(id, labels)
+		DO UPDATE SET
+			buckets = COALESCE((SELECT array_agg(a + b ORDER BY i)
+				FROM unnest(histograms.buckets, EXCLUDED.buckets) WITH ORDINALITY AS t(a, b, i)), '{}'),
+			sum = histograms.sum + EXCLUDED.sum,
+			count = histograms.count + EXCLUDED.count
+		WHERE histograms.bounds = EXCLUDED.bounds`
+	querySummary = `INSERT INTO summaries(id, labels, quantiles, qvalues, sum, count) VALUES($1, $2, $3, $4, $5, $6)
+		ON CONFLICT(id, labels)
+		DO UPDATE SET quantiles = EXCLUDED.quantiles, qvalues = EXCLUDED.qvalues,
+			sum = EXCLUDED.sum, count = EXCLUDED.count`
+)
+
+func (m *PgStorage) updateHistogram(ctx context.Context, metric *model.Metrics) error {
+	if metric.Histogram == nil {
+		return storage.ErrMetricNotSupported
+	}
+	if err := metric.Histogram.Validate(); err != nil {
+		return err
+	}
+	bounds, buckets, sum, count := histogramArgs(metric.Histogram)
+	row := m.pool.QueryRow(ctx, queryHistogram+" RETURNING bounds, buckets, sum, count",
+		metric.ID, labelsOf(metric), bounds, buckets, sum, count)
+	histogram, err := scanHistogram(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.ErrBucketLayoutMismatch
+	}
+	if err != nil {
+		return err
+	}
+	metric.Histogram = histogram
+	return nil
+}
+
+func (m *PgStorage) updateSummary(ctx context.Context, metric *model.Metrics) error {
+	if metric.Summary == nil {
+		return storage.ErrMetricNotSupported
+	}
+	if err := metric.Summary.Validate(); err != nil {
+		return err
+	}
+	quantiles, values, sum, count := summaryArgs(metric.Summary)
+	_, err := m.pool.Exec(ctx, querySummary, metric.ID, labelsOf(metric), quantiles, values, sum, count)
+	return err
+}
+
+func (m *PgStorage) readHistogram(ctx context.Context, metric *model.Metrics) error {
+	row := m.pool.QueryRow(ctx, "SELECT bounds, buckets, sum, count FROM histograms WHERE id = $1 AND labels = $2",
+		metric.ID, labelsOf(metric))
+	histogram, err := scanHistogram(row)
+	if err != nil {
+		return err
+	}
+	metric.Histogram = histogram
+	return nil
+}
+
+func (m *PgStorage) readSummary(ctx context.Context, metric *model.Metrics) error {
+	row := m.pool.QueryRow(ctx, "SELECT quantiles, qvalues, sum, count FROM summaries WHERE id = $1 AND labels = $2",
+		metric.ID, labelsOf(metric))
+	summary, err := scanSummary(row)
+	if err != nil {
+		return err
+	}
+	metric.Summary = summary
+	return nil
+}
+
+func (m *PgStorage) GetHistograms(ctx context.Context) ([]model.Metrics, error) {
+	c, cancel := context.WithTimeout(ctx, TimeoutInSeconds*time.Second)
+	defer cancel()
+
+	rows, err := m.pool.Query(c, "SELECT id, labels, bounds, buckets, sum, count FROM histograms ORDER BY id, labels")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var histograms []model.Metrics
+	for rows.Next() {
+		var (
+			id     string
+			labels model.Labels
+		)
+		histogram, err := scanHistogram(rows, &id, &labels)
+		if err != nil {
+			return nil, err
+		}
+		metric := model.NewHistogram(id, histogram)
+		metric.Labels = nonEmpty(labels)
+		histograms = append(histograms, *metric)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return histograms, nil
+}
+
+func (m *PgStorage) GetSummaries(ctx context.Context) ([]model.Metrics, error) {
+	c, cancel := context.WithTimeout(ctx, TimeoutInSeconds*time.Second)
+	defer cancel()
+
+	rows, err := m.pool.Query(c, "SELECT id, labels, quantiles, qvalues, sum, count FROM summaries ORDER BY id, labels")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []model.Metrics
+	for rows.Next() {
+		var (
+			id     string
+			labels model.Labels
+		)
+		summary, err := scanSummary(rows, &id, &labels)
+		if err != nil {
+			return nil, err
+		}
+		metric := model.NewSummary(id, summary)
+		metric.Labels = nonEmpty(labels)
+		summaries = append(summaries, *metric)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+func histogramArgs(h *model.HistogramValue) ([]float64, []int64, float64, int64) {
+	buckets := make([]int64, len(h.Buckets))
+	for i, b := range h.Buckets {
+		buckets[i] = int64(b.Count)
+	}
+	return h.Bounds(), buckets, h.Sum, int64(h.Count)
+}
+
+func summaryArgs(s *model.SummaryValue) ([]float64, []float64, float64, int64) {
+	quantiles := make([]float64, len(s.Quantiles))
+	values := make([]float64, len(s.Quantiles))
+	for i, q := range s.Quantiles {
+		quantiles[i] = q.Quantile
+		values[i] = q.Value
+	}
+	return quantiles, values, s.Sum, int64(s.Count)
+}
+
+// scanHistogram reads bounds, buckets, sum and count columns preceded by the optional dest columns.
+func scanHistogram(row pgx.Row, dest ...any) (*model.HistogramValue, error) {
+	var (
+		bounds  []float64
+		buckets []int64
+		sum     float64
+		count   int64
+	)
+	if err := row.Scan(append(dest, &bounds, &buckets, &sum, &count)...); err != nil {
+		return nil, err
+	}
+	histogram := &model.HistogramValue{Buckets: make([]model.Bucket, len(bounds)), Sum: sum, Count: uint64(count)}
+	for i := range bounds {
+		histogram.Buckets[i] = model.Bucket{UpperBound: bounds[i], Count: uint64(buckets[i])}
+	}
+	return histogram, nil
+}
+
+// scanSummary reads quantiles, values, sum and count columns preceded by the optional dest columns.
+func scanSummary(row pgx.Row, dest ...any) (*model.SummaryValue, error) {
+	var (
+		quantiles []float64
+		values    []float64
+		sum       float64
+		count     int64
+	)
+	if err := row.Scan(append(dest, &quantiles, &values, &sum, &count)...); err != nil {
+		return nil, err
+	}
+	summary := &model.SummaryValue{Quantiles: make([]model.Quantile, len(quantiles)), Sum: sum, Count: uint64(count)}
+	for i := range quantiles {
+		summary.Quantiles[i] = model.Quantile{Quantile: quantiles[i], Value: values[i]}
+	}
+	return summary, nil
+}
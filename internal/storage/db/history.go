@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
+)
+
+const (
+	createPartitionQuery = `CREATE TABLE IF NOT EXISTS %s PARTITION OF samples FOR VALUES FROM ('%s') TO ('%s')`
+	listPartitionsQuery  = `SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = 'samples'`
+
+	// Upserts of counters and gauges record the resulting value as a sample in the same statement.
+	queryCounterWithSample = `WITH upd AS (
+			INSERT INTO counters(id, labels, delta) VALUES($1, $2, $3)
+			ON CONFLICT(id, labels)
+			DO UPDATE SET delta = counters.delta + EXCLUDED.delta RETURNING id, labels, delta
+		), ins AS (
+			INSERT INTO samples(id, labels, mtype, ts, val) SELECT id, labels, 'counter', now(), delta FROM upd
+		)
+		SELECT delta FROM upd`
+	queryGaugeWithSample = `WITH upd AS (
+			INSERT INTO gauges(id, labels, val) VALUES($1, $2, $3)
+			ON CONFLICT(id, labels)
+			DO UPDATE SET val = EXCLUDED.val RETURNING id, labels, val
+		), ins AS (
+			INSERT INTO samples(id, labels, mtype, ts, val) SELECT id, labels, 'gauge', now(), val FROM upd
+		)
+		SELECT val FROM upd`
+	querySamples = `SELECT ts, val FROM samples
+		WHERE mtype = $1 AND id = $2 AND labels = $3 AND ts >= $4 AND ts <= $5 ORDER BY ts LIMIT $6`
+
+	partitionPrefix       = "samples_"
+	partitionLayout       = "20060102"
+	partitionDay          = 24 * time.Hour
+	partitionsAhead       = 2
+	partitionMaintenance  = time.Hour
+	partitionTimeout      = 10 * time.Second
+	partitionBoundsLayout = "2006-01-02 15:04:05Z07"
+)
+
+// maintainPartitions periodically creates upcoming partitions and drops the expired ones until ctx is done.
+func (m *PgStorage) maintainPartitions(ctx context.Context) {
+	ticker := time.NewTicker(partitionMaintenance)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.rotatePartitions(ctx); err != nil {
+				logger.Log().Errorf("Failed to rotate samples partitions: %v", err)
+			}
+		}
+	}
+}
+
+// rotatePartitions makes sure partitions for today and the following days exist and drops partitions
+// whose whole range is older than a positive retention period.
+func (m *PgStorage) rotatePartitions(ctx context.Context) error {
+	c, cancel := context.WithTimeout(ctx, partitionTimeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	today := now.Truncate(partitionDay)
+	for i := 0; i < partitionsAhead; i++ {
+		from := today.Add(time.Duration(i) * partitionDay)
+		to := from.Add(partitionDay)
+		query := fmt.Sprintf(createPartitionQuery, partitionPrefix+from.Format(partitionLayout),
+			from.Format(partitionBoundsLayout), to.Format(partitionBoundsLayout))
+		if _, err := m.pool.Exec(c, query); err != nil {
+			return fmt.Errorf("failed to create partition: %w", err)
+		}
+	}
+	if m.retention <= 0 {
+		return nil
+	}
+
+	rows, err := m.pool.Query(c, listPartitionsQuery)
+	if err != nil {
+		return err
+	}
+	partitions, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	for _, partition := range partitions {
+		day, err := time.Parse(partitionLayout, strings.TrimPrefix(partition, partitionPrefix))
+		if err != nil {
+			continue
+		}
+		if day.Add(partitionDay).After(now.Add(-m.retention)) {
+			continue
+		}
+		if _, err = m.pool.Exec(c, "DROP TABLE IF EXISTS "+pgx.Identifier{partition}.Sanitize()); err != nil {
+			return fmt.Errorf("failed to drop partition %s: %w", partition, err)
+		}
+		logger.Log().Infof("Expired samples partition %s has been dropped", partition)
+	}
+	return nil
+}
+
+// ReadRange returns samples of a counter or gauge series recorded within the query range
+// and the retention period.
+func (m *PgStorage) ReadRange(ctx context.Context, query *model.RangeQuery) ([]model.Sample, error) {
+	switch query.MType {
+	case model.Counter, model.Gauge:
+	default:
+		return nil, storage.ErrMetricNotSupported
+	}
+
+	c, cancel := context.WithTimeout(ctx, TimeoutInSeconds*time.Second)
+	defer cancel()
+
+	from := query.From
+	if oldest := time.Now().Add(-m.retention); m.retention > 0 && from.Before(oldest) {
+		from = oldest
+	}
+	labels := query.Labels
+	if labels == nil {
+		labels = model.Labels{}
+	}
+	// raw samples are limited, one more sample is read to tell the limit is exceeded, NULL means no limit
+	var limit *int
+	if query.Step == 0 {
+		limit = new(int)
+		*limit = model.MaxRangePoints + 1
+	}
+	rows, err := m.pool.Query(c, querySamples, string(query.MType), query.ID, labels, from, query.To, limit)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Sample, error) {
+		var sample model.Sample
+		err := row.Scan(&sample.Timestamp, &sample.Value)
+		return sample, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) > model.MaxRangePoints {
+		return nil, storage.ErrTooManySamples
+	}
+	return model.Downsample(samples, query.From, query.To, query.Step), nil
+}
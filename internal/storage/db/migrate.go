@@ -0,0 +1,272 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/itallix/go-metrics/internal/logger"
+)
+
+// MigrationTimeout limits the time of a single migrate run, including waiting for the lock.
+const MigrationTimeout = 60 * time.Second
+
+// migrationLockID is the key of the advisory lock, which prevents several replicas from migrating concurrently.
+const migrationLockID = 0x6d6574726963
+
+const createMigrationsTableQuery = `CREATE TABLE IF NOT EXISTS schema_migrations(
+	version bigint PRIMARY KEY, name text NOT NULL, applied_at timestamptz NOT NULL DEFAULT now())`
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrSchemaTooNew = errors.New("database schema is newer than the known migrations")
+
+// Migration is a versioned schema change, loaded from the migrations/<version>_<name>.<up|down>.sql files.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes whether the migration has been applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time // nil when the migration is pending
+	Unknown   bool       // applied to the database but not known to this build
+}
+
+// Migrator applies embedded migrations in version order. Every migration runs in its own transaction
+// together with its schema_migrations record, and the whole run holds an advisory lock.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+func NewMigrator(pool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read migrations: %w", err)
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		matches := migrationFileRe.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", entry.Name(), err)
+		}
+		query, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, migration.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			migration.Up = string(query)
+		} else {
+			migration.Down = string(query)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration and returns how many have been applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn, versions map[int64]time.Time) error {
+		if len(m.migrations) > 0 {
+			latest := m.migrations[len(m.migrations)-1].Version
+			for version := range versions {
+				if version > latest {
+					return fmt.Errorf("%w: version %d is applied", ErrSchemaTooNew, version)
+				}
+			}
+		}
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			err := m.run(ctx, conn, migration, migration.Up,
+				"INSERT INTO schema_migrations(version, name) VALUES($1, $2)", migration.Version, migration.Name)
+			if err != nil {
+				return err
+			}
+			logger.Log().Infof("Migration %d_%s has been applied", migration.Version, migration.Name)
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts up to steps latest applied migrations and returns how many have been reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn, versions map[int64]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			err := m.run(ctx, conn, migration, migration.Down,
+				"DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			if err != nil {
+				return err
+			}
+			logger.Log().Infof("Migration %d_%s has been reverted", migration.Version, migration.Name)
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists known migrations along with the ones applied by a newer build, ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(_ *pgxpool.Conn, versions map[int64]time.Time) error {
+		known := make(map[int64]bool, len(m.migrations))
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := versions[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+			known[migration.Version] = true
+		}
+		for version, appliedAt := range versions {
+			if !known[version] {
+				statuses = append(statuses, MigrationStatus{Version: version, AppliedAt: &appliedAt, Unknown: true})
+			}
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, err
+}
+
+// run executes the migration query and records the change in schema_migrations within one transaction.
+func (m *Migrator) run(ctx context.Context, conn *pgxpool.Conn, migration Migration, query string,
+	record string, args ...any) (err error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if _, err = tx.Exec(ctx, query); err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	if _, err = tx.Exec(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// withLock runs fn on a dedicated connection holding the advisory lock, passing the applied versions.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn, versions map[int64]time.Time) error) (
+	err error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("cannot take migration lock: %w", err)
+	}
+	defer func() {
+		// the lock is released with the session anyway, so the connection is dropped if unlock fails
+		_, unlockErr := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+		if unlockErr != nil {
+			_ = conn.Conn().Close(context.Background())
+		}
+	}()
+
+	if _, err = conn.Exec(ctx, createMigrationsTableQuery); err != nil {
+		return err
+	}
+	rows, err := conn.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+	versions := make(map[int64]time.Time)
+	var (
+		version   int64
+		appliedAt time.Time
+	)
+	_, err = pgx.ForEachRow(rows, []any{&version, &appliedAt}, func() error {
+		versions[version] = appliedAt
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return fn(conn, versions)
+}
+
+// Migrate brings the schema up to date, it is run on every start of the storage.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	c, cancel := context.WithTimeout(ctx, MigrationTimeout)
+	defer cancel()
+
+	migrator, err := NewMigrator(pool)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(c)
+	if err != nil {
+		return err
+	}
+	logger.Log().Infof("Database schema is up to date, %d migrations applied", applied)
+	return nil
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, migration := range migrations {
+		assert.Equal(t, int64(i+1), migration.Version, "versions must be sequential")
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name         string
+		giveFiles    fstest.MapFS
+		wantVersions []int64
+		wantErr      bool
+	}{
+		{
+			name: "Ordered",
+			giveFiles: fstest.MapFS{
+				"m/0010_b.up.sql":   {Data: []byte("B")},
+				"m/0010_b.down.sql": {Data: []byte("-B")},
+				"m/0002_a.up.sql":   {Data: []byte("A")},
+				"m/0002_a.down.sql": {Data: []byte("-A")},
+			},
+			wantVersions: []int64{2, 10},
+		},
+		{
+			name: "MissingDown",
+			giveFiles: fstest.MapFS{
+				"m/0001_a.up.sql": {Data: []byte("A")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "NameMismatch",
+			giveFiles: fstest.MapFS{
+				"m/0001_a.up.sql":   {Data: []byte("A")},
+				"m/0001_b.down.sql": {Data: []byte("-B")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "UnexpectedFile",
+			giveFiles: fstest.MapFS{
+				"m/readme.md": {Data: []byte("text")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.giveFiles, "m")
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			versions := make([]int64, 0, len(migrations))
+			for _, migration := range migrations {
+				versions = append(versions, migration.Version)
+			}
+			assert.Equal(t, tt.wantVersions, versions)
+		})
+	}
+}
@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS gauges(id text PRIMARY KEY, val double precision);
+CREATE TABLE IF NOT EXISTS counters(id text PRIMARY KEY, delta bigint);
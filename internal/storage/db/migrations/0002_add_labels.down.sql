@@ -0,0 +1,10 @@
+-- Labeled series cannot be represented without labels and are removed.
+DELETE FROM gauges WHERE labels <> '{}';
+DROP INDEX IF EXISTS gauges_series_idx;
+ALTER TABLE gauges DROP COLUMN IF EXISTS labels;
+ALTER TABLE gauges ADD PRIMARY KEY (id);
+
+DELETE FROM counters WHERE labels <> '{}';
+DROP INDEX IF EXISTS counters_series_idx;
+ALTER TABLE counters DROP COLUMN IF EXISTS labels;
+ALTER TABLE counters ADD PRIMARY KEY (id);
@@ -0,0 +1,8 @@
+-- Series are identified by id together with labels.
+ALTER TABLE gauges ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE gauges DROP CONSTRAINT IF EXISTS gauges_pkey;
+CREATE UNIQUE INDEX IF NOT EXISTS gauges_series_idx ON gauges(id, labels);
+
+ALTER TABLE counters ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE counters DROP CONSTRAINT IF EXISTS counters_pkey;
+CREATE UNIQUE INDEX IF NOT EXISTS counters_series_idx ON counters(id, labels);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS histograms;
+DROP TABLE IF EXISTS summaries;
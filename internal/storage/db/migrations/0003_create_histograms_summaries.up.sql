@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS histograms(
+    id text, labels jsonb NOT NULL DEFAULT '{}', bounds double precision[] NOT NULL, buckets bigint[] NOT NULL,
+    sum double precision, count bigint);
+CREATE UNIQUE INDEX IF NOT EXISTS histograms_series_idx ON histograms(id, labels);
+
+CREATE TABLE IF NOT EXISTS summaries(
+    id text, labels jsonb NOT NULL DEFAULT '{}', quantiles double precision[] NOT NULL,
+    qvalues double precision[] NOT NULL, sum double precision, count bigint);
+CREATE UNIQUE INDEX IF NOT EXISTS summaries_series_idx ON summaries(id, labels);
@@ -0,0 +1,5 @@
+-- Samples are partitioned by day, partitions are created and dropped by the storage according to retention.
+CREATE TABLE IF NOT EXISTS samples(
+    id text NOT NULL, labels jsonb NOT NULL DEFAULT '{}', mtype text NOT NULL,
+    ts timestamptz NOT NULL, val double precision NOT NULL) PARTITION BY RANGE (ts);
+CREATE INDEX IF NOT EXISTS samples_series_idx ON samples(mtype, id, labels, ts);
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -18,69 +19,63 @@ const TimeoutInSeconds = 3
 // var retryDelays = []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second}
 
 type PgStorage struct {
-	pool *pgxpool.Pool
+	pool      *pgxpool.Pool
+	retention time.Duration
+	hub       *storage.Hub
 }
 
-func NewPgStorage(ctx context.Context, dsn string) (*PgStorage, error) {
+// NewPgStorage connects to the database and prepares the tables. Samples older than a positive retention
+// are dropped in the background until ctx is done.
+func NewPgStorage(ctx context.Context, dsn string, retention time.Duration) (*PgStorage, error) {
 	pool, err := pgxpool.New(ctx, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize a connection pool: %w", err)
 	}
 
-	if err = createTablesIfNeeded(ctx, pool); err != nil {
+	if err = Migrate(ctx, pool); err != nil {
+		return nil, fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+
+	pgStorage := &PgStorage{
+		pool:      pool,
+		retention: retention,
+	}
+	if err = pgStorage.rotatePartitions(ctx); err != nil {
 		return nil, err
 	}
+	go pgStorage.maintainPartitions(ctx)
 
-	return &PgStorage{
-		pool: pool,
-	}, nil
+	return pgStorage, nil
 }
 
-func createTablesIfNeeded(ctx context.Context, pool *pgxpool.Pool) error {
+// WithHub publishes every stored metric to the hub.
+func (m *PgStorage) WithHub(hub *storage.Hub) *PgStorage {
+	m.hub = hub
+	return m
+}
+
+func (m *PgStorage) Update(ctx context.Context, metric *model.Metrics) error {
 	c, cancel := context.WithTimeout(ctx, TimeoutInSeconds*time.Second)
 	defer cancel()
-
-	tx, err := pool.Begin(c)
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback(c)
-		}
-	}()
-	if err != nil {
-		return err
-	}
-
-	status, err := tx.Exec(c, `CREATE TABLE IF NOT EXISTS gauges(id text primary key, val double precision)`)
-	if err != nil {
-		return err
-	}
-	logger.Log().Infof("Table 'gauges' has been successfully created: %s", status)
-
-	status, err = tx.Exec(c, `CREATE TABLE IF NOT EXISTS counters(id text primary key, delta bigint)`)
-	if err != nil {
-		return err
-	}
-	logger.Log().Infof("Table 'counters' has been successfully created: %s", status)
-
-	if err = tx.Commit(c); err != nil {
+	if err := m.update(c, metric); err != nil {
 		return err
 	}
+	m.hub.Publish(*metric)
 	return nil
 }
 
-func (m *PgStorage) Update(ctx context.Context, metric *model.Metrics) error {
-	c, cancel := context.WithTimeout(ctx, TimeoutInSeconds*time.Second)
-	defer cancel()
+func (m *PgStorage) update(c context.Context, metric *model.Metrics) error {
+	if err := model.ValidateSeries(metric.ID, metric.Labels); err != nil {
+		return err
+	}
 	switch metric.MType {
 	case model.Counter:
 		if metric.Delta == nil {
 			return storage.ErrMetricNotSupported
 		}
-		query := `INSERT INTO counters(id, delta) VALUES($1, $2)
-		ON CONFLICT(id)
-		DO UPDATE SET delta = counters.delta + EXCLUDED.delta RETURNING delta`
 		var newDelta int64
-		if err := m.pool.QueryRow(c, query, metric.ID, *metric.Delta).Scan(&newDelta); err != nil {
+		if err := m.pool.QueryRow(c, queryCounterWithSample, metric.ID, labelsOf(metric),
+			*metric.Delta).Scan(&newDelta); err != nil {
 			return err
 		}
 		metric.Delta = &newDelta
@@ -89,15 +84,19 @@ func (m *PgStorage) Update(ctx context.Context, metric *model.Metrics) error {
 		if metric.Value == nil {
 			return storage.ErrMetricNotSupported
 		}
-		query := `INSERT INTO gauges(id, val) VALUES($1, $2)
-		ON CONFLICT(id)
-		DO UPDATE SET val = EXCLUDED.val RETURNING val`
 		var newVal float64
-		if err := m.pool.QueryRow(c, query, metric.ID, *metric.Value).Scan(&newVal); err != nil {
+		if err := m.pool.QueryRow(c, queryGaugeWithSample, metric.ID, labelsOf(metric),
+			*metric.Value).Scan(&newVal); err != nil {
 			return err
 		}
 		metric.Value = &newVal
 
+	case model.Histogram:
+		return m.updateHistogram(c, metric)
+
+	case model.Summary:
+		return m.updateSummary(c, metric)
+
 	default:
 		return storage.ErrMetricNotFound
 	}
@@ -109,32 +108,83 @@ func (m *PgStorage) UpdateBatch(ctx context.Context, metrics []model.Metrics) er
 	defer cancel()
 
 	batch := &pgx.Batch{}
-	queryCounter := `INSERT INTO counters(id, delta) VALUES($1, $2)
-		ON CONFLICT(id)
-		DO UPDATE SET delta = counters.delta + EXCLUDED.delta`
-	queryGauge := `INSERT INTO gauges(id, val) VALUES($1, $2)
-		ON CONFLICT(id)
-		DO UPDATE SET val = EXCLUDED.val`
 
+	var queued []model.Metrics
 	for _, m := range metrics {
+		if err := model.ValidateSeries(m.ID, m.Labels); err != nil {
+			return err
+		}
 		switch m.MType {
 		case model.Counter:
-			batch.Queue(queryCounter, m.ID, m.Delta)
+			batch.Queue(queryCounterWithSample, m.ID, labelsOf(&m), m.Delta)
 		case model.Gauge:
-			batch.Queue(queryGauge, m.ID, m.Value)
+			batch.Queue(queryGaugeWithSample, m.ID, labelsOf(&m), m.Value)
+		case model.Histogram:
+			if m.Histogram == nil {
+				return storage.ErrMetricNotSupported
+			}
+			if err := m.Histogram.Validate(); err != nil {
+				return err
+			}
+			bounds, buckets, sum, count := histogramArgs(m.Histogram)
+			batch.Queue(queryHistogram+" RETURNING bounds, buckets, sum, count", m.ID, labelsOf(&m),
+				bounds, buckets, sum, count)
+		case model.Summary:
+			if m.Summary == nil {
+				return storage.ErrMetricNotSupported
+			}
+			if err := m.Summary.Validate(); err != nil {
+				return err
+			}
+			quantiles, values, sum, count := summaryArgs(m.Summary)
+			batch.Queue(querySummary, m.ID, labelsOf(&m), quantiles, values, sum, count)
+		default:
+			continue
 		}
+		queued = append(queued, m)
 	}
 
 	br := m.pool.SendBatch(c, batch)
-
-	tag, err := br.Exec()
-	if err != nil {
-		return err
-	}
 	defer func() {
 		_ = br.Close()
 	}()
-	logger.Log().Infof("Metrics has been succesfully written to DB: %s", tag)
+
+	// every statement returns the stored value, so the metrics published to the hub match the storage
+	for i := range queued {
+		metric := &queued[i]
+		switch metric.MType {
+		case model.Counter:
+			var delta int64
+			if err := br.QueryRow().Scan(&delta); err != nil {
+				return err
+			}
+			metric.Delta = &delta
+		case model.Gauge:
+			var val float64
+			if err := br.QueryRow().Scan(&val); err != nil {
+				return err
+			}
+			metric.Value = &val
+		case model.Histogram:
+			histogram, err := scanHistogram(br.QueryRow())
+			if errors.Is(err, pgx.ErrNoRows) {
+				return model.ErrBucketLayoutMismatch
+			}
+			if err != nil {
+				return err
+			}
+			metric.Histogram = histogram
+		case model.Summary:
+			if _, err := br.Exec(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := br.Close(); err != nil {
+		return err
+	}
+	logger.Log().Infof("Metrics has been succesfully written to DB: %d statements", len(queued))
+	m.hub.Publish(queued...)
 	return nil
 }
 
@@ -142,42 +192,61 @@ func (m *PgStorage) Read(ctx context.Context, metric *model.Metrics) error {
 	c, cancel := context.WithTimeout(ctx, TimeoutInSeconds*time.Second)
 	defer cancel()
 
+	if err := m.read(c, metric); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrMetricNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (m *PgStorage) read(ctx context.Context, metric *model.Metrics) error {
 	switch metric.MType {
 	case model.Counter:
-		if err := m.pool.QueryRow(c, "SELECT delta FROM counters WHERE id = $1", metric.ID).Scan(&metric.Delta); err != nil {
+		if err := m.pool.QueryRow(ctx, "SELECT delta FROM counters WHERE id = $1 AND labels = $2", metric.ID,
+			labelsOf(metric)).Scan(&metric.Delta); err != nil {
 			return err
 		}
 		return nil
 	case model.Gauge:
-		if err := m.pool.QueryRow(c, "SELECT val FROM gauges WHERE id = $1", metric.ID).Scan(&metric.Value); err != nil {
+		if err := m.pool.QueryRow(ctx, "SELECT val FROM gauges WHERE id = $1 AND labels = $2", metric.ID,
+			labelsOf(metric)).Scan(&metric.Value); err != nil {
 			return err
 		}
 		return nil
+	case model.Histogram:
+		return m.readHistogram(ctx, metric)
+	case model.Summary:
+		return m.readSummary(ctx, metric)
 	default:
 		return storage.ErrMetricNotFound
 	}
 }
 
-func (m *PgStorage) GetCounters(ctx context.Context) (map[string]int64, error) {
+func (m *PgStorage) GetCounters(ctx context.Context) ([]model.Metrics, error) {
 	c, cancel := context.WithTimeout(ctx, TimeoutInSeconds*time.Second)
 	defer cancel()
 
-	counters := make(map[string]int64)
-	rows, err := m.pool.Query(c, "SELECT * FROM counters")
+	rows, err := m.pool.Query(c, "SELECT id, labels, delta FROM counters ORDER BY id, labels")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var counters []model.Metrics
 	for rows.Next() {
 		var (
-			id    string
-			delta int64
+			id     string
+			labels model.Labels
+			delta  int64
 		)
-		if err = rows.Scan(&id, &delta); err != nil {
+		if err = rows.Scan(&id, &labels, &delta); err != nil {
 			return nil, err
 		}
-		counters[id] = delta
+		counter := model.NewCounter(id, &delta)
+		counter.Labels = nonEmpty(labels)
+		counters = append(counters, *counter)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, err
@@ -185,26 +254,29 @@ func (m *PgStorage) GetCounters(ctx context.Context) (map[string]int64, error) {
 	return counters, nil
 }
 
-func (m *PgStorage) GetGauges(ctx context.Context) (map[string]float64, error) {
+func (m *PgStorage) GetGauges(ctx context.Context) ([]model.Metrics, error) {
 	c, cancel := context.WithTimeout(ctx, TimeoutInSeconds*time.Second)
 	defer cancel()
 
-	gauges := make(map[string]float64)
-	rows, err := m.pool.Query(c, "SELECT * FROM gauges")
+	rows, err := m.pool.Query(c, "SELECT id, labels, val FROM gauges ORDER BY id, labels")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var gauges []model.Metrics
 	for rows.Next() {
 		var (
-			id  string
-			val float64
+			id     string
+			labels model.Labels
+			val    float64
 		)
-		if err = rows.Scan(&id, &val); err != nil {
+		if err = rows.Scan(&id, &labels, &val); err != nil {
 			return nil, err
 		}
-		gauges[id] = val
+		gauge := model.NewGauge(id, &val)
+		gauge.Labels = nonEmpty(labels)
+		gauges = append(gauges, *gauge)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, err
@@ -212,6 +284,22 @@ func (m *PgStorage) GetGauges(ctx context.Context) (map[string]float64, error) {
 	return gauges, nil
 }
 
+// labelsOf returns labels of the metric suitable for the jsonb column, where absent labels are stored as '{}'.
+func labelsOf(metric *model.Metrics) model.Labels {
+	if metric.Labels == nil {
+		return model.Labels{}
+	}
+	return metric.Labels
+}
+
+// nonEmpty maps the empty label set read from the database back to nil.
+func nonEmpty(labels model.Labels) model.Labels {
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
 func (m *PgStorage) Ping(ctx context.Context) bool {
 	if err := m.pool.Ping(ctx); err != nil {
 		return false
@@ -10,6 +10,8 @@ import (
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/itallix/go-metrics/internal/model"
 )
 
 type DBStorageTestSuite struct {
@@ -48,7 +50,7 @@ func (suite *DBStorageTestSuite) TestDbStorage() {
 	suite.Require().NoError(err)
 
 	dsn := fmt.Sprintf("postgres://username:password@%s/metrics?sslmode=disable", endpoint)
-	storage, err := NewPgStorage(ctx, dsn)
+	storage, err := NewPgStorage(ctx, dsn, time.Hour)
 	suite.Require().NoError(err)
 	suite.NotNil(storage)
 
@@ -70,6 +72,121 @@ func (suite *DBStorageTestSuite) TestDbStorage() {
 	suite.True(exists, "Table 'gauges' was not created")
 }
 
+func (suite *DBStorageTestSuite) TestDbStorageLabels() {
+	ctx := context.Background()
+	endpoint, err := suite.dbContainter.Endpoint(ctx, "")
+	suite.Require().NoError(err)
+
+	dsn := fmt.Sprintf("postgres://username:password@%s/metrics?sslmode=disable", endpoint)
+	storage, err := NewPgStorage(ctx, dsn, time.Hour)
+	suite.Require().NoError(err)
+
+	c0, c1 := int64(1), int64(2)
+	metrics := []model.Metrics{*model.NewCounter("requests", &c0), *model.NewCounter("requests", &c1)}
+	metrics[1].Labels = model.Labels{"host": "web-1"}
+	suite.Require().NoError(storage.UpdateBatch(ctx, metrics))
+	suite.Require().NoError(storage.UpdateBatch(ctx, metrics))
+
+	read := model.Metrics{ID: "requests", MType: model.Counter, Labels: model.Labels{"host": "web-1"}}
+	suite.Require().NoError(storage.Read(ctx, &read))
+	suite.Equal(int64(4), *read.Delta)
+
+	counters, err := storage.GetCounters(ctx)
+	suite.Require().NoError(err)
+	suite.Require().Len(counters, 2)
+	suite.Empty(counters[0].Labels)
+	suite.Equal(int64(2), *counters[0].Delta)
+	suite.Equal(model.Labels{"host": "web-1"}, counters[1].Labels)
+}
+
+func (suite *DBStorageTestSuite) TestDbStorageHistogram() {
+	ctx := context.Background()
+	endpoint, err := suite.dbContainter.Endpoint(ctx, "")
+	suite.Require().NoError(err)
+
+	dsn := fmt.Sprintf("postgres://username:password@%s/metrics?sslmode=disable", endpoint)
+	storage, err := NewPgStorage(ctx, dsn, time.Hour)
+	suite.Require().NoError(err)
+
+	histogram := model.NewHistogramValue([]float64{0.1, 1})
+	histogram.Observe(0.5)
+	suite.Require().NoError(storage.UpdateBatch(ctx, []model.Metrics{*model.NewHistogram("latency", histogram)}))
+	suite.Require().NoError(storage.Update(ctx, model.NewHistogram("latency", histogram)))
+
+	read := model.Metrics{ID: "latency", MType: model.Histogram}
+	suite.Require().NoError(storage.Read(ctx, &read))
+	suite.Equal([]model.Bucket{{UpperBound: 0.1, Count: 0}, {UpperBound: 1, Count: 2}}, read.Histogram.Buckets)
+	suite.Equal(uint64(2), read.Histogram.Count)
+
+	mismatch := model.NewHistogramValue([]float64{5})
+	suite.Require().ErrorIs(storage.Update(ctx, model.NewHistogram("latency", mismatch)), model.ErrBucketLayoutMismatch)
+
+	// histograms without finite bounds have the count and the sum only
+	empty := model.NewHistogramValue(nil)
+	empty.Observe(3)
+	suite.Require().NoError(storage.Update(ctx, model.NewHistogram("requests", empty)))
+	suite.Require().NoError(storage.Update(ctx, model.NewHistogram("requests", empty)))
+	read = model.Metrics{ID: "requests", MType: model.Histogram}
+	suite.Require().NoError(storage.Read(ctx, &read))
+	suite.Empty(read.Histogram.Buckets)
+	suite.Equal(uint64(2), read.Histogram.Count)
+}
+
+func (suite *DBStorageTestSuite) TestDbStorageReadRange() {
+	ctx := context.Background()
+	endpoint, err := suite.dbContainter.Endpoint(ctx, "")
+	suite.Require().NoError(err)
+
+	dsn := fmt.Sprintf("postgres://username:password@%s/metrics?sslmode=disable", endpoint)
+	storage, err := NewPgStorage(ctx, dsn, time.Hour)
+	suite.Require().NoError(err)
+
+	g0, g1 := 1.5, 2.5
+	suite.Require().NoError(storage.Update(ctx, model.NewGauge("temperature", &g0)))
+	suite.Require().NoError(storage.UpdateBatch(ctx, []model.Metrics{*model.NewGauge("temperature", &g1)}))
+
+	samples, err := storage.ReadRange(ctx, &model.RangeQuery{
+		ID:    "temperature",
+		MType: model.Gauge,
+		From:  time.Now().Add(-time.Minute),
+		To:    time.Now().Add(time.Minute),
+	})
+	suite.Require().NoError(err)
+	suite.Require().Len(samples, 2)
+	suite.InDelta(1.5, samples[0].Value, 0.0001)
+	suite.InDelta(2.5, samples[1].Value, 0.0001)
+}
+
+func (suite *DBStorageTestSuite) TestDbMigrations() {
+	ctx := context.Background()
+	endpoint, err := suite.dbContainter.Endpoint(ctx, "")
+	suite.Require().NoError(err)
+
+	dsn := fmt.Sprintf("postgres://username:password@%s/metrics?sslmode=disable", endpoint)
+	storage, err := NewPgStorage(ctx, dsn, time.Hour)
+	suite.Require().NoError(err)
+	defer storage.Close()
+
+	migrator, err := NewMigrator(storage.pool)
+	suite.Require().NoError(err)
+	applied, err := migrator.Up(ctx)
+	suite.Require().NoError(err)
+	suite.Zero(applied, "migrations are applied on start")
+
+	reverted, err := migrator.Down(ctx, 1)
+	suite.Require().NoError(err)
+	suite.Equal(1, reverted)
+	statuses, err := migrator.Status(ctx)
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(statuses)
+	suite.Nil(statuses[len(statuses)-1].AppliedAt)
+	suite.NotNil(statuses[0].AppliedAt)
+
+	applied, err = migrator.Up(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(1, applied)
+}
+
 func TestDbStorageTestSuite(t *testing.T) {
 	suite.Run(t, new(DBStorageTestSuite))
 }
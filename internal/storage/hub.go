@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// Change is a stored metric delivered to a subscriber.
+type Change struct {
+	// Metric holds the value after the update, e.g. the accumulated value of a counter.
+	Metric model.Metrics
+	// Dropped is the number of changes skipped right before this one because the subscriber buffer was full.
+	Dropped uint64
+}
+
+// Filter selects the metrics a subscriber is interested in.
+type Filter func(metric *model.Metrics) bool
+
+// Hub fans out metrics stored by a storage to subscribers. Publishing never blocks: every subscriber has
+// a bounded buffer, and changes that do not fit are dropped and reported with the next delivered change.
+// A nil hub is valid and discards everything.
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives changes accepted by its filter until it is closed.
+type Subscription struct {
+	hub     *Hub
+	filter  Filter
+	changes chan Change
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// Subscribe registers a subscriber with a buffer of the given size. A nil filter accepts every metric.
+// The subscription of a closed hub is closed right away.
+func (h *Hub) Subscribe(filter Filter, size int) *Subscription {
+	sub := &Subscription{hub: h, filter: filter, changes: make(chan Change, size)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		sub.once.Do(func() { close(sub.changes) })
+		return sub
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers the stored metrics to subscribers, it is called after the storage update has succeeded.
+func (h *Hub) Publish(metrics ...model.Metrics) {
+	if h == nil {
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		for i := range metrics {
+			if sub.filter != nil && !sub.filter(&metrics[i]) {
+				continue
+			}
+			sub.deliver(metrics[i])
+		}
+	}
+}
+
+// Close closes every subscription, so their readers can finish.
+func (h *Hub) Close() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		sub.once.Do(func() { close(sub.changes) })
+	}
+}
+
+func (s *Subscription) deliver(metric model.Metrics) {
+	// the dropped counter is taken before the attempt and given back when the buffer is still full
+	dropped := s.dropped.Swap(0)
+	select {
+	case s.changes <- Change{Metric: metric, Dropped: dropped}:
+	default:
+		s.dropped.Add(dropped + 1)
+	}
+}
+
+// Changes returns the channel of changes, which is closed when the subscription or the hub is closed.
+func (s *Subscription) Changes() <-chan Change {
+	return s.changes
+}
+
+// Dropped returns the number of changes dropped since the last delivered change.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes from the hub.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	delete(s.hub.subs, s)
+	s.once.Do(func() { close(s.changes) })
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+func TestHub_DropsWhenFull(t *testing.T) {
+	hub := NewHub()
+	gauges := hub.Subscribe(func(metric *model.Metrics) bool { return metric.MType == model.Gauge }, 2)
+	all := hub.Subscribe(nil, 8)
+
+	c := int64(1)
+	for i := 0; i < 4; i++ {
+		g := float64(i)
+		hub.Publish(*model.NewGauge("g0", &g), *model.NewCounter("c0", &c))
+	}
+	assert.Equal(t, uint64(2), gauges.Dropped())
+
+	change := <-gauges.Changes()
+	assert.InDelta(t, 0.0, *change.Metric.Value, 0.0001)
+	assert.Zero(t, change.Dropped)
+	<-gauges.Changes()
+
+	// the next delivered change reports the dropped ones
+	g := 4.0
+	hub.Publish(*model.NewGauge("g0", &g))
+	change = <-gauges.Changes()
+	assert.InDelta(t, 4.0, *change.Metric.Value, 0.0001)
+	assert.Equal(t, uint64(2), change.Dropped)
+	assert.Zero(t, gauges.Dropped())
+
+	assert.Len(t, all.Changes(), 8)
+	all.Close()
+	hub.Close()
+	_, ok := <-gauges.Changes()
+	assert.False(t, ok)
+
+	closed := hub.Subscribe(nil, 1)
+	_, ok = <-closed.Changes()
+	require.False(t, ok)
+
+	var nilHub *Hub
+	nilHub.Publish(*model.NewCounter("c0", &c))
+}
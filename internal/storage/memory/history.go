@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+const (
+	DefaultHistorySize = 1024
+	DefaultRetention   = time.Hour
+)
+
+// ring is a bounded circular buffer of time ordered samples, the oldest sample is overwritten first once
+// the buffer holds capacity samples. The buffer grows with the samples, so rarely updated series stay small.
+type ring struct {
+	samples  []model.Sample
+	start    int
+	size     int
+	capacity int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{capacity: capacity}
+}
+
+func (r *ring) push(sample model.Sample) {
+	if r.size == len(r.samples) && len(r.samples) < r.capacity {
+		grown := make([]model.Sample, min(max(2*len(r.samples), 4), r.capacity))
+		for i := 0; i < r.size; i++ {
+			grown[i] = r.samples[(r.start+i)%len(r.samples)]
+		}
+		r.samples, r.start = grown, 0
+	}
+	end := (r.start + r.size) % len(r.samples)
+	r.samples[end] = sample
+	if r.size < len(r.samples) {
+		r.size++
+	} else {
+		r.start = (r.start + 1) % len(r.samples)
+	}
+}
+
+// dropBefore evicts samples older than the given time.
+func (r *ring) dropBefore(t time.Time) {
+	for r.size > 0 && r.samples[r.start].Timestamp.Before(t) {
+		r.start = (r.start + 1) % len(r.samples)
+		r.size--
+	}
+}
+
+func (r *ring) between(from, to time.Time) []model.Sample {
+	var result []model.Sample
+	for i := 0; i < r.size; i++ {
+		sample := r.samples[(r.start+i)%len(r.samples)]
+		if sample.Timestamp.Before(from) || sample.Timestamp.After(to) {
+			continue
+		}
+		result = append(result, sample)
+	}
+	return result
+}
+
+// History keeps the latest samples of every series in bounded ring buffers.
+// Samples older than a positive retention period are evicted, series without samples are dropped.
+type History struct {
+	series    map[string]*ring
+	capacity  int
+	retention time.Duration
+	// pruned is the time series were checked for expired samples last.
+	pruned time.Time
+	now    func() time.Time
+	mu     sync.RWMutex
+}
+
+func NewHistory(capacity int, retention time.Duration) *History {
+	if capacity <= 0 {
+		capacity = DefaultHistorySize
+	}
+	return &History{
+		series:    make(map[string]*ring),
+		capacity:  capacity,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Append records the value of the series with the current timestamp.
+func (h *History) Append(mtype model.MetricType, seriesKey string, value float64) {
+	now := h.now()
+	key := historyKey(mtype, seriesKey)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.series[key]
+	if !ok {
+		r = newRing(h.capacity)
+		h.series[key] = r
+	}
+	if h.retention > 0 {
+		r.dropBefore(now.Add(-h.retention))
+	}
+	r.push(model.Sample{Timestamp: now, Value: value})
+	h.prune(now)
+}
+
+// prune drops the series whose samples have all expired, the series are scanned at most once per retention period.
+func (h *History) prune(now time.Time) {
+	if h.retention <= 0 || now.Sub(h.pruned) < h.retention {
+		return
+	}
+	for key, r := range h.series {
+		if r.dropBefore(now.Add(-h.retention)); r.size == 0 {
+			delete(h.series, key)
+		}
+	}
+	h.pruned = now
+}
+
+// Range returns time ordered samples of the series within [from, to] that are not older than retention period.
+func (h *History) Range(mtype model.MetricType, seriesKey string, from, to time.Time) []model.Sample {
+	if oldest := h.now().Add(-h.retention); h.retention > 0 && from.Before(oldest) {
+		from = oldest
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	r, ok := h.series[historyKey(mtype, seriesKey)]
+	if !ok {
+		return nil
+	}
+	return r.between(from, to)
+}
+
+func historyKey(mtype model.MetricType, seriesKey string) string {
+	return string(mtype) + ":" + seriesKey
+}
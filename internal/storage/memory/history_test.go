@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+func TestHistory_RingBuffer(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewHistory(3, time.Hour)
+	h.now = func() time.Time { return now }
+
+	for i := 1; i <= 5; i++ {
+		now = now.Add(time.Second)
+		h.Append(model.Gauge, "g0", float64(i))
+	}
+
+	samples := h.Range(model.Gauge, "g0", now.Add(-time.Minute), now)
+	assert.Equal(t, []float64{3, 4, 5}, values(samples))
+	assert.Equal(t, []float64{4}, values(h.Range(model.Gauge, "g0", now.Add(-time.Second), now.Add(-time.Second))))
+	assert.Empty(t, h.Range(model.Counter, "g0", now.Add(-time.Minute), now))
+}
+
+func TestHistory_Retention(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewHistory(10, time.Minute)
+	h.now = func() time.Time { return now }
+
+	h.Append(model.Counter, "c0", 1)
+	now = now.Add(45 * time.Second)
+	h.Append(model.Counter, "c0", 2)
+	now = now.Add(30 * time.Second)
+
+	assert.Equal(t, []float64{2}, values(h.Range(model.Counter, "c0", now.Add(-time.Hour), now)))
+
+	h.Append(model.Counter, "c0", 3)
+	assert.Equal(t, 2, h.series["counter:c0"].size)
+
+	// series whose samples have all expired are dropped
+	now = now.Add(2 * time.Minute)
+	h.Append(model.Gauge, "g0", 1)
+	assert.NotContains(t, h.series, "counter:c0")
+	assert.Contains(t, h.series, "gauge:g0")
+}
+
+func TestHistory_NoRetention(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewHistory(2, 0)
+	h.now = func() time.Time { return now }
+
+	// samples are kept until they are overwritten
+	h.Append(model.Counter, "c0", 1)
+	now = now.Add(24 * time.Hour)
+	h.Append(model.Gauge, "g0", 1)
+	assert.Equal(t, []float64{1}, values(h.Range(model.Counter, "c0", time.Time{}, now)))
+
+	h.Append(model.Counter, "c0", 2)
+	h.Append(model.Counter, "c0", 3)
+	assert.Equal(t, []float64{2, 3}, values(h.Range(model.Counter, "c0", time.Time{}, now)))
+}
+
+func TestHistory_Grow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewHistory(10, time.Hour)
+	h.now = func() time.Time { return now }
+
+	h.Append(model.Gauge, "g0", 1)
+	assert.Len(t, h.series["gauge:g0"].samples, 4, "a new series does not take the whole capacity")
+
+	for i := 2; i <= 12; i++ {
+		now = now.Add(time.Second)
+		h.Append(model.Gauge, "g0", float64(i))
+	}
+	assert.Len(t, h.series["gauge:g0"].samples, 10)
+	samples := h.Range(model.Gauge, "g0", now.Add(-time.Hour), now)
+	assert.Equal(t, []float64{3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, values(samples))
+}
+
+func values(samples []model.Sample) []float64 {
+	result := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		result = append(result, s.Value)
+	}
+	return result
+}
@@ -55,3 +55,56 @@ func (m *ConcurrentMap[T]) Init(values map[string]T) {
 func (m *ConcurrentMap[T]) Len() int {
 	return len(m.store)
 }
+
+// ValueMap is a thread-safe map for values which are merged rather than added up, e.g. histograms.
+// Stored values are treated as immutable: Update replaces them with the result of the merge function,
+// so values returned by Get and Copy can be read without holding the lock.
+type ValueMap[T any] struct {
+	store map[string]T
+	mu    sync.RWMutex
+}
+
+func NewValueMap[T any](size int) *ValueMap[T] {
+	return &ValueMap[T]{
+		store: make(map[string]T, size),
+	}
+}
+
+func (m *ValueMap[T]) Update(name string, merge func(current T, exists bool) (T, error)) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, exists := m.store[name]
+	value, err := merge(current, exists)
+	if err != nil {
+		return current, err
+	}
+	m.store[name] = value
+	return value, nil
+}
+
+func (m *ValueMap[T]) Get(name string) (T, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	val, ok := m.store[name]
+	return val, ok
+}
+
+func (m *ValueMap[T]) Copy() map[string]T {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	clone := make(map[string]T, len(m.store))
+	for key, value := range m.store {
+		clone[key] = value
+	}
+	return clone
+}
+
+func (m *ValueMap[T]) Init(values map[string]T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = values
+}
+
+func (m *ValueMap[T]) Len() int {
+	return len(m.store)
+}
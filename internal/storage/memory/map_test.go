@@ -1,9 +1,11 @@
 package memory
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConcurrentMap_UpdateValue(t *testing.T) {
@@ -55,3 +57,25 @@ func TestConcurrentMap_Len(t *testing.T) {
 
 	assert.Equal(t, 3, m1.Len())
 }
+
+func TestValueMap_Update(t *testing.T) {
+	m := NewValueMap[string](1)
+	join := func(suffix string) func(string, bool) (string, error) {
+		return func(current string, _ bool) (string, error) {
+			return current + suffix, nil
+		}
+	}
+	_, _ = m.Update("v0", join("a"))
+	val, err := m.Update("v0", join("b"))
+	require.NoError(t, err)
+	assert.Equal(t, "ab", val)
+
+	_, err = m.Update("v0", func(string, bool) (string, error) {
+		return "", errors.New("boom")
+	})
+	require.Error(t, err)
+	val, ok := m.Get("v0")
+	assert.True(t, ok)
+	assert.Equal(t, "ab", val)
+	assert.Equal(t, 1, m.Len())
+}
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/itallix/go-metrics/internal/logger"
@@ -10,87 +11,193 @@ import (
 )
 
 const (
-	DefaultCounterCapacity = 1
-	DefaultGaugeCapacity   = 32
+	DefaultCounterCapacity      = 1
+	DefaultGaugeCapacity        = 32
+	DefaultDistributionCapacity = 1
 )
 
+// Series holds every series of the in-memory storage grouped by metric type.
+type Series struct {
+	counters   *ConcurrentMap[int64]
+	gauges     *ConcurrentMap[float64]
+	histograms *ValueMap[*model.HistogramValue]
+	summaries  *ValueMap[*model.SummaryValue]
+}
+
+func NewSeries() *Series {
+	return &Series{
+		counters:   NewConcurrentMap[int64](DefaultCounterCapacity),
+		gauges:     NewConcurrentMap[float64](DefaultGaugeCapacity),
+		histograms: NewValueMap[*model.HistogramValue](DefaultDistributionCapacity),
+		summaries:  NewValueMap[*model.SummaryValue](DefaultDistributionCapacity),
+	}
+}
+
 type MemStorage struct {
-	counters *ConcurrentMap[int64]
-	gauges   *ConcurrentMap[float64]
-	syncCh   chan int
+	*Series
+
+	history *History
+	wal     *WAL
+	syncCh  chan int
+	hub     *storage.Hub
 }
 
 func NewMemStorage(ctx context.Context, wg *sync.WaitGroup, config *Config) *MemStorage {
-	var syncCh chan int
-	counters := NewConcurrentMap[int64](DefaultCounterCapacity)
-	gauges := NewConcurrentMap[float64](DefaultGaugeCapacity)
+	var (
+		syncCh chan int
+		wal    *WAL
+	)
+	series := NewSeries()
+	history := NewHistory(DefaultHistorySize, DefaultRetention)
 
 	if config != nil {
+		history = NewHistory(config.historySize, config.retention)
 		if config.filepath == "" {
 			logger.Log().Info("Filepath is not defined. Server will proceed in memory mode.")
 		} else {
 			if config.interval == 0 {
 				syncCh = make(chan int)
 			}
-			syncer := NewFileSyncer(config, counters, gauges, syncCh)
+			syncer := NewFileSyncer(config, series, syncCh)
+			if config.wal {
+				wal = NewWAL(config.filepath + WALSuffix)
+				syncer.WithWAL(wal)
+			}
 			syncer.Start(ctx, wg)
 		}
 	}
 	return &MemStorage{
-		counters: counters,
-		gauges:   gauges,
-		syncCh:   syncCh,
+		Series:  series,
+		history: history,
+		wal:     wal,
+		syncCh:  syncCh,
 	}
 }
 
+// WithHub publishes every stored metric to the hub.
+func (m *MemStorage) WithHub(hub *storage.Hub) *MemStorage {
+	m.hub = hub
+	return m
+}
+
 func (m *MemStorage) Update(_ context.Context, metric *model.Metrics) error {
+	if err := model.ValidateSeries(metric.ID, metric.Labels); err != nil {
+		return err
+	}
+	err := m.write([]model.Metrics{*metric}, func() error {
+		return m.apply(metric)
+	})
+	if err != nil {
+		return err
+	}
+	if m.syncCh != nil {
+		m.syncCh <- 1
+	}
+	m.hub.Publish(*metric)
+	return nil
+}
+
+func (m *MemStorage) UpdateBatch(_ context.Context, metrics []model.Metrics) error {
+	// invalid series are rejected before the batch is logged ahead
+	for _, metric := range metrics {
+		if err := model.ValidateSeries(metric.ID, metric.Labels); err != nil {
+			return err
+		}
+	}
+	stored := make([]model.Metrics, 0, len(metrics))
+	err := m.write(metrics, func() error {
+		for _, metric := range metrics {
+			if err := m.apply(&metric); err != nil {
+				return err
+			}
+			stored = append(stored, metric)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if m.syncCh != nil {
+		m.syncCh <- 1
+	}
+	m.hub.Publish(stored...)
+	return nil
+}
+
+// write logs the mutation ahead when WAL is enabled and applies it.
+func (m *MemStorage) write(metrics []model.Metrics, apply func() error) error {
+	if m.wal == nil {
+		return apply()
+	}
+	return m.wal.Write(metrics, apply)
+}
+
+// apply writes the metric into the corresponding series and updates the metric with the stored value.
+// Counters and histograms are accumulated, gauges and summaries are overwritten.
+func (s *Series) apply(metric *model.Metrics) error {
 	switch metric.MType {
 	case model.Counter:
 		if metric.Delta == nil {
 			return storage.ErrMetricNotSupported
 		}
-		val := m.counters.Inc(metric.ID, *metric.Delta)
+		val := s.counters.Inc(metric.SeriesKey(), *metric.Delta)
 		metric.Delta = &val
 
 	case model.Gauge:
 		if metric.Value == nil {
 			return storage.ErrMetricNotSupported
 		}
-		val := m.gauges.Set(metric.ID, *metric.Value)
+		val := s.gauges.Set(metric.SeriesKey(), *metric.Value)
 		metric.Value = &val
 
+	case model.Histogram:
+		if metric.Histogram == nil {
+			return storage.ErrMetricNotSupported
+		}
+		if err := metric.Histogram.Validate(); err != nil {
+			return err
+		}
+		val, err := s.histograms.Update(metric.SeriesKey(), func(current *model.HistogramValue, exists bool) (
+			*model.HistogramValue, error) {
+			if !exists {
+				return metric.Histogram.Clone(), nil
+			}
+			merged := current.Clone()
+			return merged, merged.Merge(metric.Histogram)
+		})
+		if err != nil {
+			return err
+		}
+		metric.Histogram = val.Clone()
+
+	case model.Summary:
+		if metric.Summary == nil {
+			return storage.ErrMetricNotSupported
+		}
+		if err := metric.Summary.Validate(); err != nil {
+			return err
+		}
+		val, _ := s.summaries.Update(metric.SeriesKey(), func(*model.SummaryValue, bool) (*model.SummaryValue, error) {
+			return metric.Summary.Clone(), nil
+		})
+		metric.Summary = val.Clone()
+
 	default:
 		return storage.ErrMetricNotFound
 	}
-	if m.syncCh != nil {
-		m.syncCh <- 1
-	}
 	return nil
 }
 
-func (m *MemStorage) UpdateBatch(_ context.Context, metrics []model.Metrics) error {
-	for _, metric := range metrics {
-		switch metric.MType {
-		case model.Counter:
-			if metric.Delta == nil {
-				return storage.ErrMetricNotSupported
-			}
-			val := m.counters.Inc(metric.ID, *metric.Delta)
-			metric.Delta = &val
-
-		case model.Gauge:
-			if metric.Value == nil {
-				return storage.ErrMetricNotSupported
-			}
-			val := m.gauges.Set(metric.ID, *metric.Value)
-			metric.Value = &val
-
-		default:
-			return storage.ErrMetricNotFound
-		}
+// apply writes the metric into the series and records the history of counters and gauges.
+func (m *MemStorage) apply(metric *model.Metrics) error {
+	if err := m.Series.apply(metric); err != nil {
+		return err
 	}
-	if m.syncCh != nil {
-		m.syncCh <- 1
+	switch metric.MType {
+	case model.Counter:
+		m.history.Append(model.Counter, metric.SeriesKey(), float64(*metric.Delta))
+	case model.Gauge:
+		m.history.Append(model.Gauge, metric.SeriesKey(), *metric.Value)
 	}
 	return nil
 }
@@ -98,30 +205,95 @@ func (m *MemStorage) UpdateBatch(_ context.Context, metrics []model.Metrics) err
 func (m *MemStorage) Read(_ context.Context, metric *model.Metrics) error {
 	switch metric.MType {
 	case model.Counter:
-		val, ok := m.counters.Get(metric.ID)
+		val, ok := m.counters.Get(metric.SeriesKey())
 		if !ok {
 			return storage.ErrMetricNotFound
 		}
 		metric.Delta = &val
 		return nil
 	case model.Gauge:
-		val, ok := m.gauges.Get(metric.ID)
+		val, ok := m.gauges.Get(metric.SeriesKey())
 		if !ok {
 			return storage.ErrMetricNotFound
 		}
 		metric.Value = &val
 		return nil
+	case model.Histogram:
+		val, ok := m.histograms.Get(metric.SeriesKey())
+		if !ok {
+			return storage.ErrMetricNotFound
+		}
+		metric.Histogram = val.Clone()
+		return nil
+	case model.Summary:
+		val, ok := m.summaries.Get(metric.SeriesKey())
+		if !ok {
+			return storage.ErrMetricNotFound
+		}
+		metric.Summary = val.Clone()
+		return nil
 	default:
 		return storage.ErrMetricNotFound
 	}
 }
 
-func (m *MemStorage) GetCounters(_ context.Context) (map[string]int64, error) {
-	return m.counters.Copy(), nil
+// ReadRange returns the history of a counter or gauge series. History is kept in memory only
+// and is not restored from the file.
+func (m *MemStorage) ReadRange(_ context.Context, query *model.RangeQuery) ([]model.Sample, error) {
+	switch query.MType {
+	case model.Counter, model.Gauge:
+		samples := m.history.Range(query.MType, query.SeriesKey(), query.From, query.To)
+		if query.Step == 0 && len(samples) > model.MaxRangePoints {
+			return nil, storage.ErrTooManySamples
+		}
+		return model.Downsample(samples, query.From, query.To, query.Step), nil
+	default:
+		return nil, storage.ErrMetricNotSupported
+	}
+}
+
+func (m *MemStorage) GetCounters(_ context.Context) ([]model.Metrics, error) {
+	return toSeries(m.counters.Copy(), model.NewCounter)
 }
 
-func (m *MemStorage) GetGauges(_ context.Context) (map[string]float64, error) {
-	return m.gauges.Copy(), nil
+func (m *MemStorage) GetGauges(_ context.Context) ([]model.Metrics, error) {
+	return toSeries(m.gauges.Copy(), model.NewGauge)
+}
+
+func (m *MemStorage) GetHistograms(_ context.Context) ([]model.Metrics, error) {
+	return toSeries(m.histograms.Copy(), func(id string, value **model.HistogramValue) *model.Metrics {
+		return model.NewHistogram(id, (*value).Clone())
+	})
+}
+
+func (m *MemStorage) GetSummaries(_ context.Context) ([]model.Metrics, error) {
+	return toSeries(m.summaries.Copy(), func(id string, value **model.SummaryValue) *model.Metrics {
+		return model.NewSummary(id, (*value).Clone())
+	})
+}
+
+// toSeries converts values keyed by series key into a list of metrics ordered by series key.
+// Malformed keys, stored before series were validated, are skipped.
+func toSeries[T any](values map[string]T, newMetric func(string, *T) *model.Metrics) ([]model.Metrics, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	series := make([]model.Metrics, 0, len(keys))
+	for _, key := range keys {
+		id, labels, err := model.ParseSeriesKey(key)
+		if err != nil {
+			logger.Log().Warnf("Skipping series %q: %v", key, err)
+			continue
+		}
+		value := values[key]
+		metric := newMetric(id, &value)
+		metric.Labels = labels
+		series = append(series, *metric)
+	}
+	return series, nil
 }
 
 func (m *MemStorage) Ping(_ context.Context) bool {
@@ -4,11 +4,13 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/itallix/go-metrics/internal/model"
+	"github.com/itallix/go-metrics/internal/storage"
 )
 
 func TestStorage_Update(t *testing.T) {
@@ -22,10 +24,14 @@ func TestStorage_Update(t *testing.T) {
 
 	cc, err := s.GetCounters(ctx)
 	require.NoError(t, err)
-	assert.Equal(t, int64(64), cc["c0"])
+	require.Len(t, cc, 1)
+	assert.Equal(t, "c0", cc[0].ID)
+	assert.Equal(t, int64(64), *cc[0].Delta)
 	gg, err := s.GetGauges(ctx)
 	require.NoError(t, err)
-	assert.Equal(t, float64(64), gg["g0"])
+	require.Len(t, gg, 1)
+	assert.Equal(t, "g0", gg[0].ID)
+	assert.Equal(t, float64(64), *gg[0].Value)
 }
 
 func TestStorage_UpdateBatch(t *testing.T) {
@@ -39,10 +45,14 @@ func TestStorage_UpdateBatch(t *testing.T) {
 
 	cc, err := s.GetCounters(ctx)
 	require.NoError(t, err)
-	assert.Equal(t, int64(64), cc["c0"])
+	require.Len(t, cc, 1)
+	assert.Equal(t, "c0", cc[0].ID)
+	assert.Equal(t, int64(64), *cc[0].Delta)
 	gg, err := s.GetGauges(ctx)
 	require.NoError(t, err)
-	assert.Equal(t, float64(64), gg["g0"])
+	require.Len(t, gg, 1)
+	assert.Equal(t, "g0", gg[0].ID)
+	assert.Equal(t, float64(64), *gg[0].Value)
 }
 
 func TestStorage_Read(t *testing.T) {
@@ -78,6 +88,137 @@ func TestStorage_Read(t *testing.T) {
 	assert.Equal(t, model.Gauge, read.MType)
 }
 
+func TestStorage_Labels(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStorage(ctx, nil, nil)
+	c0, c1, c2 := int64(1), int64(2), int64(3)
+	metrics := []model.Metrics{*model.NewCounter("requests", &c0), *model.NewCounter("requests", &c1),
+		*model.NewCounter("requests", &c2)}
+	metrics[1].Labels = model.Labels{"host": "web-1"}
+	metrics[2].Labels = model.Labels{"host": "web-1"}
+	err := s.UpdateBatch(ctx, metrics)
+	require.NoError(t, err)
+
+	read := model.Metrics{ID: "requests", MType: model.Counter, Labels: model.Labels{"host": "web-1"}}
+	err = s.Read(ctx, &read)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *read.Delta)
+
+	cc, err := s.GetCounters(ctx)
+	require.NoError(t, err)
+	require.Len(t, cc, 2)
+	assert.Equal(t, int64(1), *cc[0].Delta)
+	assert.Empty(t, cc[0].Labels)
+	assert.Equal(t, int64(5), *cc[1].Delta)
+	assert.Equal(t, model.Labels{"host": "web-1"}, cc[1].Labels)
+}
+
+func TestStorage_InvalidSeries(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStorage(ctx, nil, nil)
+	g := 1.0
+	require.ErrorIs(t, s.Update(ctx, model.NewGauge("weird{name", &g)), model.ErrInvalidSeries)
+	unlabeled := model.NewGauge(`cpu{core="1"}`, &g)
+	require.ErrorIs(t, s.UpdateBatch(ctx, []model.Metrics{*model.NewGauge("cpu", &g), *unlabeled}),
+		model.ErrInvalidSeries)
+
+	// series stored before the validation do not break listing
+	s.gauges.Set("weird{name", g)
+	require.NoError(t, s.Update(ctx, model.NewGauge("cpu", &g)))
+	gg, err := s.GetGauges(ctx)
+	require.NoError(t, err)
+	require.Len(t, gg, 1)
+	assert.Equal(t, "cpu", gg[0].ID)
+}
+
+func TestStorage_Histogram(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStorage(ctx, nil, nil)
+	agent1 := model.NewHistogramValue([]float64{0.1, 1})
+	agent1.Observe(0.05)
+	agent2 := model.NewHistogramValue([]float64{0.1, 1})
+	agent2.Observe(0.5)
+	agent2.Observe(2)
+
+	err := s.UpdateBatch(ctx, []model.Metrics{*model.NewHistogram("latency", agent1),
+		*model.NewHistogram("latency", agent2)})
+	require.NoError(t, err)
+
+	read := model.Metrics{ID: "latency", MType: model.Histogram}
+	require.NoError(t, s.Read(ctx, &read))
+	assert.Equal(t, []model.Bucket{{UpperBound: 0.1, Count: 1}, {UpperBound: 1, Count: 2}}, read.Histogram.Buckets)
+	assert.Equal(t, uint64(3), read.Histogram.Count)
+	assert.InEpsilon(t, 2.55, read.Histogram.Sum, 0.00001)
+
+	mismatch := model.NewHistogramValue([]float64{0.5})
+	err = s.Update(ctx, model.NewHistogram("latency", mismatch))
+	require.ErrorIs(t, err, model.ErrBucketLayoutMismatch)
+
+	histograms, err := s.GetHistograms(ctx)
+	require.NoError(t, err)
+	require.Len(t, histograms, 1)
+	assert.Equal(t, uint64(3), histograms[0].Histogram.Count)
+}
+
+func TestStorage_Summary(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStorage(ctx, nil, nil)
+	first := &model.SummaryValue{Quantiles: []model.Quantile{{Quantile: 0.5, Value: 1}}, Sum: 10, Count: 5}
+	second := &model.SummaryValue{Quantiles: []model.Quantile{{Quantile: 0.5, Value: 2}}, Sum: 12, Count: 6}
+	require.NoError(t, s.Update(ctx, model.NewSummary("latency", first)))
+	require.NoError(t, s.Update(ctx, model.NewSummary("latency", second)))
+
+	summaries, err := s.GetSummaries(ctx)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, second, summaries[0].Summary)
+
+	invalid := &model.SummaryValue{Quantiles: []model.Quantile{{Quantile: 2}}}
+	require.ErrorIs(t, s.Update(ctx, model.NewSummary("latency", invalid)), model.ErrInvalidSummary)
+}
+
+func TestStorage_ReadRange(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStorage(ctx, nil, nil)
+	c := int64(2)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Update(ctx, model.NewCounter("c0", &c)))
+	}
+
+	samples, err := s.ReadRange(ctx, &model.RangeQuery{
+		ID:    "c0",
+		MType: model.Counter,
+		From:  time.Now().Add(-time.Minute),
+		To:    time.Now(),
+	})
+	require.NoError(t, err)
+	require.Len(t, samples, 3)
+	assert.InDelta(t, 6.0, samples[2].Value, 0.0001)
+
+	_, err = s.ReadRange(ctx, &model.RangeQuery{ID: "h0", MType: model.Histogram})
+	require.ErrorIs(t, err, storage.ErrMetricNotSupported)
+}
+
+func TestStorage_ReadRangeLimit(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStorage(ctx, nil, new(Config).WithHistory(model.MaxRangePoints+1, time.Hour))
+	v := 1.0
+	for i := 0; i <= model.MaxRangePoints; i++ {
+		require.NoError(t, s.Update(ctx, model.NewGauge("g0", &v)))
+	}
+	query := &model.RangeQuery{ID: "g0", MType: model.Gauge, From: time.Now().Add(-time.Minute), To: time.Now()}
+
+	// raw samples are limited
+	_, err := s.ReadRange(ctx, query)
+	require.ErrorIs(t, err, storage.ErrTooManySamples)
+
+	// downsampled samples are limited by the step
+	query.Step = time.Minute
+	samples, err := s.ReadRange(ctx, query)
+	require.NoError(t, err)
+	assert.NotEmpty(t, samples)
+}
+
 func TestStorage_Ping(t *testing.T) {
 	ctx := context.Background()
 	s := NewMemStorage(ctx, nil, nil)
@@ -1,9 +1,14 @@
 package memory
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -11,11 +16,15 @@ import (
 	"github.com/itallix/go-metrics/internal/model"
 )
 
-// Config defines start parameters for the sync process.
+// Config defines start parameters for the sync process and the series history.
 type Config struct {
-	filepath string
-	interval int
-	restore  bool
+	filepath    string
+	interval    int
+	restore     bool
+	historySize int
+	retention   time.Duration
+	wal         bool
+	generations int
 }
 
 func NewConfig(filepath string, interval int, restore bool) *Config {
@@ -26,58 +35,207 @@ func NewConfig(filepath string, interval int, restore bool) *Config {
 	}
 }
 
+// WithHistory sets the number of samples kept per series and for how long they are kept.
+// Zero size falls back to DefaultHistorySize, zero retention keeps samples until they are overwritten.
+func (c *Config) WithHistory(size int, retention time.Duration) *Config {
+	c.historySize = size
+	c.retention = retention
+	return c
+}
+
+// WithWAL enables the write-ahead log, so mutations made since the last snapshot survive a crash.
+func (c *Config) WithWAL(enabled bool) *Config {
+	c.wal = enabled
+	return c
+}
+
+// WithGenerations sets how many snapshots are kept, including the latest one.
+// Zero value falls back to DefaultGenerations.
+func (c *Config) WithGenerations(generations int) *Config {
+	c.generations = generations
+	return c
+}
+
+// DefaultGenerations is the number of kept snapshots when it is not configured.
+const DefaultGenerations = 3
+
+// WALSuffix is appended to the snapshot filepath to get the location of the write-ahead log.
+const WALSuffix = ".wal"
+
+// snapshot is the file format used with the write-ahead log: along with the metrics it keeps
+// the LSN of the last WAL record included, so only newer records are replayed on restore.
+// Without WAL, the metrics are stored as a plain JSON array.
+type snapshot struct {
+	LSN     uint64          `json:"lsn"`
+	Metrics []model.Metrics `json:"metrics"`
+}
+
 type FileSyncer struct {
-	config   *Config
-	counters *ConcurrentMap[int64]
-	gauges   *ConcurrentMap[float64]
-	syncCh   chan int
+	config *Config
+	series *Series
+	wal    *WAL
+	syncCh chan int
 }
 
-func NewFileSyncer(config *Config, counters *ConcurrentMap[int64], gauges *ConcurrentMap[float64],
-	syncCh chan int) *FileSyncer {
+func NewFileSyncer(config *Config, series *Series, syncCh chan int) *FileSyncer {
 	return &FileSyncer{
-		config:   config,
-		counters: counters,
-		gauges:   gauges,
-		syncCh:   syncCh,
+		config: config,
+		series: series,
+		syncCh: syncCh,
 	}
 }
 
-func toMetrics(counters *ConcurrentMap[int64], gauges *ConcurrentMap[float64]) []*model.Metrics {
-	metrics := make([]*model.Metrics, counters.Len()+gauges.Len())
-	i := 0
-	for k, v := range counters.Copy() {
-		metrics[i] = model.NewCounter(k, &v)
-		i++
+// WithWAL makes the syncer replay the log on restore and checkpoint it with every snapshot.
+func (s *FileSyncer) WithWAL(wal *WAL) *FileSyncer {
+	s.wal = wal
+	return s
+}
+
+func toMetrics(series *Series) ([]model.Metrics, error) {
+	counters, err := toSeries(series.counters.Copy(), model.NewCounter)
+	if err != nil {
+		return nil, err
+	}
+	gauges, err := toSeries(series.gauges.Copy(), model.NewGauge)
+	if err != nil {
+		return nil, err
 	}
-	for k, v := range gauges.Copy() {
-		metrics[i] = model.NewGauge(k, &v)
-		i++
+	histograms, err := toSeries(series.histograms.Copy(), func(id string, value **model.HistogramValue) *model.Metrics {
+		return model.NewHistogram(id, *value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := toSeries(series.summaries.Copy(), func(id string, value **model.SummaryValue) *model.Metrics {
+		return model.NewSummary(id, *value)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return metrics
+	metrics := append(counters, gauges...)
+	metrics = append(metrics, histograms...)
+	return append(metrics, summaries...), nil
 }
 
+// sync saves a snapshot of the storage, keeping previous snapshots as older generations.
 func (s *FileSyncer) sync(filepath string) error {
 	logger.Log().Infof("Saving metrics to file %s", filepath)
-	file, err := os.OpenFile(filepath, os.O_WRONLY|os.O_CREATE, 0666)
+	if s.wal == nil {
+		metrics, err := toMetrics(s.series)
+		if err != nil {
+			return err
+		}
+		if err = writeSnapshot(filepath, s.generations(), metrics); err != nil {
+			return err
+		}
+		logger.Log().Info("Metrics has been successfully saved.")
+		return nil
+	}
+
+	var snap snapshot
+	lsn, err := s.wal.Checkpoint(func() (err error) {
+		snap.Metrics, err = toMetrics(s.series)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	encoder := json.NewEncoder(file)
-	metrics := toMetrics(s.counters, s.gauges)
-	if err = encoder.Encode(metrics); err != nil {
+	snap.LSN = lsn
+	if err = writeSnapshot(filepath, s.generations(), snap); err != nil {
 		return err
 	}
-	logger.Log().Info("Metrics has been successfully saved.")
+	if err = s.wal.Purge(s.purgeLSN(filepath, snap.LSN)); err != nil {
+		return err
+	}
+	logger.Log().Infof("Metrics has been successfully saved at WAL position %d.", snap.LSN)
 	return nil
 }
 
+// writeSnapshot encodes the payload into a temporary file next to the target, flushes it to disk
+// and atomically replaces the target, so a crash never leaves a partially written snapshot behind.
+// The replaced snapshots are kept as name.1 ... name.<generations-1>, the oldest one is dropped.
+func writeSnapshot(name string, generations int, payload any) (err error) {
+	dir := filepath.Dir(name)
+	tmp, err := os.CreateTemp(dir, filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = json.NewEncoder(tmp).Encode(payload); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	for i := generations - 1; i > 0; i-- {
+		if err = os.Rename(generationName(name, i-1), generationName(name, i)); err != nil &&
+			!errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes directory entries, so renames survive a power loss.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// generationName gives the file of the snapshot generation, where 0 is the latest snapshot.
+func generationName(name string, generation int) string {
+	if generation == 0 {
+		return name
+	}
+	return name + "." + strconv.Itoa(generation)
+}
+
+func (s *FileSyncer) generations() int {
+	if s.config.generations <= 0 {
+		return DefaultGenerations
+	}
+	return s.config.generations
+}
+
+// purgeLSN gives the WAL position covered by every kept snapshot generation,
+// so the log can still be replayed on top of any of them.
+func (s *FileSyncer) purgeLSN(filepath string, lsn uint64) uint64 {
+	for i := s.generations() - 1; i > 0; i-- {
+		snap, err := readSnapshot(generationName(filepath, i))
+		if err == nil {
+			return min(snap.LSN, lsn)
+		}
+	}
+	return lsn
+}
+
 func (s *FileSyncer) Start(ctx context.Context, wg *sync.WaitGroup) {
+	var lsn uint64
 	if s.config.restore {
-		if err := s.load(s.config.filepath); err != nil {
+		var err error
+		if lsn, err = s.restore(s.config.filepath); err != nil {
 			logger.Log().Errorf("Error loading metrics from file: %v", err)
 		}
 	}
+	if s.wal != nil {
+		s.openWAL(lsn)
+	}
 	handleSync := func(close bool) {
 		if close {
 			logger.Log().Info("Syncing storage with the filesystem due to graceful shutdown...")
@@ -85,6 +243,11 @@ func (s *FileSyncer) Start(ctx context.Context, wg *sync.WaitGroup) {
 		if err := s.sync(s.config.filepath); err != nil {
 			logger.Log().Errorf("Error syncing to the file: %v", err)
 		}
+		if close && s.wal != nil {
+			if err := s.wal.Close(); err != nil {
+				logger.Log().Errorf("Error closing WAL: %v", err)
+			}
+		}
 	}
 	wg.Add(1)
 	if s.config.interval == 0 {
@@ -92,7 +255,12 @@ func (s *FileSyncer) Start(ctx context.Context, wg *sync.WaitGroup) {
 			defer wg.Done()
 			for {
 				select {
-				case <-s.syncCh:
+				case _, ok := <-s.syncCh:
+					if !ok {
+						// the storage is closed, save the final snapshot and stop
+						handleSync(true)
+						return
+					}
 					handleSync(false)
 				case <-ctx.Done():
 					handleSync(true)
@@ -118,30 +286,109 @@ func (s *FileSyncer) Start(ctx context.Context, wg *sync.WaitGroup) {
 	}
 }
 
+// openWAL replays the log on top of the loaded snapshot, or discards it when the storage is not restored,
+// and opens it for writing. On failure the storage proceeds without the log.
+func (s *FileSyncer) openWAL(lsn uint64) {
+	if s.config.restore {
+		var (
+			err      error
+			replayed int
+		)
+		lsn, err = s.wal.Replay(lsn, func(metrics []model.Metrics) {
+			replayed++
+			for i := range metrics {
+				// mutations are repeated as they were made, including the ones failed with the same error
+				if err := s.series.apply(&metrics[i]); err != nil {
+					return
+				}
+			}
+		})
+		if err != nil {
+			logger.Log().Errorf("Error replaying WAL: %v", err)
+		}
+		logger.Log().Infof("%d WAL records have been replayed.", replayed)
+	} else if err := s.wal.Reset(); err != nil {
+		logger.Log().Errorf("Error resetting WAL: %v", err)
+	}
+	if err := s.wal.Open(lsn); err != nil {
+		logger.Log().Errorf("Error opening WAL, proceeding without it: %v", err)
+	}
+}
+
+// restore loads the newest snapshot generation which can be read.
+func (s *FileSyncer) restore(filepath string) (uint64, error) {
+	var errs []error
+	for i := 0; i < s.generations(); i++ {
+		lsn, err := s.load(generationName(filepath, i))
+		if err == nil {
+			return lsn, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Log().Warnf("Snapshot %s cannot be loaded: %v", generationName(filepath, i), err)
+		}
+		errs = append(errs, err)
+	}
+	return 0, errors.Join(errs...)
+}
+
+// readSnapshot decodes the snapshot file, snapshots saved without WAL have zero LSN.
+func readSnapshot(filepath string) (*snapshot, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var raw json.RawMessage
+	if err = json.NewDecoder(file).Decode(&raw); err != nil {
+		return nil, err
+	}
+	var snap snapshot
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = json.Unmarshal(raw, &snap)
+	} else {
+		err = json.Unmarshal(raw, &snap.Metrics)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range snap.Metrics {
+		if (m.MType == model.Counter && m.Delta == nil) || (m.MType == model.Gauge && m.Value == nil) ||
+			(m.MType == model.Histogram && m.Histogram == nil) || (m.MType == model.Summary && m.Summary == nil) {
+			return nil, fmt.Errorf("metric %s of type %s has no value", m.ID, m.MType)
+		}
+	}
+	return &snap, nil
+}
+
 // load initializes storage with metric values that have been read from file.
-func (s *FileSyncer) load(filepath string) error {
+// Returns the WAL position of the snapshot, which is zero for snapshots saved without WAL.
+func (s *FileSyncer) load(filepath string) (uint64, error) {
 	logger.Log().Infof("Loading metrics from file %s...", filepath)
-	file, err := os.OpenFile(filepath, os.O_RDONLY, 0666)
+	snap, err := readSnapshot(filepath)
 	if err != nil {
-		return err
-	}
-	decoder := json.NewDecoder(file)
-	var metrics []model.Metrics
-	if err = decoder.Decode(&metrics); err != nil {
-		return err
+		return 0, err
 	}
+	metrics := snap.Metrics
 	counters := make(map[string]int64)
 	gauges := make(map[string]float64)
+	histograms := make(map[string]*model.HistogramValue)
+	summaries := make(map[string]*model.SummaryValue)
 	for _, m := range metrics {
 		switch m.MType {
 		case model.Counter:
-			counters[m.ID] = *m.Delta
+			counters[m.SeriesKey()] = *m.Delta
 		case model.Gauge:
-			gauges[m.ID] = *m.Value
+			gauges[m.SeriesKey()] = *m.Value
+		case model.Histogram:
+			histograms[m.SeriesKey()] = m.Histogram
+		case model.Summary:
+			summaries[m.SeriesKey()] = m.Summary
 		}
 	}
-	s.counters.Init(counters)
-	s.gauges.Init(gauges)
+	s.series.counters.Init(counters)
+	s.series.gauges.Init(gauges)
+	s.series.histograms.Init(histograms)
+	s.series.summaries.Init(summaries)
 	logger.Log().Infof("Metrics has been successfully loaded from file %s.", filepath)
-	return nil
+	return snap.LSN, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -15,24 +16,29 @@ import (
 )
 
 func TestFileSyncer_SyncDelay(t *testing.T) {
-	f, err := os.CreateTemp("", "file_syncer_load_test")
+	f, err := os.CreateTemp(t.TempDir(), "file_syncer_load_test")
 	require.NoError(t, err)
-	defer func() {
-		os.Remove(f.Name())
-	}()
 
 	cfg := NewConfig(f.Name(), 1, false)
-	counters := NewConcurrentMap[int64](1)
-	counters.Set("c0", 64)
-	gauges := NewConcurrentMap[float64](1)
-	gauges.Set("g0", 64.0)
+	series := NewSeries()
+	series.counters.Set("c0", 64)
+	series.gauges.Set("g0", 64.0)
 
+	ctx, cancel := context.WithCancel(context.Background())
 	var wg sync.WaitGroup
-	syncer := NewFileSyncer(cfg, counters, gauges, nil)
-	syncer.Start(context.Background(), &wg)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	syncer := NewFileSyncer(cfg, series, nil)
+	syncer.Start(ctx, &wg)
 
 	time.Sleep(2 * time.Second) // to wait syncer with delay=1
-	decoder := json.NewDecoder(f)
+	// the snapshot replaces the file, so it has to be reopened
+	saved, err := os.Open(f.Name())
+	require.NoError(t, err)
+	defer saved.Close()
+	decoder := json.NewDecoder(saved)
 	metrics := make([]model.Metrics, 2)
 	err = decoder.Decode(&metrics)
 	require.NoError(t, err)
@@ -43,28 +49,31 @@ func TestFileSyncer_SyncDelay(t *testing.T) {
 }
 
 func TestFileSyncer_SyncNoDelay(t *testing.T) {
-	f, err := os.CreateTemp("", "file_syncer_load_test")
+	f, err := os.CreateTemp(t.TempDir(), "file_syncer_load_test")
 	require.NoError(t, err)
-	defer func() {
-		os.Remove(f.Name())
-	}()
 
 	cfg := NewConfig(f.Name(), 0, false)
-	counters := NewConcurrentMap[int64](1)
-	counters.Set("c0", 64)
-	gauges := NewConcurrentMap[float64](1)
-	gauges.Set("g0", 64.0)
+	series := NewSeries()
+	series.counters.Set("c0", 64)
+	series.gauges.Set("g0", 64.0)
 	syncCh := make(chan int)
-	defer close(syncCh)
 
 	var wg sync.WaitGroup
-	syncer := NewFileSyncer(cfg, counters, gauges, syncCh)
+	defer func() {
+		close(syncCh)
+		wg.Wait()
+	}()
+	syncer := NewFileSyncer(cfg, series, syncCh)
 	syncer.Start(context.Background(), &wg)
 
 	syncCh <- 1
 
 	time.Sleep(100 * time.Millisecond)
-	decoder := json.NewDecoder(f)
+	// the snapshot replaces the file, so it has to be reopened
+	saved, err := os.Open(f.Name())
+	require.NoError(t, err)
+	defer saved.Close()
+	decoder := json.NewDecoder(saved)
 	metrics := make([]model.Metrics, 2)
 	err = decoder.Decode(&metrics)
 	require.NoError(t, err)
@@ -75,20 +84,73 @@ func TestFileSyncer_SyncNoDelay(t *testing.T) {
 }
 
 func TestFileSyncer_Load(t *testing.T) {
-	filepath := "../../../test_data/metrics.json"
-	cfg := NewConfig(filepath, 5, true)
-	counters := NewConcurrentMap[int64](1)
-	gauges := NewConcurrentMap[float64](1)
+	data, err := os.ReadFile("../../../test_data/metrics.json")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	require.NoError(t, os.WriteFile(path, data, 0666))
+	cfg := NewConfig(path, 5, true)
+	series := NewSeries()
 
+	ctx, cancel := context.WithCancel(context.Background())
 	var wg sync.WaitGroup
-	syncer := NewFileSyncer(cfg, counters, gauges, nil)
-	syncer.Start(context.Background(), &wg)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	syncer := NewFileSyncer(cfg, series, nil)
+	syncer.Start(ctx, &wg)
 
-	c, exists := counters.Get("C1")
+	c, exists := series.counters.Get("C1")
 	assert.True(t, exists)
 	assert.Equal(t, int64(800), c)
 
-	g, exists := gauges.Get("G2")
+	g, exists := series.gauges.Get("G2")
 	assert.True(t, exists)
 	assert.Equal(t, float64(127.452), g)
 }
+
+func TestFileSyncer_SyncHistogram(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "file_syncer_histogram_test")
+	require.NoError(t, err)
+
+	histogram := model.NewHistogramValue([]float64{1, 2})
+	histogram.Observe(1.5)
+	series := NewSeries()
+	series.histograms.Init(map[string]*model.HistogramValue{`latency{host="a"}`: histogram})
+
+	syncer := NewFileSyncer(NewConfig(f.Name(), 1, false), series, nil)
+	require.NoError(t, syncer.sync(f.Name()))
+
+	restored := NewSeries()
+	_, err = NewFileSyncer(NewConfig(f.Name(), 1, true), restored, nil).load(f.Name())
+	require.NoError(t, err)
+	h, exists := restored.histograms.Get(`latency{host="a"}`)
+	assert.True(t, exists)
+	assert.Equal(t, histogram, h)
+}
+
+func TestFileSyncer_Generations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	series := NewSeries()
+	syncer := NewFileSyncer(NewConfig(path, 1, true).WithGenerations(2), series, nil)
+
+	for _, v := range []int64{1, 2, 3} {
+		series.counters.Set("c0", v)
+		require.NoError(t, syncer.sync(path))
+	}
+	_, err := os.Stat(generationName(path, 2))
+	require.ErrorIs(t, err, os.ErrNotExist, "only 2 generations must be kept")
+
+	// the latest snapshot is torn, so the previous one is loaded
+	require.NoError(t, os.WriteFile(path, []byte(`[{"id":"c0","type":"counter","del`), 0666))
+	restored := NewSeries()
+	_, err = NewFileSyncer(NewConfig(path, 1, true).WithGenerations(2), restored, nil).restore(path)
+	require.NoError(t, err)
+	c, exists := restored.counters.Get("c0")
+	assert.True(t, exists)
+	assert.Equal(t, int64(2), c)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "temporary files must not be left behind")
+}
@@ -0,0 +1,296 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/itallix/go-metrics/internal/logger"
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+// WAL record layout:
+//
+//	LSN (8 bytes) | payload length (4 bytes) | CRC-32C of LSN, length and payload (4 bytes) | JSON payload
+//
+// Every record holds the metrics of a single Update or UpdateBatch call as they were received,
+// so replaying the records on top of the snapshot repeats the same mutations.
+const (
+	walHeaderSize    = 16
+	walMaxRecordSize = 64 << 20
+	walSegmentDigits = 20
+)
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errTornRecord = errors.New("torn WAL record")
+)
+
+// WAL is an append-only log of storage mutations. The active segment is stored at path,
+// sealed segments are named path.<LSN of the last record>, and get removed once they are covered by a snapshot.
+type WAL struct {
+	path string
+	file *os.File
+	lsn  uint64
+	size int64
+	mu   sync.Mutex
+}
+
+func NewWAL(path string) *WAL {
+	return &WAL{path: path}
+}
+
+// Open opens the active segment for appending. New records get LSNs after the given one.
+func (w *WAL) Open(lsn uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("cannot open WAL: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("cannot open WAL: %w", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	w.lsn = max(w.lsn, lsn)
+	return nil
+}
+
+// Write appends the metrics as a single record, flushes it to disk and then calls apply.
+// Records are written and applied under the same lock, so the log order matches the order of mutations.
+// When the log is not open, mutations are applied without logging.
+func (w *WAL) Write(metrics []model.Metrics, apply func() error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return apply()
+	}
+
+	payload, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("cannot encode WAL record: %w", err)
+	}
+
+	record := make([]byte, walHeaderSize, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint64(record[0:8], w.lsn+1)
+	binary.BigEndian.PutUint32(record[8:12], uint32(len(payload)))
+	record = append(record, payload...)
+	crc := crc32.Update(crc32.Checksum(record[0:12], crcTable), crcTable, payload)
+	binary.BigEndian.PutUint32(record[12:16], crc)
+
+	if _, err = w.file.Write(record); err != nil {
+		// drop the partially written record, otherwise replay stops at it
+		_ = w.file.Truncate(w.size)
+		return fmt.Errorf("cannot write WAL record: %w", err)
+	}
+	if err = w.file.Sync(); err != nil {
+		return fmt.Errorf("cannot sync WAL: %w", err)
+	}
+	w.lsn++
+	w.size += int64(len(record))
+	return apply()
+}
+
+// Checkpoint blocks writers, calls capture to take a consistent copy of the storage and seals the active segment.
+// It returns the LSN covered by the captured copy, segments up to it can be purged once the copy is saved.
+func (w *WAL) Checkpoint(capture func() error) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := capture(); err != nil {
+		return 0, err
+	}
+	if w.file == nil || w.size == 0 {
+		return w.lsn, nil
+	}
+	if err := w.file.Close(); err != nil {
+		return 0, fmt.Errorf("cannot close WAL segment: %w", err)
+	}
+	w.file = nil
+	if err := os.Rename(w.path, segmentName(w.path, w.lsn)); err != nil {
+		return 0, fmt.Errorf("cannot seal WAL segment: %w", err)
+	}
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return 0, fmt.Errorf("cannot open WAL: %w", err)
+	}
+	w.file = file
+	w.size = 0
+	return w.lsn, nil
+}
+
+// Purge removes sealed segments whose records are all covered by the snapshot with the given LSN.
+func (w *WAL) Purge(lsn uint64) error {
+	segments, err := w.segments()
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if segment.last > lsn {
+			break
+		}
+		if err = os.Remove(segment.path); err != nil {
+			return fmt.Errorf("cannot remove WAL segment: %w", err)
+		}
+	}
+	return nil
+}
+
+// Reset removes every segment, it is used when the storage starts without restoring previous state.
+func (w *WAL) Reset() error {
+	segments, err := w.segments()
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if err = os.Remove(segment.path); err != nil {
+			return fmt.Errorf("cannot remove WAL segment: %w", err)
+		}
+	}
+	if err = os.Remove(w.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot remove WAL: %w", err)
+	}
+	return nil
+}
+
+// Replay reads every segment in order and passes records with LSN after the given one to apply.
+// A torn or corrupted record ends the segment: it is cut off, so new records are not appended after garbage.
+// Returns the LSN of the last replayed record.
+func (w *WAL) Replay(lsn uint64, apply func(metrics []model.Metrics)) (uint64, error) {
+	segments, err := w.segments()
+	if err != nil {
+		return lsn, err
+	}
+	paths := make([]string, 0, len(segments)+1)
+	for _, segment := range segments {
+		paths = append(paths, segment.path)
+	}
+	paths = append(paths, w.path)
+
+	last := lsn
+	for _, path := range paths {
+		if last, err = replaySegment(path, last, apply); err != nil {
+			return last, err
+		}
+	}
+	w.lsn = last
+	return last, nil
+}
+
+func replaySegment(path string, lsn uint64, apply func(metrics []model.Metrics)) (uint64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0666)
+	if errors.Is(err, os.ErrNotExist) {
+		return lsn, nil
+	}
+	if err != nil {
+		return lsn, fmt.Errorf("cannot open WAL segment: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		recordLSN, payload, err := readRecord(reader)
+		if errors.Is(err, io.EOF) {
+			return lsn, nil
+		}
+		if err != nil {
+			logger.Log().Warnf("WAL segment %s is cut at offset %d: %v", path, offset, err)
+			if err = file.Truncate(offset); err != nil {
+				return lsn, fmt.Errorf("cannot truncate WAL segment: %w", err)
+			}
+			return lsn, nil
+		}
+		offset += int64(walHeaderSize + len(payload))
+		if recordLSN <= lsn {
+			continue
+		}
+
+		var metrics []model.Metrics
+		if err = json.Unmarshal(payload, &metrics); err != nil {
+			return lsn, fmt.Errorf("cannot decode WAL record %d: %w", recordLSN, err)
+		}
+		apply(metrics)
+		lsn = recordLSN
+	}
+}
+
+// readRecord returns io.EOF at the end of the segment and errTornRecord when the record is incomplete or corrupted.
+func readRecord(reader io.Reader) (uint64, []byte, error) {
+	header := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, errTornRecord
+		}
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[8:12])
+	if length > walMaxRecordSize {
+		return 0, nil, errTornRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return 0, nil, errTornRecord
+	}
+	crc := crc32.Update(crc32.Checksum(header[0:12], crcTable), crcTable, payload)
+	if crc != binary.BigEndian.Uint32(header[12:16]) {
+		return 0, nil, errTornRecord
+	}
+	return binary.BigEndian.Uint64(header[0:8]), payload, nil
+}
+
+// Close flushes and closes the active segment.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+type segment struct {
+	path string
+	last uint64
+}
+
+// segments lists sealed segments ordered by LSN.
+func (w *WAL) segments() ([]segment, error) {
+	matches, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var segments []segment
+	for _, match := range matches {
+		last, err := strconv.ParseUint(strings.TrimPrefix(match, w.path+"."), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{path: match, last: last})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].last < segments[j].last
+	})
+	return segments, nil
+}
+
+func segmentName(path string, lsn uint64) string {
+	return fmt.Sprintf("%s.%0*d", path, walSegmentDigits, lsn)
+}
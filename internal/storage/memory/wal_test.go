@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itallix/go-metrics/internal/model"
+)
+
+func TestWAL_Replay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.wal")
+	wal := NewWAL(path)
+	require.NoError(t, wal.Open(0))
+
+	for _, delta := range []int64{1, 2, 3} {
+		require.NoError(t, wal.Write([]model.Metrics{*model.NewCounter("c0", &delta)}, func() error { return nil }))
+	}
+	require.NoError(t, wal.Close())
+
+	tests := []struct {
+		name      string
+		giveLSN   uint64
+		wantLSN   uint64
+		wantDelta []int64
+	}{
+		{
+			name:      "All",
+			wantLSN:   3,
+			wantDelta: []int64{1, 2, 3},
+		},
+		{
+			name:      "AfterSnapshot",
+			giveLSN:   2,
+			wantLSN:   3,
+			wantDelta: []int64{3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deltas []int64
+			lsn, err := NewWAL(path).Replay(tt.giveLSN, func(metrics []model.Metrics) {
+				deltas = append(deltas, *metrics[0].Delta)
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLSN, lsn)
+			assert.Equal(t, tt.wantDelta, deltas)
+		})
+	}
+}
+
+func TestWAL_TornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.wal")
+	wal := NewWAL(path)
+	require.NoError(t, wal.Open(0))
+	delta := int64(5)
+	require.NoError(t, wal.Write([]model.Metrics{*model.NewCounter("c0", &delta)}, func() error { return nil }))
+	require.NoError(t, wal.Write([]model.Metrics{*model.NewCounter("c0", &delta)}, func() error { return nil }))
+	require.NoError(t, wal.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+
+	var replayed int
+	wal = NewWAL(path)
+	lsn, err := wal.Replay(0, func([]model.Metrics) { replayed++ })
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), lsn)
+	assert.Equal(t, 1, replayed)
+
+	// new records continue after the last complete one
+	require.NoError(t, wal.Open(lsn))
+	require.NoError(t, wal.Write([]model.Metrics{*model.NewCounter("c0", &delta)}, func() error { return nil }))
+	require.NoError(t, wal.Close())
+
+	replayed = 0
+	lsn, err = NewWAL(path).Replay(0, func([]model.Metrics) { replayed++ })
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), lsn)
+	assert.Equal(t, 2, replayed)
+}
+
+func TestStorage_WALRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	newStorage := func(ctx context.Context, wg *sync.WaitGroup) *MemStorage {
+		return NewMemStorage(ctx, wg, NewConfig(path, 3600, true).WithWAL(true))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	s := newStorage(ctx, &wg)
+	c, g := int64(2), 1.5
+	require.NoError(t, s.Update(ctx, model.NewCounter("c0", &c)))
+	require.NoError(t, s.UpdateBatch(ctx, []model.Metrics{*model.NewCounter("c0", &c), *model.NewGauge("g0", &g)}))
+
+	// snapshot in the middle: records before it must not be applied twice
+	syncer := NewFileSyncer(NewConfig(path, 3600, true), s.Series, nil).WithWAL(s.wal)
+	require.NoError(t, syncer.sync(path))
+	require.NoError(t, s.Update(ctx, model.NewCounter("c0", &c)))
+
+	// simulate crash: drop the storage without the final snapshot
+	require.NoError(t, s.wal.Close())
+	restored := newStorage(ctx, &wg)
+
+	counter := model.Metrics{ID: "c0", MType: model.Counter}
+	require.NoError(t, restored.Read(ctx, &counter))
+	assert.Equal(t, int64(6), *counter.Delta)
+	gauge := model.Metrics{ID: "g0", MType: model.Gauge}
+	require.NoError(t, restored.Read(ctx, &gauge))
+	assert.InDelta(t, 1.5, *gauge.Value, 0.0001)
+
+	cancel()
+	wg.Wait()
+}
@@ -8,12 +8,22 @@ import (
 )
 
 // Storage defines an interface for the storage API, detailing the methods used to interact with storage systems.
+// Series are identified by the metric ID together with its labels.
 type Storage interface {
 	Update(ctx context.Context, metric *model.Metrics) error
 	UpdateBatch(ctx context.Context, metrics []model.Metrics) error
 	Read(ctx context.Context, metric *model.Metrics) error
-	GetCounters(ctx context.Context) (map[string]int64, error)
-	GetGauges(ctx context.Context) (map[string]float64, error)
+	// ReadRange returns time ordered samples of a counter or gauge series within the query time range.
+	// Without step at most model.MaxRangePoints samples are returned, ErrTooManySamples is returned otherwise.
+	ReadRange(ctx context.Context, query *model.RangeQuery) ([]model.Sample, error)
+	// GetCounters returns every counter series ordered by series key.
+	GetCounters(ctx context.Context) ([]model.Metrics, error)
+	// GetGauges returns every gauge series ordered by series key.
+	GetGauges(ctx context.Context) ([]model.Metrics, error)
+	// GetHistograms returns every histogram series ordered by series key.
+	GetHistograms(ctx context.Context) ([]model.Metrics, error)
+	// GetSummaries returns every summary series ordered by series key.
+	GetSummaries(ctx context.Context) ([]model.Metrics, error)
 
 	Ping(ctx context.Context) bool
 	Close()
@@ -22,4 +32,5 @@ type Storage interface {
 var (
 	ErrMetricNotSupported = errors.New("metric type is not supported")
 	ErrMetricNotFound     = errors.New("metric is not found")
+	ErrTooManySamples     = errors.New("too many samples")
 )